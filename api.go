@@ -0,0 +1,8 @@
+// Package api встраивает в сервер описание api (api_swagger.yaml), чтобы сервер
+// отдавал и проверял именно то описание, с которым он собран
+package api
+
+import _ "embed"
+
+//go:embed api_swagger.yaml
+var Swagger []byte
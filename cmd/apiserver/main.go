@@ -40,7 +40,11 @@ func main() {
 	}
 	slog.SetLogLoggerLevel(level)
 
-	config := apiserver.NewConfig()
+	config, err := apiserver.NewConfig()
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
 
 	server := apiserver.NewAPIServer(config)
 	if err = server.Start(); err != nil {
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"ApiServer/internal/app/db"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// утилита для администрирования библиотеки
+// работает напрямую с хранилищем, указанным в файле окружения, минуя api сервер
+// использование: libadmin [-p путь к .env] <команда> [флаги команды]
+
+var envPath string
+
+type command struct {
+	description string
+	run         func(store db.Store, args []string) error
+	// команда не обращается к хранилищу, run получает nil
+	offline bool
+}
+
+var commands = map[string]command{
+	"merge-artists": {
+		description: "merge duplicate artists into one",
+		run:         mergeArtists,
+	},
+	"mint-key": {
+		description: "create an api key, the key is printed only once",
+		run:         mintKey,
+	},
+	"revoke-key": {
+		description: "revoke an api key by its id",
+		run:         revokeKey,
+	},
+	"list-keys": {
+		description: "list api keys including revoked ones",
+		run:         listKeys,
+	},
+	"gen-jwt-key": {
+		description: "generate a token signing key file for JWT_KEYS",
+		run:         genJWTKey,
+		offline:     true,
+	},
+}
+
+func init() {
+	flag.StringVar(&envPath, "p", `env\.env`, "Path to environment file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-p env file] <command> [command flags]\n\nCommands:\n", os.Args[0])
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %-16s %s\n", name, commands[name].description)
+		}
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if cmd.offline {
+		err := cmd.run(nil, flag.Args()[1:])
+		if err != nil {
+			slog.Error(flag.Arg(0)+" failed", "error", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	err := godotenv.Load(envPath)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	var level slog.Level
+	err = level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL")))
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	slog.SetLogLoggerLevel(level)
+
+	config := db.NewConfig()
+	// данные хранилища в памяти принадлежат процессу сервера, изменить их отсюда нельзя
+	if config.Driver == db.DriverMemory {
+		slog.Error("in-memory storage can't be administered from outside the server")
+		os.Exit(1)
+	}
+
+	store, err := db.NewStore(config)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	err = store.Open()
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	err = cmd.run(store, flag.Args()[1:])
+	if err != nil {
+		slog.Error(flag.Arg(0)+" failed", "error", err.Error())
+		os.Exit(1)
+	}
+}
+
+// объединение исполнителей, см. db.Store.MergeArtists
+func mergeArtists(store db.Store, args []string) error {
+	fs := flag.NewFlagSet("merge-artists", flag.ExitOnError)
+	target := fs.Int("target", 0, "id of the artist to merge into")
+	sources := fs.String("sources", "", "comma separated ids of the artists to merge")
+	policy := fs.String("policy", string(db.MergeFail), "what to do with songs having the same name: keep_newest, keep_target or fail")
+	fs.Parse(args)
+
+	ids, err := parseIDs(*sources)
+	if err != nil {
+		return err
+	}
+	if *target == 0 || len(ids) == 0 {
+		return errors.New("both -target and -sources are required")
+	}
+	if !db.MergePolicy(*policy).Valid() {
+		return fmt.Errorf("unknown policy %q", *policy)
+	}
+
+	result, err := store.MergeArtists(*target, ids, db.MergePolicy(*policy), "libadmin")
+	if err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+// создание ключа доступа к api, см. db.NewAPIKeySecret
+func mintKey(store db.Store, args []string) error {
+	fs := flag.NewFlagSet("mint-key", flag.ExitOnError)
+	name := fs.String("name", "", "who or what the key is issued to")
+	role := fs.String("role", string(db.RoleReader), "role of the key: reader, editor or admin")
+	fs.Parse(args)
+
+	if strings.TrimSpace(*name) == "" {
+		return errors.New("-name is required")
+	}
+	if !db.Role(*role).Valid() {
+		return fmt.Errorf("unknown role %q", *role)
+	}
+
+	secret, hash, err := db.NewAPIKeySecret()
+	if err != nil {
+		return err
+	}
+	key, err := store.AddAPIKey(db.APIKey{Name: strings.TrimSpace(*name), Role: db.Role(*role)}, hash)
+	if err != nil {
+		return err
+	}
+	return printJSON(struct {
+		db.APIKey
+		Key string `json:"key"`
+	}{key, secret})
+}
+
+func revokeKey(store db.Store, args []string) error {
+	fs := flag.NewFlagSet("revoke-key", flag.ExitOnError)
+	id := fs.Int64("id", 0, "id of the key")
+	fs.Parse(args)
+
+	if *id == 0 {
+		return errors.New("-id is required")
+	}
+	return store.RevokeAPIKey(*id)
+}
+
+func listKeys(store db.Store, args []string) error {
+	keys, err := store.ListAPIKeys()
+	if err != nil {
+		return err
+	}
+	return printJSON(keys)
+}
+
+// создание ключа подписи токенов: секрета для hs256 или пары ключей ed25519,
+// открытый ключ ed25519 записывается в файл с расширением .pub - его можно оставить
+// в JWT_KEYS после смены ключа подписи, чтобы выданные ранее токены оставались действительными
+func genJWTKey(_ db.Store, args []string) error {
+	fs := flag.NewFlagSet("gen-jwt-key", flag.ExitOnError)
+	alg := fs.String("alg", "ed25519", "algorithm: ed25519 or hs256")
+	out := fs.String("out", "", "file to write the key to")
+	fs.Parse(args)
+
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+
+	switch *alg {
+	case "hs256":
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(*out, []byte(hex.EncodeToString(secret)+"\n"), 0600)
+	case "ed25519":
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			return err
+		}
+		publicDER, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(*out, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(*out+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644)
+	default:
+		return fmt.Errorf("unknown algorithm %q", *alg)
+	}
+}
+
+func parseIDs(list string) ([]int, error) {
+	ids := make([]int, 0)
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad id %q: %w", s, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
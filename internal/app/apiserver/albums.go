@@ -0,0 +1,274 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// тело запроса на добавление песни в альбом
+type trackRequest struct {
+	Disc     int `json:"disc"`
+	Position int `json:"position"`
+}
+
+// список альбомов, параметр artist - id исполнителя
+func (s *APIServer) listAlbums() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("list albums request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		artistID, _ := v.Int("artist", request.FormValue("artist"), 1, 0)
+		offset, limit := request.FormValue("offset"), request.FormValue("limit")
+		checkPage(&v, offset, limit)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		albums, err := s.store.ListAlbums(artistID, offset, limit)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeJSON(writer, 200, albums)
+	}
+}
+
+// альбом вместе с треками
+func (s *APIServer) getAlbum() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("get album request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad album id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad album id")
+			return
+		}
+		var v validate.Validator
+		fields := songFields(request, &v)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		album, err := s.store.GetAlbum(id)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		writeJSON(writer, 200, albumFields(album, fields))
+	}
+}
+
+func (s *APIServer) addAlbum() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("add album request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		album, ok := readAlbum(writer, request)
+		if !ok {
+			return
+		}
+
+		album, err := s.store.AddAlbum(album)
+		if err != nil {
+			slog.Error("error adding album", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		writeJSON(writer, 201, album)
+	}
+}
+
+// замена данных альбома, треки не изменяются
+func (s *APIServer) updateAlbum() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("update album request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad album id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad album id")
+			return
+		}
+
+		album, ok := readAlbum(writer, request)
+		if !ok {
+			return
+		}
+
+		err = s.store.UpdateAlbum(id, album)
+		if err != nil {
+			slog.Error("error updating album", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		album, err = s.store.GetAlbum(id)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeJSON(writer, 200, albumFields(album, db.DefaultSongFields))
+	}
+}
+
+// удаление альбома, его песни остаются в библиотеке
+func (s *APIServer) deleteAlbum() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("delete album request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad album id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad album id")
+			return
+		}
+
+		err = s.store.DeleteAlbum(id)
+		if err != nil {
+			slog.Error("error deleting album", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// добавление песни в альбом или изменение её позиции
+// диск по умолчанию - первый, позиция обязательна
+func (s *APIServer) setTrack() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("set album track request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		albumID, err := pathID(request)
+		if err != nil {
+			slog.Error("bad album id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad album id")
+			return
+		}
+		songID, err := pathInt(request, "songId")
+		if err != nil {
+			slog.Error("bad song id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "songId", "bad song id")
+			return
+		}
+
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			slog.Error("error reading request body", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
+			return
+		}
+		track := trackRequest{Disc: 1}
+		err = json.Unmarshal(body, &track)
+		if err != nil {
+			slog.Error("unmarshal error", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadBody, "", err.Error())
+			return
+		}
+		var v validate.Validator
+		v.Min("disc", track.Disc, 1)
+		v.Min("position", track.Position, 1)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		err = s.store.SetTrack(albumID, songID, track.Disc, track.Position)
+		if err != nil {
+			slog.Error("error setting album track", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		album, err := s.store.GetAlbum(albumID)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeJSON(writer, 200, albumFields(album, db.DefaultSongFields))
+	}
+}
+
+// удаление песни из альбома, сама песня остаётся в библиотеке
+func (s *APIServer) removeTrack() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("remove album track request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		albumID, err := pathID(request)
+		if err != nil {
+			slog.Error("bad album id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad album id")
+			return
+		}
+		songID, err := pathInt(request, "songId")
+		if err != nil {
+			slog.Error("bad song id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "songId", "bad song id")
+			return
+		}
+
+		err = s.store.RemoveTrack(albumID, songID)
+		if err != nil {
+			slog.Error("error removing album track", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// читает данные альбома из тела запроса, при ошибке сам отвечает 400
+// тип по умолчанию - LP
+func readAlbum(writer http.ResponseWriter, request *http.Request) (db.Album, bool) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		slog.Error("error reading request body", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
+		return db.Album{}, false
+	}
+
+	album := db.Album{Type: "LP"}
+	err = json.Unmarshal(body, &album)
+	if err != nil {
+		slog.Error("unmarshal error", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", err.Error())
+		return db.Album{}, false
+	}
+
+	album.Title = strings.TrimSpace(album.Title)
+	var v validate.Validator
+	v.Name("title", album.Title)
+	v.Check(album.ArtistID != 0, "artistId", validate.CodeRequired, "album artist is required")
+	v.Date("releaseDate", album.ReleaseDate)
+	v.OneOf("type", album.Type, db.AlbumTypes)
+	if err := v.Err(); err != nil {
+		writeInvalid(writer, request, err)
+		return db.Album{}, false
+	}
+	return album, true
+}
+
+// оставляет в песнях альбома только поля fields
+func albumFields(album db.Album, fields []string) db.Album {
+	for i := range album.Tracks {
+		album.Tracks[i].Song = album.Tracks[i].Song.Only(fields)
+	}
+	return album
+}
@@ -0,0 +1,72 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"encoding/json"
+	"testing"
+)
+
+func TestAlbums(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria")
+
+	steps := []struct {
+		method, path, body string
+		want               wantStatus
+	}{
+		{"POST", "/albums", `{"title":"The Resistance","artistId":1,"releaseDate":"14.09.2009"}`, wantStatus{status: 201}},
+		{"POST", "/albums", `{"title":"Absolution","artistId":7}`, wantStatus{404, codeNotFound}},
+		{"POST", "/albums", `{"title":"Absolution","artistId":1,"type":"bootleg"}`, wantStatus{400, codeValidation}},
+		{"POST", "/albums", `{"title":"","artistId":1}`, wantStatus{400, codeValidation}},
+		{"PUT", "/albums/1/tracks/1", `{"position":1}`, wantStatus{status: 200}},
+		{"PUT", "/albums/1/tracks/2", `{"position":1}`, wantStatus{409, codeAlreadyExists}},
+		{"PUT", "/albums/1/tracks/2", `{"position":0}`, wantStatus{400, codeValidation}},
+		{"PUT", "/albums/1/tracks/9", `{"position":2}`, wantStatus{404, codeNotFound}},
+		{"PUT", "/albums/1/tracks/2", `{"disc":2,"position":1}`, wantStatus{status: 200}},
+		{"GET", "/albums/3", "", wantStatus{404, codeNotFound}},
+		{"GET", "/albums/1?fields=lyrics", "", wantStatus{400, codeValidation}},
+		{"DELETE", "/albums/1/tracks/9", "", wantStatus{404, codeNotFound}},
+		{"PUT", "/albums/3", `{"title":"Drones","artistId":1}`, wantStatus{404, codeNotFound}},
+	}
+	for _, step := range steps {
+		status, body := call(t, ts, step.method, step.path, step.body, nil)
+		t.Run(step.method+" "+step.path, func(t *testing.T) {
+			checkStatus(t, status, body, step.want)
+		})
+	}
+
+	// треки по диску и позиции, песни без текста, пока он не запрошен
+	status, body := call(t, ts, "GET", "/albums/1", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var album db.Album
+	err := json.Unmarshal([]byte(body), &album)
+	if err != nil {
+		t.Fatalf("decode album: %v", err)
+	}
+	if album.Artist != "Muse" || album.ReleaseDate != "2009-09-14" || len(album.Tracks) != 2 {
+		t.Fatalf("album = %+v", album)
+	}
+	first := album.Tracks[0]
+	if first.Disc != 1 || first.Song.SongName != "Uprising" || first.Song.Text != "" || first.Song.Version != 1 {
+		t.Errorf("first track = %+v", first)
+	}
+	if second := album.Tracks[1]; second.Disc != 2 || second.Song.SongName != "Hysteria" {
+		t.Errorf("second track = %+v", second)
+	}
+
+	status, body = call(t, ts, "GET", "/albums/1?fields=song,text", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	album = db.Album{}
+	json.Unmarshal([]byte(body), &album)
+	if len(album.Tracks) != 2 || album.Tracks[0].Song != (db.Song{SongName: "Uprising", Text: externalText}) {
+		t.Errorf("tracks with fields = %+v", album.Tracks)
+	}
+
+	// после удаления альбома его песни остаются в библиотеке
+	status, body = call(t, ts, "DELETE", "/albums/1", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 204})
+	status, body = call(t, ts, "GET", "/albums/1", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+	if got := libraryNames(t, ts, ""); len(got) != 2 {
+		t.Errorf("library after album delete = %q", got)
+	}
+}
@@ -2,6 +2,7 @@ package apiserver
 
 import (
 	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,6 +10,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
@@ -19,16 +21,25 @@ import (
 )
 
 type APIServer struct {
-	config   *Config
-	router   *mux.Router
-	database *db.Database
-	server   *http.Server
+	config *Config
+	router *mux.Router
+	store  db.Store
+	server *http.Server
+	// ключи подписи токенов, nil - токены не используются
+	keys *keySet
+	// встроенное описание api, отдаётся клиентам и сверяется с маршрутами
+	doc *apiDoc
+	// проверка по описанию api, nil - выключена
+	spec *specValidator
+	// маршруты без авторизации (см. publicRoute)
+	public map[*mux.Route]bool
 }
 
 func NewAPIServer(config *Config) *APIServer {
 	return &APIServer{
 		config: config,
 		router: mux.NewRouter(),
+		public: make(map[*mux.Route]bool),
 		server: &http.Server{
 			Addr:         config.BindPort,
 			ReadTimeout:  time.Second * 15,
@@ -40,14 +51,17 @@ func NewAPIServer(config *Config) *APIServer {
 func (s *APIServer) Start() error {
 	slog.Debug("debug is enabled")
 
-	s.configureRouter()
-	s.server.Handler = s.router
-
-	err := s.configureDB()
+	err := s.configure()
 	if err != nil {
 		return err
 	}
 
+	if s.config.TrashRetention > 0 {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		go s.purgeTrash(ctx, s.config.TrashRetention)
+	}
+
 	idleConnsClosed := make(chan struct{})
 
 	// горутина для перехвата SIGINT и graceful shutdown работы сервера
@@ -78,23 +92,151 @@ func (s *APIServer) Start() error {
 	return nil
 }
 
+// готовит сервер к работе: ключи, описание api, маршруты и хранилище.
+// После него s.server.Handler обрабатывает запросы, но порт ещё не слушается
+func (s *APIServer) configure() error {
+	keys, err := loadKeySet(s.config.JWTKeys, s.config.JWTSigningKey, s.config.JWTTTL)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+
+	s.doc, err = loadDoc()
+	if err != nil {
+		return err
+	}
+	s.spec, err = loadSpec(s.doc.doc, s.config.OpenAPISpec, s.config.OpenAPIValidation,
+		strings.EqualFold(s.config.LogLevel, "debug"))
+	if err != nil {
+		return err
+	}
+
+	s.configureRouter()
+	err = s.doc.checkRoutes(s.router)
+	if err != nil {
+		return err
+	}
+	s.server.Handler = withRequestID(s.router)
+
+	return s.configureDB()
+}
+
+// маршруты api, каждый обработчик доступен только с ролью не ниже указанной:
+// чтение - reader, изменение - editor, объединение и удаление исполнителей - admin
 func (s *APIServer) configureRouter() {
-	s.router.HandleFunc("/library/all", s.listLibrary()).Methods("GET")
-	s.router.HandleFunc("/library/text", s.showSongText()).Methods("GET")
-	s.router.HandleFunc("/library/delete", s.deleteSong()).Methods("DELETE")
-	s.router.HandleFunc("/library/add", s.addSong()).Methods("POST")
-	s.router.HandleFunc("/library/update", s.updateSong()).Methods("PATCH")
+	s.router.Use(s.authenticate)
+	if s.spec != nil {
+		s.router.Use(s.spec.middleware)
+	}
+	s.router.NotFoundHandler = routeNotFound()
+	s.router.MethodNotAllowedHandler = methodNotAllowed()
+
+	s.router.HandleFunc("/auth/token", s.role(db.RoleReader, s.issueToken())).Methods("POST")
+	s.router.HandleFunc("/auth/token", s.role(db.RoleReader, s.revokeToken())).Methods("DELETE")
+	s.publicRoute("/auth/jwks.json", s.jwks()).Methods("GET")
+
+	// описание api и страница для его просмотра доступны без авторизации
+	s.publicRoute("/openapi.yaml", s.doc.serveYAML()).Methods("GET")
+	s.publicRoute("/openapi.json", s.doc.serveJSON()).Methods("GET")
+	s.publicRoute("/docs", serveDocsPage()).Methods("GET")
+
+	s.router.HandleFunc("/library/all", s.role(db.RoleReader, s.listLibrary())).Methods("GET")
+	s.router.HandleFunc("/library/text", s.role(db.RoleReader, s.showSongText())).Methods("GET")
+	s.router.HandleFunc("/library/search", s.role(db.RoleReader, s.searchLyrics())).Methods("GET")
+	s.router.HandleFunc("/library/suggest", s.role(db.RoleReader, s.suggest())).Methods("GET")
+	s.router.HandleFunc("/library/delete", s.role(db.RoleEditor, s.deleteSong())).Methods("DELETE")
+	s.router.HandleFunc("/library/add", s.role(db.RoleEditor, s.addSong())).Methods("POST")
+	s.router.HandleFunc("/library/update", s.role(db.RoleEditor, s.updateSong())).Methods("PATCH")
+
+	s.router.HandleFunc("/songs", s.role(db.RoleReader, s.listSongs())).Methods("GET")
+	s.router.HandleFunc("/songs/{id:[0-9]+}", s.role(db.RoleReader, s.getSong())).Methods("GET")
+	s.router.HandleFunc("/songs/{id:[0-9]+}", s.role(db.RoleEditor, s.replaceSong())).Methods("PUT")
+	s.router.HandleFunc("/songs/{id:[0-9]+}", s.role(db.RoleEditor, s.patchSong())).Methods("PATCH")
+	s.router.HandleFunc("/songs/{id:[0-9]+}", s.role(db.RoleEditor, s.deleteSongByID())).Methods("DELETE")
+	s.router.HandleFunc("/songs/{id:[0-9]+}/revisions", s.role(db.RoleReader, s.songRevisions())).Methods("GET")
+	s.router.HandleFunc("/songs/{id:[0-9]+}/revisions/{version:[0-9]+}", s.role(db.RoleReader, s.songRevision())).Methods("GET")
+	s.router.HandleFunc("/songs/{id:[0-9]+}/revisions/{version:[0-9]+}/restore", s.role(db.RoleEditor, s.restoreSong())).Methods("POST")
+	s.router.HandleFunc("/songs/{id:[0-9]+}/diff", s.role(db.RoleReader, s.diffSong())).Methods("GET")
+
+	s.router.HandleFunc("/trash", s.role(db.RoleReader, s.listTrash())).Methods("GET")
+	s.router.HandleFunc("/trash/{id:[0-9]+}/restore", s.role(db.RoleEditor, s.restoreTrashed())).Methods("POST")
+	s.router.HandleFunc("/trash/{id:[0-9]+}", s.role(db.RoleAdmin, s.purgeTrashed())).Methods("DELETE")
+
+	s.router.HandleFunc("/artists", s.role(db.RoleReader, s.listArtists())).Methods("GET")
+	s.router.HandleFunc("/artists", s.role(db.RoleEditor, s.addArtist())).Methods("POST")
+	s.router.HandleFunc("/artists/{id:[0-9]+}", s.role(db.RoleReader, s.getArtist())).Methods("GET")
+	s.router.HandleFunc("/artists/{id:[0-9]+}", s.role(db.RoleEditor, s.renameArtist())).Methods("PATCH")
+	s.router.HandleFunc("/artists/{id:[0-9]+}", s.role(db.RoleAdmin, s.deleteArtist())).Methods("DELETE")
+	s.router.HandleFunc("/artists/{id:[0-9]+}/merge", s.role(db.RoleAdmin, s.mergeArtists())).Methods("POST")
+	s.router.HandleFunc("/artists/{id:[0-9]+}/revisions", s.role(db.RoleReader, s.artistRevisions())).Methods("GET")
+	s.router.HandleFunc("/artists/{id:[0-9]+}/revisions/{version:[0-9]+}/restore", s.role(db.RoleEditor, s.restoreArtist())).Methods("POST")
+
+	s.router.HandleFunc("/albums", s.role(db.RoleReader, s.listAlbums())).Methods("GET")
+	s.router.HandleFunc("/albums", s.role(db.RoleEditor, s.addAlbum())).Methods("POST")
+	s.router.HandleFunc("/albums/{id:[0-9]+}", s.role(db.RoleReader, s.getAlbum())).Methods("GET")
+	s.router.HandleFunc("/albums/{id:[0-9]+}", s.role(db.RoleEditor, s.updateAlbum())).Methods("PUT")
+	s.router.HandleFunc("/albums/{id:[0-9]+}", s.role(db.RoleEditor, s.deleteAlbum())).Methods("DELETE")
+	s.router.HandleFunc("/albums/{id:[0-9]+}/tracks/{songId:[0-9]+}", s.role(db.RoleEditor, s.setTrack())).Methods("PUT")
+	s.router.HandleFunc("/albums/{id:[0-9]+}/tracks/{songId:[0-9]+}", s.role(db.RoleEditor, s.removeTrack())).Methods("DELETE")
+
+	s.router.HandleFunc("/tags", s.role(db.RoleReader, s.listTags())).Methods("GET")
+	s.router.HandleFunc("/tags", s.role(db.RoleEditor, s.addTag())).Methods("POST")
+	s.router.HandleFunc("/tags/{id:[0-9]+}", s.role(db.RoleReader, s.getTag())).Methods("GET")
+	s.router.HandleFunc("/tags/{id:[0-9]+}", s.role(db.RoleEditor, s.updateTag())).Methods("PUT")
+	s.router.HandleFunc("/tags/{id:[0-9]+}", s.role(db.RoleEditor, s.deleteTag())).Methods("DELETE")
+	s.router.HandleFunc("/songs/{id:[0-9]+}/tags", s.role(db.RoleReader, s.songTags())).Methods("GET")
+	s.router.HandleFunc("/songs/{id:[0-9]+}/tags/{tagId:[0-9]+}", s.role(db.RoleEditor, s.attachTag())).Methods("PUT")
+	s.router.HandleFunc("/songs/{id:[0-9]+}/tags/{tagId:[0-9]+}", s.role(db.RoleEditor, s.detachTag())).Methods("DELETE")
+
+	s.router.HandleFunc("/playlists", s.role(db.RoleReader, s.listPlaylists())).Methods("GET")
+	s.router.HandleFunc("/playlists", s.role(db.RoleEditor, s.addPlaylist())).Methods("POST")
+	s.router.HandleFunc("/playlists/{id:[0-9]+}", s.role(db.RoleReader, s.getPlaylist())).Methods("GET")
+	s.router.HandleFunc("/playlists/{id:[0-9]+}", s.role(db.RoleEditor, s.deletePlaylist())).Methods("DELETE")
+	s.router.HandleFunc("/playlists/{id:[0-9]+}/entries", s.role(db.RoleEditor, s.appendToPlaylist())).Methods("POST")
+	s.router.HandleFunc("/playlists/{id:[0-9]+}/entries/{entryId:[0-9]+}", s.role(db.RoleEditor, s.movePlaylistEntry())).Methods("PUT")
+	s.router.HandleFunc("/playlists/{id:[0-9]+}/entries/{entryId:[0-9]+}", s.role(db.RoleEditor, s.removePlaylistEntry())).Methods("DELETE")
+
+	s.router.HandleFunc("/smart-playlists", s.role(db.RoleReader, s.listSmartPlaylists())).Methods("GET")
+	s.router.HandleFunc("/smart-playlists", s.role(db.RoleEditor, s.addSmartPlaylist())).Methods("POST")
+	s.router.HandleFunc("/smart-playlists/{id:[0-9]+}", s.role(db.RoleReader, s.getSmartPlaylist())).Methods("GET")
+	s.router.HandleFunc("/smart-playlists/{id:[0-9]+}", s.role(db.RoleEditor, s.updateSmartPlaylist())).Methods("PUT")
+	s.router.HandleFunc("/smart-playlists/{id:[0-9]+}", s.role(db.RoleEditor, s.deleteSmartPlaylist())).Methods("DELETE")
+}
+
+// регистрирует маршрут, доступный без авторизации: authenticate не проверяет
+// для него ключ и токен, поэтому неверные учётные данные не мешают его получить
+func (s *APIServer) publicRoute(path string, handler http.HandlerFunc) *mux.Route {
+	route := s.router.HandleFunc(path, handler)
+	s.public[route] = true
+	return route
 }
 
 func (s *APIServer) configureDB() error {
-	slog.Debug("Database connection string: " + s.config.Database.ConnString())
-	database := db.New(s.config.Database)
-	err := database.Open()
+	slog.Debug("database driver", "driver", s.config.Database.Driver)
+	switch s.config.Database.Driver {
+	case db.DriverPostgres:
+		slog.Debug("Database connection string: " + s.config.Database.ConnString())
+	case db.DriverSQLite:
+		slog.Debug("Database file: " + s.config.Database.Path)
+	}
+	store, err := db.NewStore(s.config.Database)
+	if err != nil {
+		return err
+	}
+	err = store.Open()
 	if err != nil {
 		return err
 	}
 
-	s.database = database
+	switch {
+	case !s.config.AuthEnabled:
+		slog.Warn("authentication is disabled, the api is open to everyone")
+	case s.config.Database.Driver == db.DriverMemory:
+		// ключи выдаются через libadmin, а он не имеет доступа к памяти сервера
+		slog.Warn("api keys can't be minted for in-memory storage, every request will be rejected; set AUTH_ENABLED=false")
+	}
+
+	s.store = store
 	return nil
 }
 
@@ -102,71 +244,161 @@ func (s *APIServer) configureDB() error {
 // на их основе лист песен
 // если параметр не указан - фильтрация по нему не происходит.
 func (s *APIServer) listLibrary() http.HandlerFunc {
-	var lib db.Library
-	var filterParams db.Song
-	var offset, limit string
-	var err error
 	return func(writer http.ResponseWriter, request *http.Request) {
 		slog.Info("list library request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
 		writer.Header().Set("Content-type", "application/json")
 
-		filterParams.Group = request.FormValue("author")
-		filterParams.SongName = request.FormValue("song")
-		filterParams.ReleaseDate = request.FormValue("releaseDate")
-		filterParams.Text = request.FormValue("text")
-		filterParams.Link = request.FormValue("link")
-		offset = request.FormValue("offset")
-		limit = request.FormValue("limit")
+		var v validate.Validator
+		params := libraryFilter(request, &v)
+		page := pageParams(request, &params, &v)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
 
-		slog.Debug("filter parameters", "struct", filterParams, "offset", offset, "limit", limit)
+		slog.Debug("filter parameters", "struct", params)
 
-		lib, err = s.database.ListAllLibrary(filterParams, offset, limit)
+		if page.cursor {
+			s.songsPage(writer, request, params, page)
+			return
+		}
+		if page.count && !s.setTotalCount(writer, request, params) {
+			return
+		}
+
+		lib, err := s.store.ListAllLibrary(params)
 		if err != nil {
-			writer.WriteHeader(500)
 			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
 			return
 		}
 
 		if len(lib) == 0 {
-			writer.WriteHeader(404)
 			slog.Debug("song not found", "provided URL", request.URL)
+			writeProblem(writer, request, 404, codeNotFound, "", "no songs match the filters")
 			return
 		}
-		encoder := json.NewEncoder(writer)
-		encoder.Encode(lib)
+		writeList(writer, request, lib)
 	}
 }
 
-// удаление определенной песни
+// fields=group,song - поля песен в ответе, по умолчанию все, кроме текста.
+// Так же отдаются песни исполнителя, альбома и плейлиста
+func songFields(request *http.Request, v *validate.Validator) []string {
+	query := request.URL.Query()
+	if !query.Has("fields") {
+		return db.DefaultSongFields
+	}
+	fields, err := db.ParseFields(query.Get("fields"))
+	if err != nil {
+		v.Add("fields", validate.CodeBadValue, "%s", err.Error())
+	}
+	return fields
+}
+
+// параметры фильтрации и пагинации списка песен из квери запроса
+// (операторы фильтров field[op]=value - см. filterRule), ошибки добавляются в v
+func libraryFilter(request *http.Request, v *validate.Validator) db.ListParams {
+	var params db.ListParams
+	params.Filter.Group = request.FormValue("author")
+	params.Filter.SongName = request.FormValue("song")
+	params.Filter.ReleaseDate = request.FormValue("releaseDate")
+	params.Filter.Text = request.FormValue("text")
+	params.Filter.Link = request.FormValue("link")
+	params.Offset = request.FormValue("offset")
+	params.Limit = request.FormValue("limit")
+	v.Date("releaseDate", params.Filter.ReleaseDate)
+	checkPage(v, params.Offset, params.Limit)
+
+	// sort=-releaseDate,song - поля через запятую, "-" - по убыванию
+	params.Sort = request.FormValue("sort")
+	v.Check(db.ValidSort(params.Sort), "sort", validate.CodeBadValue, "bad sort %q", params.Sort)
+
+	params.Fields = songFields(request, v)
+
+	album, _ := v.Int("album", request.FormValue("album"), 1, 0)
+	params.Album = int64(album)
+
+	// tag=rock,live - песни с любым из тегов (tagMode=any) или со всеми сразу (tagMode=all)
+	if tags := request.FormValue("tag"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				params.Tags = append(params.Tags, tag)
+			}
+		}
+	}
+	params.TagMode = request.FormValue("tagMode")
+	if params.TagMode == "" {
+		params.TagMode = db.TagModeAny
+	}
+	v.OneOf("tagMode", params.TagMode, []string{db.TagModeAny, db.TagModeAll})
+
+	params.Rule = filterRule(request, v)
+
+	// fuzzy=true - author и song сравниваются нечётко, threshold - порог сходства от 0 до 1
+	if on, _ := v.Bool("fuzzy", request.FormValue("fuzzy")); on {
+		params.Similarity = db.DefaultSimilarity
+	}
+	if threshold := request.FormValue("threshold"); threshold != "" && !v.Has("fuzzy") {
+		var err error
+		if v.Check(params.Similarity > 0, "threshold", validate.CodeBadValue, "threshold requires fuzzy=true") {
+			params.Similarity, err = strconv.ParseFloat(threshold, 64)
+			v.Check(err == nil && params.Similarity > 0 && params.Similarity <= 1, "threshold", validate.CodeOutOfRange,
+				"bad threshold %q, must be in (0, 1]", threshold)
+		}
+	}
+	return params
+}
+
+// удаление определенной песни в корзину (см. trash.go)
 // если такая песня не была найдена - возвращаем 404
 // если она была найдена и удалена - 200
 func (s *APIServer) deleteSong() http.HandlerFunc {
-	var author, songName string
-
 	return func(writer http.ResponseWriter, request *http.Request) {
 		slog.Info("delete song request", "from", request.RemoteAddr,
 			"to", request.Host+request.URL.String())
 
-		author = request.FormValue("author")
-		songName = request.FormValue("song")
+		author := request.FormValue("author")
+		songName := request.FormValue("song")
 		slog.Debug("delete request", "author", author, "song", songName)
 
 		// необходимы оба поля author и song для точного определения песни,
 		// которую необходимо удалить
-		if author == "" || songName == "" {
-			slog.Error("bad request, author and/or name of the song weren't provided",
-				"request", request.Host+request.URL.String())
-			writer.WriteHeader(400)
+		var v validate.Validator
+		checkSongKey(&v, author, songName)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
 			return
 		}
 
-		dbresp, err := s.database.DeleteSong(author, songName)
+		// с If-Match песня удаляется по id с проверкой версии (см. etag.go)
+		if request.Header.Get("If-Match") != "" {
+			current, err := s.songByName(author, songName)
+			if err != nil {
+				slog.Error("error retrieving from db", "error", err.Error())
+				writeStoreError(writer, request, err)
+				return
+			}
+			version, ok := checkIfMatch(writer, request, current)
+			if !ok {
+				return
+			}
+			err = s.store.DeleteSongByID(current.ID, version, actor(request))
+			if err != nil {
+				slog.Error("error deleting from database", "error", err.Error())
+				writeStoreError(writer, request, err)
+			}
+			return
+		}
+
+		deleted, err := s.store.DeleteSong(author, songName, actor(request))
 		if err != nil {
 			slog.Error("error deleting from database", "error", err.Error())
-			writer.WriteHeader(500)
+			writeInternalError(writer, request)
+			return
 		}
-		if dbresp == "DELETE 0" {
-			writer.WriteHeader(404)
+		if deleted == 0 {
+			writeProblem(writer, request, 404, codeNotFound, "", "song not found")
 			return
 		}
 	}
@@ -174,56 +406,57 @@ func (s *APIServer) deleteSong() http.HandlerFunc {
 
 // вывод текста определенной песни, с возможностью выбора куплета
 func (s *APIServer) showSongText() http.HandlerFunc {
-	var author, song, text, verse string
-	var err error
-
 	return func(writer http.ResponseWriter, request *http.Request) {
 		slog.Info("song text request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
-		author = request.FormValue("author")
-		song = request.FormValue("song")
-		verse = request.FormValue("verse")
+		author := request.FormValue("author")
+		song := request.FormValue("song")
+		verse := request.FormValue("verse")
 
 		slog.Debug("", "author", author, "song", song, "verse", verse)
 
 		// необходимы оба поля author и song для точного определения песни,
-		// текст которой необходимо показать
-		if author == "" || song == "" {
-			slog.Error("bad request, author and/or name of the song weren't provided",
-				"request", request.Host+request.URL.String())
-			writer.WriteHeader(400)
+		// текст которой необходимо показать; куплет - неотрицательное число,
+		// его верхняя граница проверяется по тексту песни
+		var v validate.Validator
+		checkSongKey(&v, author, song)
+		verseInt, _ := v.Int("verse", verse, 0, 0)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
 			return
 		}
 
 		// подразумеваем, что куплеты песни разделены между собой
 		// одной пустой строкой
-		text, err = s.database.GetSongText(author, song)
+		found, err := s.songByName(author, song)
+		text := found.Text
 		slog.Debug("", "text", text)
+		if errors.Is(err, db.ErrNotFound) {
+			slog.Debug("song not found", "provided URL", request.URL)
+			writeProblem(writer, request, 404, codeNotFound, "", "song not found")
+			return
+		}
 		if err != nil {
 			slog.Error("error retrieving from db", "error", err.Error())
-			writer.WriteHeader(500)
+			writeInternalError(writer, request)
 			return
 		}
 		tmp := strings.Split(text, "\n\n")
 
 		// если параметр verse не указан (или указан как 0) - выводим весь текст
 		// в другом случае выводим указанный куплет (1 = первый куплет, и т.д.)
-		// отрицательное значение куплета, а также значение, превышающее кол-во
-		// куплетов в песне = bad request
-		if verse == "" {
-			fmt.Fprint(writer, text)
-			return
-		}
-		verseInt, err := strconv.Atoi(verse)
-		if err != nil {
-			slog.Error(err.Error())
-			writer.WriteHeader(400)
+		// значение, превышающее кол-во куплетов в песне = bad request
+		if verseInt > len(tmp) {
+			v.Add("verse", validate.CodeOutOfRange, "verse %d is out of range, the song has %d verses", verseInt, len(tmp))
+			writeInvalid(writer, request, v.Err())
 			return
 		}
-		if verseInt > len(tmp) || verseInt < 0 {
-			slog.Error("bad request", "verse", verseInt)
-			writer.WriteHeader(400)
+		// текст, как и песня целиком, отдаётся с ETag - версией песни
+		etag := songETag(found)
+		writer.Header().Set("ETag", etag)
+		if notModified(writer, request, etag) {
 			return
 		}
+		writer.Header().Set("Content-type", "text/plain; charset=utf-8")
 		if verseInt == 0 {
 			fmt.Fprint(writer, text)
 		} else {
@@ -234,33 +467,39 @@ func (s *APIServer) showSongText() http.HandlerFunc {
 
 // запрос на добавление песни в базу данных
 func (s *APIServer) addSong() http.HandlerFunc {
-	var song db.Song
-	var body []byte
-	var err error
-	var reqURL string
-	var resp *http.Response
 	externalURL := os.Getenv("EXTERNAL_API_URL")
 
 	return func(writer http.ResponseWriter, request *http.Request) {
+		var song db.Song
+		var resp *http.Response
 		defer request.Body.Close()
 		slog.Info("add song request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
-		body, err = io.ReadAll(request.Body)
+		body, err := io.ReadAll(request.Body)
 		if err != nil {
 			slog.Error("error reading request body", "error", err.Error())
-			writer.WriteHeader(400)
+			writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
 			return
 		}
 
 		err = json.Unmarshal(body, &song)
 		if err != nil {
-			writer.WriteHeader(400)
 			slog.Error("error unmarshalling", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadBody, "", err.Error())
 			return
 		}
 		slog.Debug("request body", "struct", song)
 
+		// остальные поля заполняет внешний api, от клиента нужны только имена
+		var v validate.Validator
+		v.Name("group", song.Group)
+		v.Name("song", song.SongName)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
 		// формируем запрос во внешний АПИ для получения данных о песне
-		reqURL = fmt.Sprintf("%s?group=%s&song=%s", externalURL, song.Group, song.SongName)
+		reqURL := externalURL + "?" + url.Values{"group": {song.Group}, "song": {song.SongName}}.Encode()
 		slog.Debug("accessing external api", "URL", reqURL)
 		timer := time.Second
 
@@ -271,17 +510,15 @@ func (s *APIServer) addSong() http.HandlerFunc {
 		for range 5 {
 			resp, err = http.Get(reqURL)
 			if err != nil {
-				writer.WriteHeader(500)
 				slog.Error("http.get error", "error", err.Error())
-				fmt.Fprint(writer, "error trying to access external api: "+err.Error())
-				resp.Body.Close()
+				writeProblem(writer, request, 500, codeExternalAPI, "", "error trying to access external api")
 				return
 			}
 			defer resp.Body.Close()
 			switch resp.StatusCode {
 			case 400:
 				slog.Error("received code 400, bad request")
-				writer.WriteHeader(400)
+				writeProblem(writer, request, 400, codeBadParameter, "", "external api rejected the group or song name")
 				return
 			case 500:
 				slog.Debug("received code 500, trying to get " + reqURL + " again")
@@ -291,8 +528,9 @@ func (s *APIServer) addSong() http.HandlerFunc {
 				break outer
 			default:
 				// исходя из ТЗ мы никогда не должны сюда попасть
-				writer.WriteHeader(resp.StatusCode)
 				slog.Error("got unsupported response code", "code", resp.StatusCode)
+				writeProblem(writer, request, 500, codeExternalAPI, "",
+					fmt.Sprintf("external api returned unexpected status %d", resp.StatusCode))
 				return
 			}
 		}
@@ -303,26 +541,33 @@ func (s *APIServer) addSong() http.HandlerFunc {
 
 		if resp.StatusCode == 500 {
 			slog.Error("external api is not working")
-			writer.WriteHeader(500)
-			fmt.Fprint(writer, "external api is not working")
+			writeProblem(writer, request, 500, codeExternalAPI, "", "external api is not working")
 			return
 		}
 
 		data, err := io.ReadAll(resp.Body)
 		if err != nil {
 			slog.Error("error reading external api response body", "error", err.Error())
+			writeProblem(writer, request, 500, codeExternalAPI, "", "error reading external api response")
 			return
 		}
 		err = json.Unmarshal(data, &song)
 		if err != nil {
 			slog.Error("error unmarshalling", "error", err.Error())
+			writeProblem(writer, request, 500, codeExternalAPI, "", "external api returned malformed song details")
+			return
+		}
+		checkSong(&v, song, false)
+		if err := v.Err(); err != nil {
+			slog.Error("external api returned invalid song details", "error", err.Error())
+			writeProblem(writer, request, 500, codeExternalAPI, "", "external api returned invalid song details: "+err.Error())
 			return
 		}
 		slog.Debug("adding song to database", "song struct", song)
-		err = s.database.AddSong(song)
+		err = s.store.AddSong(song)
 		if err != nil {
 			slog.Error("error adding to the database", "error", err.Error())
-			writer.WriteHeader(500)
+			writeStoreError(writer, request, err)
 		}
 	}
 }
@@ -334,56 +579,61 @@ func (s *APIServer) addSong() http.HandlerFunc {
 // в любом другом случае в квери также необходимо указать название песни
 // и будут обновляться данные песни
 func (s *APIServer) updateSong() http.HandlerFunc {
-	// стандартные значения установлени на no_data для реализации возможности удаления каких-либо
-	// данных о песне (другими словами - заменой их на пустую строку)
-	song := db.Song{
-		Group:       "no_data",
-		SongName:    "no_data",
-		ReleaseDate: "no_data",
-		Text:        "no_data",
-		Link:        "no_data",
-	}
-	var body []byte
-	var err error
-
 	return func(writer http.ResponseWriter, request *http.Request) {
+		// стандартные значения установлени на no_data для реализации возможности удаления каких-либо
+		// данных о песне (другими словами - заменой их на пустую строку)
+		song := db.Song{
+			Group:       "no_data",
+			SongName:    "no_data",
+			ReleaseDate: "no_data",
+			Text:        "no_data",
+			Link:        "no_data",
+		}
 		defer request.Body.Close()
 		slog.Info("update song request", "from", request.RemoteAddr,
 			"to", request.Host+request.URL.String())
 		author := request.FormValue("author")
 		songname := request.FormValue("song")
 
-		if author == "" {
-			slog.Error("bad request, author wasn't provided",
-				"request", request.Host+request.URL.String())
-			writer.WriteHeader(400)
-			return
-		}
-
 		slog.Debug("update", "author", author, "song name", songname)
 
-		body, err = io.ReadAll(request.Body)
+		body, err := io.ReadAll(request.Body)
 		if err != nil {
 			slog.Error("error reading request body", "error", err.Error())
-			writer.WriteHeader(400)
+			writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
 			return
 		}
 
 		err = json.Unmarshal(body, &song)
 		if err != nil {
 			slog.Error("unmarshal error", "error", err.Error())
-			writer.WriteHeader(400)
+			writeProblem(writer, request, 400, codeBadBody, "", err.Error())
 			return
 		}
 
 		slog.Debug("", "update song data", song)
 
+		var v validate.Validator
+		v.Required("author", author)
+		checkSong(&v, song, true)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
 		// проверяем если в теле находятся только данные об имени исполнителя,
 		// а также что в квери указан только автор
 		if songname == "" && song.Group != "no_data" && song.SongName == "no_data" && song.Link == "no_data" && song.ReleaseDate == "no_data" && song.Text == "no_data" {
-			err = s.database.UpdateGroupName(author, song)
+			// у исполнителя нет своего ETag, сравнить If-Match не с чем
+			if h := strings.TrimSpace(request.Header.Get("If-Match")); h != "" && h != "*" {
+				writeProblem(writer, request, 412, codePreconditionFailed, "If-Match",
+					"If-Match is only supported for song updates, not for renaming an artist")
+				return
+			}
+			err = s.store.UpdateGroupName(author, song, actor(request))
 			if err != nil {
 				slog.Error("error updating author's name", "err", err.Error())
+				writeStoreError(writer, request, err)
 				return
 			}
 			return
@@ -391,18 +641,38 @@ func (s *APIServer) updateSong() http.HandlerFunc {
 
 		// на этом этапе требуем название песни, т.к. будут меняться её данные
 		// и необходимо знать в какой песне их менять
-		if songname == "" {
-			slog.Error("bad request, song name wasn't provided",
-				"request", request.Host+request.URL.String())
-			writer.WriteHeader(400)
+		if !v.Check(songname != "", "song", validate.CodeRequired, "song is required to update song details") {
+			writeInvalid(writer, request, v.Err())
 			return
 		}
 
-		err = s.database.UpdateSongDetails(author, songname, song)
+		// версия из тела не учитывается, её задаёт If-Match (см. etag.go)
+		song.Version = 0
+		if request.Header.Get("If-Match") != "" {
+			current, err := s.songByName(author, songname)
+			if err != nil {
+				slog.Error("error retrieving from db", "error", err.Error())
+				writeStoreError(writer, request, err)
+				return
+			}
+			var ok bool
+			song.Version, ok = checkIfMatch(writer, request, current)
+			if !ok {
+				return
+			}
+		}
+
+		err = s.store.UpdateSongDetails(author, songname, song, actor(request))
 		if err != nil {
 			slog.Error("updating song details error", "error", err.Error())
-			writer.WriteHeader(500)
+			writeStoreError(writer, request, err)
 			return
 		}
 	}
 }
+
+// исполнитель и название песни, обязательные для поиска песни в /library/*
+func checkSongKey(v *validate.Validator, author, song string) {
+	v.Required("author", author)
+	v.Required("song", song)
+}
@@ -0,0 +1,309 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// текст песни из внешнего api: три куплета
+const externalText = "First verse\nline two\n\nSecond verse\n\nThird verse"
+
+// внешний api с данными песен (external_api_swagger.yaml): для группы Unknown
+// отвечает 400, для остальных - одинаковыми данными песни
+func newExternalAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Query().Get("group") == "Unknown" || request.URL.Query().Get("song") == "" {
+			writer.WriteHeader(400)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(db.Song{
+			ReleaseDate: "16.07.2006",
+			Text:        externalText,
+			Link:        "https://example.com/" + request.URL.Query().Get("song"),
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// сервер с хранилищем в памяти и песнями songs (исполнитель и название через "/")
+func newLibraryServer(t *testing.T, songs ...string) *httptest.Server {
+	t.Helper()
+	t.Setenv("EXTERNAL_API_URL", newExternalAPI(t).URL)
+	ts := newTestServer(t, testConfig())
+	for _, song := range songs {
+		group, name, _ := strings.Cut(song, "/")
+		body, _ := json.Marshal(db.Song{Group: group, SongName: name})
+		status, resp := call(t, ts, "POST", "/library/add", string(body), nil)
+		if status != 200 {
+			t.Fatalf("add %s: status %d, body: %s", song, status, resp)
+		}
+	}
+	return ts
+}
+
+// запрос к серверу, возвращает код и тело ответа
+func call(t *testing.T, ts *httptest.Server, method, path, body string, header http.Header) (int, string) {
+	t.Helper()
+	request, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// список песен /library/all: названия по порядку
+func libraryNames(t *testing.T, ts *httptest.Server, query string) []string {
+	t.Helper()
+	status, body := call(t, ts, "GET", "/library/all"+query, "", nil)
+	if status != 200 {
+		t.Fatalf("GET /library/all%s: status %d, body: %s", query, status, body)
+	}
+	var lib db.Library
+	err := json.Unmarshal([]byte(body), &lib)
+	if err != nil {
+		t.Fatalf("decode library: %v", err)
+	}
+	names := make([]string, len(lib))
+	for i, song := range lib {
+		names[i] = song.SongName
+	}
+	return names
+}
+
+// код ответа и, для ошибок, код problem+json
+type wantStatus struct {
+	status int
+	code   string
+}
+
+func checkStatus(t *testing.T, status int, body string, want wantStatus) {
+	t.Helper()
+	if status != want.status {
+		t.Fatalf("status = %d, want %d, body: %s", status, want.status, body)
+	}
+	if want.code == "" {
+		return
+	}
+	var problem struct {
+		Code string `json:"code"`
+	}
+	err := json.Unmarshal([]byte(body), &problem)
+	if err != nil || problem.Code != want.code {
+		t.Errorf("problem code = %q (%v), want %q, body: %s", problem.Code, err, want.code, body)
+	}
+}
+
+func TestLibraryList(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria", "Queen/Innuendo")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Hysteria", "Uprising", "Innuendo"}},
+		{"?author=Queen", []string{"Innuendo"}},
+		{"?author=Muse&song=Uprising", []string{"Uprising"}},
+		{"?releaseDate=16.07.2006&limit=1", []string{"Hysteria"}},
+		{"?offset=1&limit=1", []string{"Uprising"}},
+		{"?offset=2", []string{"Innuendo"}},
+		{"?sort=-song", []string{"Uprising", "Innuendo", "Hysteria"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := libraryNames(t, ts, tt.query)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("songs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	errors := []struct {
+		query string
+		want  wantStatus
+	}{
+		{"?offset=3", wantStatus{404, codeNotFound}},
+		{"?author=Nobody", wantStatus{404, codeNotFound}},
+		{"?limit=-1", wantStatus{400, codeValidation}},
+		{"?offset=x", wantStatus{400, codeValidation}},
+		{"?releaseDate=32.13.2006", wantStatus{400, codeValidation}},
+		{"?sort=year", wantStatus{400, codeValidation}},
+	}
+	for _, tt := range errors {
+		t.Run(tt.query, func(t *testing.T) {
+			status, body := call(t, ts, "GET", "/library/all"+tt.query, "", nil)
+			checkStatus(t, status, body, tt.want)
+		})
+	}
+}
+
+func TestLibraryAdd(t *testing.T) {
+	ts := newLibraryServer(t)
+
+	// данные песни, кроме имён, приходят из внешнего api
+	status, body := call(t, ts, "POST", "/library/add", `{"group":"Queen","song":"Bohemian Rhapsody"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	status, body = call(t, ts, "GET", "/library/all?author=Queen&fields=group,song,releaseDate,link", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	want := `[{"group":"Queen","song":"Bohemian Rhapsody","releaseDate":"2006-07-16","link":"https://example.com/Bohemian Rhapsody"}]`
+	if strings.TrimSpace(body) != want {
+		t.Errorf("library = %s, want %s", body, want)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want wantStatus
+	}{
+		{"duplicate", `{"group":"Queen","song":"Bohemian Rhapsody"}`, wantStatus{409, codeAlreadyExists}},
+		{"no song", `{"group":"Queen"}`, wantStatus{400, codeValidation}},
+		{"not json", `{"group":`, wantStatus{400, ""}},
+		{"rejected by external api", `{"group":"Unknown","song":"Song"}`, wantStatus{400, codeBadParameter}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(t, ts, "POST", "/library/add", tt.body, nil)
+			checkStatus(t, status, body, tt.want)
+		})
+	}
+}
+
+func TestLibraryUpdate(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria")
+
+	status, body := call(t, ts, "PATCH", "/library/update?author=Muse&song=Uprising",
+		`{"text":"New text","releaseDate":"07.09.2009"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	status, body = call(t, ts, "GET", "/library/text?author=Muse&song=Uprising", "", nil)
+	if status != 200 || body != "New text" {
+		t.Errorf("text after update: %d %q, want 200 %q", status, body, "New text")
+	}
+	if got := libraryNames(t, ts, "?releaseDate=2009-09-07"); strings.Join(got, ",") != "Uprising" {
+		t.Errorf("songs released 2009-09-07 = %q, want Uprising", got)
+	}
+
+	// только исполнитель - переименование исполнителя со всеми песнями
+	status, body = call(t, ts, "PATCH", "/library/update?author=Muse", `{"group":"MUSE"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	if got := libraryNames(t, ts, "?author=MUSE"); strings.Join(got, ",") != "Hysteria,Uprising" {
+		t.Errorf("songs of MUSE = %q, want Hysteria and Uprising", got)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		header http.Header
+		want   wantStatus
+	}{
+		{"missing song", "?author=MUSE&song=Missing", `{"text":"x"}`, nil, wantStatus{404, codeNotFound}},
+		{"missing artist", "?author=Nobody", `{"group":"Somebody"}`, nil, wantStatus{404, codeNotFound}},
+		{"no author", "?song=Uprising", `{"text":"x"}`, nil, wantStatus{400, codeValidation}},
+		{"no song", "?author=MUSE", `{"text":"x"}`, nil, wantStatus{400, codeValidation}},
+		{"bad date", "?author=MUSE&song=Uprising", `{"releaseDate":"2009"}`, nil, wantStatus{400, codeValidation}},
+		{"not json", "?author=MUSE&song=Uprising", `{"text":`, nil, wantStatus{400, ""}},
+		{"stale version", "?author=MUSE&song=Uprising", `{"text":"x"}`, http.Header{"If-Match": {`"1"`}},
+			wantStatus{412, codePreconditionFailed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(t, ts, "PATCH", "/library/update"+tt.query, tt.body, tt.header)
+			checkStatus(t, status, body, tt.want)
+		})
+	}
+}
+
+func TestLibraryDelete(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria")
+
+	status, body := call(t, ts, "DELETE", "/library/delete?author=Muse&song=Uprising", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	if got := libraryNames(t, ts, ""); strings.Join(got, ",") != "Hysteria" {
+		t.Errorf("songs after delete = %q, want Hysteria", got)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+		want   wantStatus
+	}{
+		{"deleted song", "?author=Muse&song=Uprising", nil, wantStatus{404, codeNotFound}},
+		{"missing artist", "?author=Nobody&song=Hysteria", nil, wantStatus{404, codeNotFound}},
+		{"no song", "?author=Muse", nil, wantStatus{400, codeValidation}},
+		{"no author", "?song=Hysteria", nil, wantStatus{400, codeValidation}},
+		{"stale version", "?author=Muse&song=Hysteria", http.Header{"If-Match": {`"2"`}},
+			wantStatus{412, codePreconditionFailed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(t, ts, "DELETE", "/library/delete"+tt.query, "", tt.header)
+			checkStatus(t, status, body, tt.want)
+		})
+	}
+}
+
+func TestLibraryText(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising")
+
+	verses := []struct {
+		verse string
+		want  string
+	}{
+		{"", externalText},
+		{"0", externalText},
+		{"1", "First verse\nline two"},
+		{"3", "Third verse"},
+	}
+	for _, tt := range verses {
+		t.Run("verse "+tt.verse, func(t *testing.T) {
+			query := "?author=Muse&song=Uprising"
+			if tt.verse != "" {
+				query += "&verse=" + tt.verse
+			}
+			status, body := call(t, ts, "GET", "/library/text"+query, "", nil)
+			if status != 200 || body != tt.want {
+				t.Errorf("text = %d %q, want 200 %q", status, body, tt.want)
+			}
+		})
+	}
+
+	errors := []struct {
+		query string
+		want  wantStatus
+	}{
+		{"?author=Muse&song=Uprising&verse=4", wantStatus{400, codeValidation}},
+		{"?author=Muse&song=Uprising&verse=-1", wantStatus{400, codeValidation}},
+		{"?author=Muse&song=Uprising&verse=x", wantStatus{400, codeValidation}},
+		{"?author=Muse", wantStatus{400, codeValidation}},
+		{"?author=Muse&song=Missing", wantStatus{404, codeNotFound}},
+	}
+	for _, tt := range errors {
+		t.Run(tt.query, func(t *testing.T) {
+			status, body := call(t, ts, "GET", "/library/text"+tt.query, "", nil)
+			checkStatus(t, status, body, tt.want)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// тело запросов на создание и переименование исполнителя
+type artistRequest struct {
+	Name string `json:"name"`
+}
+
+// список исполнителей с количеством песен у каждого
+func (s *APIServer) listArtists() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("list artists request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		offset, limit := request.FormValue("offset"), request.FormValue("limit")
+		checkPage(&v, offset, limit)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		artists, err := s.store.ListArtists(offset, limit)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeJSON(writer, 200, artists)
+	}
+}
+
+// исполнитель вместе с его песнями
+func (s *APIServer) getArtist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("get artist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad artist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad artist id")
+			return
+		}
+		var v validate.Validator
+		fields := songFields(request, &v)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		artist, err := s.store.GetArtist(int(id))
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		for i := range artist.Songs {
+			artist.Songs[i] = artist.Songs[i].Only(fields)
+		}
+		writeJSON(writer, 200, artist)
+	}
+}
+
+func (s *APIServer) addArtist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("add artist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		name, ok := readArtistName(writer, request)
+		if !ok {
+			return
+		}
+
+		artist, err := s.store.AddArtist(name)
+		if err != nil {
+			slog.Error("error adding artist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		writeJSON(writer, 201, artist)
+	}
+}
+
+func (s *APIServer) renameArtist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("rename artist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad artist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad artist id")
+			return
+		}
+
+		name, ok := readArtistName(writer, request)
+		if !ok {
+			return
+		}
+
+		err = s.store.RenameArtist(int(id), name, actor(request))
+		if err != nil {
+			slog.Error("error renaming artist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		artist, err := s.store.GetArtist(int(id))
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeJSON(writer, 200, artist)
+	}
+}
+
+// удаление исполнителя
+// если у исполнителя остались песни - 409, если только не указан cascade=true,
+// в этом случае песни переносятся в корзину
+func (s *APIServer) deleteArtist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("delete artist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad artist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad artist id")
+			return
+		}
+
+		var v validate.Validator
+		cascade, _ := v.Bool("cascade", request.FormValue("cascade"))
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		err = s.store.DeleteArtist(int(id), cascade, actor(request))
+		if err != nil {
+			slog.Error("error deleting artist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// тело запроса на объединение исполнителей
+type mergeRequest struct {
+	Sources []int          `json:"sources"`
+	Policy  db.MergePolicy `json:"policy"`
+}
+
+// объединение дубликатов исполнителя: все песни исполнителей из sources
+// переходят к исполнителю из пути, их имена остаются его псевдонимами
+// policy определяет, что делать с одноимёнными песнями (по умолчанию - fail, 409)
+func (s *APIServer) mergeArtists() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("merge artists request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad artist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad artist id")
+			return
+		}
+
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			slog.Error("error reading request body", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
+			return
+		}
+
+		req := mergeRequest{Policy: db.MergeFail}
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			slog.Error("unmarshal error", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadBody, "", err.Error())
+			return
+		}
+		var v validate.Validator
+		v.Check(len(req.Sources) > 0, "sources", validate.CodeRequired, "no artists to merge")
+		v.Check(req.Policy.Valid(), "policy", validate.CodeBadValue, "unknown merge policy %q", req.Policy)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		result, err := s.store.MergeArtists(int(id), req.Sources, req.Policy, actor(request))
+		if err != nil {
+			slog.Error("error merging artists", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		slog.Info("artists merged", "target", id, "sources", req.Sources, "moved", result.Moved, "dropped", result.Dropped)
+		writeJSON(writer, 200, result)
+	}
+}
+
+// читает имя исполнителя из тела запроса, при ошибке сам отвечает 400
+func readArtistName(writer http.ResponseWriter, request *http.Request) (string, bool) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		slog.Error("error reading request body", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
+		return "", false
+	}
+
+	var req artistRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		slog.Error("unmarshal error", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", err.Error())
+		return "", false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	var v validate.Validator
+	if !v.Name("name", req.Name) {
+		writeInvalid(writer, request, v.Err())
+		return "", false
+	}
+	return req.Name, true
+}
@@ -0,0 +1,104 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"encoding/json"
+	"testing"
+)
+
+// песни исполнителя, как и список песен, по умолчанию отдаются без текста
+func TestGetArtistSongFields(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria")
+
+	tests := []struct {
+		query string
+		want  db.Song
+	}{
+		{"", db.Song{ID: 2, Group: "Muse", SongName: "Hysteria", ReleaseDate: "2006-07-16",
+			Link: "https://example.com/Hysteria", Version: 1}},
+		{"?fields=song,text", db.Song{SongName: "Hysteria", Text: externalText}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			status, body := call(t, ts, "GET", "/artists/1"+tt.query, "", nil)
+			checkStatus(t, status, body, wantStatus{status: 200})
+			var artist db.Artist
+			err := json.Unmarshal([]byte(body), &artist)
+			if err != nil {
+				t.Fatalf("decode artist: %v", err)
+			}
+			if artist.SongCount != 2 || len(artist.Songs) != 2 {
+				t.Fatalf("artist = %+v, want 2 songs", artist)
+			}
+			if artist.Songs[0] != tt.want {
+				t.Errorf("first song = %+v, want %+v", artist.Songs[0], tt.want)
+			}
+		})
+	}
+
+	status, body := call(t, ts, "GET", "/artists/1?fields=lyrics", "", nil)
+	checkStatus(t, status, body, wantStatus{400, codeValidation})
+	status, body = call(t, ts, "GET", "/artists/5", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+}
+
+func TestMergeArtists(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "muse/Uprising", "muse/Hysteria", "MUSE/Starlight", "Queen/Innuendo")
+
+	steps := []struct {
+		method, path, body string
+		want               wantStatus
+	}{
+		{"POST", "/artists/1/merge", `{}`, wantStatus{400, codeValidation}},
+		{"POST", "/artists/1/merge", `{"sources":[2],"policy":"keep_oldest"}`, wantStatus{400, codeValidation}},
+		{"POST", "/artists/1/merge", `{"sources":`, wantStatus{400, codeValidation}},
+		{"POST", "/artists/1/merge", `{"sources":[9]}`, wantStatus{404, codeNotFound}},
+		{"POST", "/artists/9/merge", `{"sources":[2]}`, wantStatus{404, codeNotFound}},
+		// по умолчанию одноимённые песни отменяют объединение целиком
+		{"POST", "/artists/1/merge", `{"sources":[3,2]}`, wantStatus{409, codeConflict}},
+		{"GET", "/artists/3", "", wantStatus{status: 200}},
+	}
+	for _, step := range steps {
+		status, body := call(t, ts, step.method, step.path, step.body, nil)
+		t.Run(step.method+" "+step.path, func(t *testing.T) {
+			checkStatus(t, status, body, step.want)
+		})
+	}
+
+	status, body := call(t, ts, "POST", "/artists/1/merge", `{"sources":[2,3],"policy":"keep_newest"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var result db.MergeResult
+	err := json.Unmarshal([]byte(body), &result)
+	if err != nil {
+		t.Fatalf("decode merge result: %v", err)
+	}
+	if result.Target.Name != "Muse" || result.Moved != 3 || len(result.Dropped) != 1 || result.Dropped[0] != 1 {
+		t.Errorf("merge result = %+v, want 3 songs moved to Muse and song 1 dropped", result)
+	}
+	status, body = call(t, ts, "GET", "/artists/2", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+
+	// перенесённая песня - новая версия с новым исполнителем
+	status, body = call(t, ts, "GET", "/songs/3", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var song db.Song
+	json.Unmarshal([]byte(body), &song)
+	if song.Group != "Muse" || song.Version != 2 {
+		t.Errorf("moved song = %+v, want Muse version 2", song)
+	}
+
+	// прежние имена остаются псевдонимами исполнителя
+	if got := libraryNames(t, ts, "?author=muse&sort=song"); len(got) != 3 ||
+		got[0] != "Hysteria" || got[1] != "Starlight" || got[2] != "Uprising" {
+		t.Errorf("songs of muse after merge = %q", got)
+	}
+	status, body = call(t, ts, "POST", "/library/add", `{"group":"MUSE","song":"Resistance"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	status, body = call(t, ts, "GET", "/artists/1", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var artist db.Artist
+	json.Unmarshal([]byte(body), &artist)
+	if artist.SongCount != 4 {
+		t.Errorf("Muse after merge and add = %+v, want 4 songs", artist)
+	}
+}
@@ -0,0 +1,235 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// заголовок с ключом доступа
+const apiKeyHeader = "X-API-Key"
+
+type contextKey int
+
+const (
+	identityKey contextKey = iota
+	requestIDKey
+)
+
+// владелец запроса, определённый по ключу доступа или токену
+type identity struct {
+	Name string
+	Role db.Role
+	// id ключа доступа, при доступе по токену - ключа, по которому он выдан
+	keyID int64
+	// id и срок действия токена, пустые при доступе по ключу
+	tokenID string
+	expires time.Time
+}
+
+// ответ на запрос токена
+type tokenResponse struct {
+	AccessToken string `json:"accessToken"`
+	TokenType   string `json:"tokenType"`
+	// срок действия в секундах
+	ExpiresIn int `json:"expiresIn"`
+}
+
+// ошибка проверки ключа или токена, текст ошибки отправляется клиенту
+type authError string
+
+func (e authError) Error() string {
+	return string(e)
+}
+
+// middleware, определяющий владельца запроса по ключу из заголовка X-API-Key
+// или по токену из заголовка Authorization: Bearer
+// запрос без них передаётся дальше анонимным, доступ к маршруту проверяет role.
+// Публичные маршруты (publicRoute) передаются дальше без проверки
+func (s *APIServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !s.config.AuthEnabled || s.public[mux.CurrentRoute(request)] {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		var id identity
+		var err error
+		scheme, token, _ := strings.Cut(request.Header.Get("Authorization"), " ")
+		switch key := request.Header.Get(apiKeyHeader); {
+		case key != "":
+			id, err = s.keyIdentity(key)
+		case strings.EqualFold(scheme, "Bearer"):
+			id, err = s.tokenIdentity(strings.TrimSpace(token))
+		default:
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		var authErr authError
+		if errors.As(err, &authErr) {
+			slog.Warn("authentication failed", "error", err.Error(),
+				"from", request.RemoteAddr, "to", request.Host+request.URL.String())
+			unauthorized(writer, request, authErr.Error())
+			return
+		}
+		if err != nil {
+			slog.Error("error checking credentials", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		ctx := context.WithValue(request.Context(), identityKey, id)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+func (s *APIServer) keyIdentity(key string) (identity, error) {
+	k, err := s.store.FindAPIKey(db.HashAPIKey(key))
+	if errors.Is(err, db.ErrNotFound) {
+		return identity{}, authError("invalid or revoked api key")
+	}
+	if err != nil {
+		return identity{}, err
+	}
+	return identity{Name: k.Name, Role: k.Role, keyID: k.ID}, nil
+}
+
+func (s *APIServer) tokenIdentity(token string) (identity, error) {
+	if s.keys == nil {
+		return identity{}, authError("bearer tokens are not accepted")
+	}
+	claims, err := s.keys.verify(token)
+	if err != nil {
+		slog.Debug("token verification failed", "error", err.Error())
+		return identity{}, authError("invalid or expired token")
+	}
+
+	revoked, err := s.store.TokenRevoked(claims.ID, claims.KeyID)
+	if err != nil {
+		return identity{}, err
+	}
+	if revoked {
+		return identity{}, authError("token has been revoked")
+	}
+	return identity{
+		Name:    claims.Subject,
+		Role:    claims.Role,
+		keyID:   claims.KeyID,
+		tokenID: claims.ID,
+		expires: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// обработчик next, доступный только с ролью не ниже required
+func (s *APIServer) role(required db.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if !s.config.AuthEnabled {
+			next(writer, request)
+			return
+		}
+
+		id, ok := request.Context().Value(identityKey).(identity)
+		if !ok {
+			unauthorized(writer, request, "api key or bearer token required")
+			return
+		}
+		if !id.Role.Allows(required) {
+			slog.Warn("access denied", "name", id.Name, "role", id.Role, "required", required,
+				"to", request.Host+request.URL.String())
+			writeProblem(writer, request, 403, codeForbidden, "", "role "+string(required)+" required")
+			return
+		}
+		next(writer, request)
+	}
+}
+
+// кто выполняет запрос - для истории изменений: имя ключа доступа (или ключа,
+// по которому выдан токен), при выключенной проверке ключей - anonymous
+func actor(request *http.Request) string {
+	id, ok := request.Context().Value(identityKey).(identity)
+	if !ok {
+		return "anonymous"
+	}
+	return id.Name
+}
+
+// выдача токена владельцу ключа доступа с ролью этого ключа
+// по токену новый токен не выдаётся, иначе его срок действия можно продлевать бесконечно
+func (s *APIServer) issueToken() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("issue token request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		if s.keys == nil {
+			writeProblem(writer, request, 404, codeNotFound, "", "bearer tokens are not configured")
+			return
+		}
+		id, ok := request.Context().Value(identityKey).(identity)
+		if !ok {
+			unauthorized(writer, request, "api key required")
+			return
+		}
+		if id.tokenID != "" {
+			writeProblem(writer, request, 403, codeForbidden, "", "tokens are issued only for api keys")
+			return
+		}
+
+		token, claims, err := s.keys.issue(id)
+		if err != nil {
+			slog.Error("error signing token", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+		slog.Debug("token issued", "name", id.Name, "jti", claims.ID)
+
+		writer.Header().Set("Cache-Control", "no-store")
+		writeJSON(writer, 200, tokenResponse{
+			AccessToken: token,
+			TokenType:   "Bearer",
+			ExpiresIn:   int(s.keys.ttl.Seconds()),
+		})
+	}
+}
+
+// отзыв токена, которым подписан запрос
+func (s *APIServer) revokeToken() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("revoke token request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, _ := request.Context().Value(identityKey).(identity)
+		if id.tokenID == "" {
+			writeProblem(writer, request, 400, codeBadParameter, "Authorization", "request must be authorized with the token to revoke")
+			return
+		}
+
+		err := s.store.RevokeToken(id.tokenID, id.expires)
+		if err != nil {
+			slog.Error("error revoking token", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// открытые ключи проверки токенов, доступны без авторизации
+func (s *APIServer) jwks() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		keys := make([]jwk, 0)
+		if s.keys != nil {
+			keys = s.keys.jwks()
+		}
+		writeJSON(writer, 200, map[string][]jwk{"keys": keys})
+	}
+}
+
+func unauthorized(writer http.ResponseWriter, request *http.Request, message string) {
+	writer.Header().Set("WWW-Authenticate", `Bearer realm="music-lib"`)
+	writeProblem(writer, request, 401, codeUnauthorized, "", message)
+}
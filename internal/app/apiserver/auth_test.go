@@ -0,0 +1,80 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"net/http"
+	"testing"
+)
+
+// создаёт ключ доступа, как libadmin mint-key, и возвращает его вместе с самим ключом
+func mintKey(t *testing.T, s *APIServer, name string, role db.Role) (db.APIKey, string) {
+	t.Helper()
+	secret, hash, err := db.NewAPIKeySecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.store.AddAPIKey(db.APIKey{Name: name, Role: role}, hash)
+	if err != nil {
+		t.Fatalf("AddAPIKey: %v", err)
+	}
+	return key, secret
+}
+
+func apiKey(secret string) http.Header {
+	return http.Header{apiKeyHeader: {secret}}
+}
+
+func TestAuthAPIKeys(t *testing.T) {
+	t.Setenv("EXTERNAL_API_URL", newExternalAPI(t).URL)
+	config := testConfig()
+	config.AuthEnabled = true
+	s, ts := startTestServer(t, config)
+	reader, readerSecret := mintKey(t, s, "dashboard", db.RoleReader)
+	_, editorSecret := mintKey(t, s, "importer", db.RoleEditor)
+
+	steps := []struct {
+		method, path, body string
+		header             http.Header
+		want               wantStatus
+	}{
+		{"GET", "/library/all", "", nil, wantStatus{401, codeUnauthorized}},
+		{"GET", "/library/all", "", apiKey("mlk_unknown"), wantStatus{401, codeUnauthorized}},
+		{"GET", "/library/all", "", http.Header{"Authorization": {"Bearer token"}}, wantStatus{401, codeUnauthorized}},
+		{"POST", "/library/add", `{"group":"Muse","song":"Uprising"}`, apiKey(editorSecret), wantStatus{status: 200}},
+		{"GET", "/library/all", "", apiKey(readerSecret), wantStatus{status: 200}},
+		{"POST", "/library/add", `{"group":"Muse","song":"Hysteria"}`, apiKey(readerSecret), wantStatus{403, codeForbidden}},
+		{"DELETE", "/artists/1", "", apiKey(editorSecret), wantStatus{403, codeForbidden}},
+		// описание api и открытые ключи доступны без ключа и с неверным ключом
+		{"GET", "/openapi.yaml", "", nil, wantStatus{status: 200}},
+		{"GET", "/openapi.json", "", apiKey("mlk_unknown"), wantStatus{status: 200}},
+		{"GET", "/docs", "", nil, wantStatus{status: 200}},
+		{"GET", "/auth/jwks.json", "", nil, wantStatus{status: 200}},
+	}
+	for _, step := range steps {
+		status, body := call(t, ts, step.method, step.path, step.body, step.header)
+		t.Run(step.method+" "+step.path, func(t *testing.T) {
+			checkStatus(t, status, body, step.want)
+		})
+	}
+
+	// отозванный ключ больше не принимается
+	err := s.store.RevokeAPIKey(reader.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	status, body := call(t, ts, "GET", "/library/all", "", apiKey(readerSecret))
+	checkStatus(t, status, body, wantStatus{401, codeUnauthorized})
+	status, body = call(t, ts, "GET", "/library/all", "", apiKey(editorSecret))
+	checkStatus(t, status, body, wantStatus{status: 200})
+}
+
+// при AUTH_ENABLED=false ключи не проверяются, любой запрос выполняется
+func TestAuthDisabled(t *testing.T) {
+	ts := newLibraryServer(t)
+	status, body := call(t, ts, "POST", "/library/add", `{"group":"Muse","song":"Uprising"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	status, body = call(t, ts, "POST", "/library/add", `{"group":"Muse","song":"Hysteria"}`, apiKey("mlk_unknown"))
+	checkStatus(t, status, body, wantStatus{status: 200})
+	status, body = call(t, ts, "DELETE", "/artists/1?cascade=true", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 204})
+}
@@ -2,19 +2,77 @@ package apiserver
 
 import (
 	"ApiServer/internal/app/db"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	BindPort string
 	LogLevel string
-	Database *db.Config
+	// проверка ключей доступа, отключается только явным AUTH_ENABLED=false
+	AuthEnabled bool
+	// ключи подписи токенов: "id:алгоритм:путь к файлу" через запятую,
+	// пустой список - токены не выдаются и не принимаются
+	JWTKeys string
+	// id ключа, которым подписываются новые токены
+	JWTSigningKey string
+	// срок действия токена
+	JWTTTL time.Duration
+	// файл описания api (пусто - встроенный api_swagger.yaml) и режим проверки
+	// запросов по нему: off, log или reject; при LOG_LEVEL=debug (-d) проверяются и ответы
+	OpenAPISpec       string
+	OpenAPIValidation string
+	// срок хранения удалённых песен в корзине, 0 - корзина не очищается сама
+	TrashRetention time.Duration
+	Database       *db.Config
 }
 
-func NewConfig() *Config {
+// настройки из переменных окружения. Неверное значение - ошибка: сервер
+// не запускается с настройкой по умолчанию вместо заданной
+func NewConfig() (*Config, error) {
+	authEnabled, err := strconv.ParseBool(os.Getenv("AUTH_ENABLED"))
+	if err != nil {
+		authEnabled = true
+	}
+	ttl, err := durationEnv("JWT_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("JWT_TTL %s is not positive", ttl)
+	}
+	retention, err := durationEnv("TRASH_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if retention < 0 {
+		return nil, fmt.Errorf("TRASH_RETENTION %s is negative", retention)
+	}
 	return &Config{
-		BindPort: os.Getenv("BIND_PORT"),
-		LogLevel: os.Getenv("LOG_LEVEL"),
-		Database: db.NewConfig(),
+		BindPort:          os.Getenv("BIND_PORT"),
+		LogLevel:          os.Getenv("LOG_LEVEL"),
+		AuthEnabled:       authEnabled,
+		JWTKeys:           os.Getenv("JWT_KEYS"),
+		JWTSigningKey:     os.Getenv("JWT_SIGNING_KEY"),
+		JWTTTL:            ttl,
+		OpenAPISpec:       os.Getenv("OPENAPI_SPEC"),
+		OpenAPIValidation: os.Getenv("OPENAPI_VALIDATION"),
+		TrashRetention:    retention,
+		Database:          db.NewConfig(),
+	}, nil
+}
+
+// длительность из переменной окружения name, пустая переменная - def
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
 	}
+	return d, nil
 }
@@ -0,0 +1,36 @@
+package apiserver
+
+import (
+	"testing"
+	"time"
+)
+
+// пустая переменная - значение по умолчанию, неверная - ошибка запуска
+func TestNewConfigDurations(t *testing.T) {
+	tests := []struct {
+		name, env, value string
+		wantErr          bool
+	}{
+		{"retention default", "TRASH_RETENTION", "", false},
+		{"retention off", "TRASH_RETENTION", "0", false},
+		{"retention", "TRASH_RETENTION", "48h", false},
+		{"retention typo", "TRASH_RETENTION", "30d", true},
+		{"retention negative", "TRASH_RETENTION", "-1h", true},
+		{"ttl default", "JWT_TTL", "", false},
+		{"ttl", "JWT_TTL", "1h", false},
+		{"ttl typo", "JWT_TTL", "15", true},
+		{"ttl zero", "JWT_TTL", "0s", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			config, err := NewConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && tt.value == "" && (config.TrashRetention != 30*24*time.Hour || config.JWTTTL != 15*time.Minute) {
+				t.Errorf("defaults: retention %s, ttl %s", config.TrashRetention, config.JWTTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package apiserver
+
+import (
+	api "ApiServer"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+)
+
+// страница просмотра и проверки api в браузере, работает без доступа к интернету:
+// описание берётся из /openapi.json того же сервера
+//
+//go:embed docs.html
+var docsPage []byte
+
+// описание api, с которым собран сервер
+type apiDoc struct {
+	doc  *openapi3.T
+	json []byte
+}
+
+// загружает встроенное описание api
+func loadDoc() (*apiDoc, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(api.Swagger)
+	if err != nil {
+		return nil, fmt.Errorf("load embedded api spec: %w", err)
+	}
+	err = doc.Validate(loader.Context)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded api spec: %w", err)
+	}
+	data, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encode embedded api spec: %w", err)
+	}
+	return &apiDoc{doc: doc, json: data}, nil
+}
+
+// переменная пути mux с регулярным выражением: {id:[0-9]+} -> {id}
+var muxPathVar = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// сверяет маршруты router с операциями описания: каждый маршрут должен быть описан,
+// каждая описанная операция - обрабатываться, иначе описание разошлось с кодом
+func (d *apiDoc) checkRoutes(router *mux.Router) error {
+	routes := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		path = muxPathVar.ReplaceAllString(path, "{$1}")
+		for _, method := range methods {
+			routes[method+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var undocumented, unrouted []string
+	documented := map[string]bool{}
+	for path, item := range d.doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+			if !routes[method+" "+path] {
+				unrouted = append(unrouted, method+" "+path)
+			}
+		}
+	}
+	for route := range routes {
+		if !documented[route] {
+			undocumented = append(undocumented, route)
+		}
+	}
+	if len(undocumented) == 0 && len(unrouted) == 0 {
+		return nil
+	}
+
+	slices.Sort(undocumented)
+	slices.Sort(unrouted)
+	var msgs []string
+	if len(undocumented) > 0 {
+		msgs = append(msgs, "not described in api_swagger.yaml: "+strings.Join(undocumented, ", "))
+	}
+	if len(unrouted) > 0 {
+		msgs = append(msgs, "described in api_swagger.yaml but not served: "+strings.Join(unrouted, ", "))
+	}
+	return fmt.Errorf("api spec doesn't match the routes: %s", strings.Join(msgs, "; "))
+}
+
+// описание api в исходном виде
+func (d *apiDoc) serveYAML() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-type", "application/yaml")
+		_, err := writer.Write(api.Swagger)
+		if err != nil {
+			slog.Error("error writing response", "error", err.Error())
+		}
+	}
+}
+
+func (d *apiDoc) serveJSON() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-type", "application/json")
+		_, err := writer.Write(d.json)
+		if err != nil {
+			slog.Error("error writing response", "error", err.Error())
+		}
+	}
+}
+
+func serveDocsPage() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-type", "text/html; charset=utf-8")
+		_, err := writer.Write(docsPage)
+		if err != nil {
+			slog.Error("error writing response", "error", err.Error())
+		}
+	}
+}
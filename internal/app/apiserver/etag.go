@@ -0,0 +1,132 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// оптимистичная блокировка песен: версия песни (db.Song.Version) отдаётся
+// в заголовке ETag, клиент передаёт её в If-Match при изменении или удалении
+// и, если песню за это время уже изменили, получает 412 вместо того, чтобы
+// незаметно затереть чужие изменения. GET с If-None-Match, совпадающим
+// с ETag, получает 304 без тела
+
+// ETag песни - её версия. Версия меняется при любом изменении данных песни,
+// поэтому ETag сильный
+func songETag(song db.Song) string {
+	return `"` + strconv.FormatInt(song.Version, 10) + `"`
+}
+
+// совпадает ли etag с одним из ETag заголовка header ("*" совпадает с любым).
+// If-Match сравнивает теги строго (strong): слабые теги W/"..." не совпадают ни с чем,
+// If-None-Match - без учёта слабости
+func etagMatch(header, etag string, strong bool) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if strong {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// проверяет If-Match запроса на изменение песни current и возвращает версию,
+// которую хранилище должно проверить при изменении (0 - без проверки),
+// чтобы песню не изменили между проверкой и изменением.
+// При несовпадении отвечает 412 и возвращает false
+func checkIfMatch(writer http.ResponseWriter, request *http.Request, current db.Song) (int64, bool) {
+	header := strings.TrimSpace(request.Header.Get("If-Match"))
+	switch {
+	case header == "", header == "*":
+		return 0, true
+	case !etagMatch(header, songETag(current), true):
+		slog.Info("song has changed", "id", current.ID, "etag", songETag(current), "if-match", header)
+		writeProblem(writer, request, 412, codePreconditionFailed, "If-Match",
+			"the song has been changed, its current ETag is "+songETag(current))
+		return 0, false
+	}
+	return current.Version, true
+}
+
+// версия, ожидаемая If-Match запроса на изменение песни id (см. checkIfMatch).
+// Без If-Match песня не загружается. При ошибке ответ уже отправлен
+func (s *APIServer) ifMatch(writer http.ResponseWriter, request *http.Request, id int64) (int64, bool) {
+	if request.Header.Get("If-Match") == "" {
+		return 0, true
+	}
+	current, err := s.store.GetSong(id)
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeStoreError(writer, request, err)
+		return 0, false
+	}
+	return checkIfMatch(writer, request, current)
+}
+
+// песня по исполнителю (в том числе по его прежнему имени) и названию
+func (s *APIServer) songByName(author, name string) (db.Song, error) {
+	lib, err := s.store.ListAllLibrary(db.ListParams{Filter: db.Song{Group: author, SongName: name}})
+	if err != nil {
+		return db.Song{}, err
+	}
+	if len(lib) == 0 {
+		return db.Song{}, db.ErrNotFound
+	}
+	return lib[0], nil
+}
+
+// отправляет песню с её ETag
+func writeSong(writer http.ResponseWriter, request *http.Request, song db.Song) {
+	etag := songETag(song)
+	writer.Header().Set("ETag", etag)
+	if notModified(writer, request, etag) {
+		return
+	}
+	writeJSON(writer, 200, song)
+}
+
+// отправляет список со слабым ETag - хешем тела ответа. У списка нет своей версии,
+// но повторный запрос, если список не изменился, получит 304 без тела
+func writeList(writer http.ResponseWriter, request *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("error encoding response", "error", err.Error())
+		writeInternalError(writer, request)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	writer.Header().Set("ETag", etag)
+	if notModified(writer, request, etag) {
+		return
+	}
+	writer.Header().Set("Content-type", "application/json")
+	writer.WriteHeader(200)
+	writer.Write(append(body, '\n'))
+}
+
+// отвечает 304, если GET запрос с If-None-Match уже получал ответ с ETag etag
+func notModified(writer http.ResponseWriter, request *http.Request, etag string) bool {
+	header := request.Header.Get("If-None-Match")
+	if request.Method != http.MethodGet || header == "" || !etagMatch(header, etag, false) {
+		return false
+	}
+	writer.WriteHeader(304)
+	return true
+}
@@ -0,0 +1,114 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// операторы фильтров списка песен: параметры вида field[op]=value, field[!op] - отрицание.
+// Каждый параметр превращается в правило db.Rule, правила объединяются через and,
+// поэтому значения попадают в sql только как параметры запроса
+//
+//	author[in]=a,b             releaseDate[from]=1990-01-01
+//	song[icontains]=love       releaseDate[to]=31.12.1999
+//	link[!prefix]=http://      text[contains]=chorus
+var filterParam = regexp.MustCompile(`^(\w+)\[(!?)(\w+)\]$`)
+
+// поля фильтров и соответствующие им поля правил
+var filterFields = map[string]string{
+	"author":      "artist",
+	"song":        "song",
+	"releaseDate": "releaseDate",
+	"text":        "text",
+	"link":        "link",
+}
+
+type filterOp struct {
+	// операция правила
+	op         string
+	ignoreCase bool
+	// значение - список через запятую
+	list bool
+	// граница диапазона дат: 0 - нижняя (from), 1 - верхняя (to), -1 - не диапазон
+	bound int
+}
+
+var filterOps = map[string]filterOp{
+	"eq":        {op: "in", bound: -1},
+	"in":        {op: "in", list: true, bound: -1},
+	"ieq":       {op: "in", ignoreCase: true, bound: -1},
+	"iin":       {op: "in", ignoreCase: true, list: true, bound: -1},
+	"contains":  {op: "contains", bound: -1},
+	"icontains": {op: "contains", ignoreCase: true, bound: -1},
+	"prefix":    {op: "prefix", bound: -1},
+	"iprefix":   {op: "prefix", ignoreCase: true, bound: -1},
+	"platform":  {op: "platform", list: true, bound: -1},
+	"from":      {op: "between", bound: 0},
+	"to":        {op: "between", bound: 1},
+}
+
+// правило из параметров-операторов запроса, nil - операторов нет;
+// ошибки каждого параметра добавляются в v
+func filterRule(request *http.Request, v *validate.Validator) *db.Rule {
+	err := request.ParseForm()
+	if err != nil {
+		v.Add("query", validate.CodeBadValue, "%s", err.Error())
+		return nil
+	}
+
+	// порядок параметров в запросе не важен, сортировка делает текст sql постоянным
+	keys := make([]string, 0, len(request.Form))
+	for key := range request.Form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var rules []db.Rule
+	for _, key := range keys {
+		m := filterParam.FindStringSubmatch(key)
+		if m == nil {
+			continue
+		}
+		field, ok := filterFields[m[1]]
+		if !v.Check(ok, key, validate.CodeBadValue, "unknown filter field %q", m[1]) {
+			continue
+		}
+		op, ok := filterOps[m[3]]
+		if !v.Check(ok, key, validate.CodeBadValue, "unknown filter operator %q", m[3]) {
+			continue
+		}
+
+		for _, value := range request.Form[key] {
+			rule := db.Rule{Field: field, Op: op.op, IgnoreCase: op.ignoreCase, Values: []string{value}}
+			switch {
+			case op.list:
+				rule.Values = strings.Split(value, ",")
+				for i := range rule.Values {
+					rule.Values[i] = strings.TrimSpace(rule.Values[i])
+				}
+			case op.bound >= 0:
+				rule.Values = make([]string, 2)
+				rule.Values[op.bound] = value
+			}
+			if m[2] == "!" {
+				negated := rule
+				rule = db.Rule{Not: &negated}
+			}
+			// правила проверяются по одному, чтобы сообщить, какой параметр неверен
+			err = rule.Validate()
+			if err != nil {
+				v.Add(key, validate.CodeBadValue, "bad filter: %s", err.Error())
+				continue
+			}
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return &db.Rule{All: rules}
+}
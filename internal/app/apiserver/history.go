@@ -0,0 +1,266 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// обработчики истории изменений песен и исполнителей (см. db.Revision)
+
+// разница между двумя ревизиями песни
+type songDiff struct {
+	From    int64       `json:"from"`
+	To      int64       `json:"to"`
+	Changes []db.Change `json:"changes"`
+}
+
+// история изменений песни, от новых ревизий к старым
+func (s *APIServer) songRevisions() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("song revisions request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		_, revs, ok := s.songHistory(writer, request)
+		if !ok {
+			return
+		}
+		writeJSON(writer, 200, revs)
+	}
+}
+
+// данные песни на момент ревизии
+func (s *APIServer) songRevision() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("song revision request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		version, ok := pathVersion(writer, request)
+		if !ok {
+			return
+		}
+		current, revs, ok := s.songHistory(writer, request)
+		if !ok {
+			return
+		}
+
+		song, err := db.SongAt(current, revs, version)
+		if err != nil {
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeJSON(writer, 200, song)
+	}
+}
+
+// поля, которыми отличаются ревизии песни from и to (по умолчанию - текущая версия)
+func (s *APIServer) diffSong() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("song diff request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		from := request.FormValue("from")
+		v.Required("from", from)
+		fromInt, _ := v.Int("from", from, 1, 0)
+		toInt, _ := v.Int("to", request.FormValue("to"), 1, 0)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		current, revs, ok := s.songHistory(writer, request)
+		if !ok {
+			return
+		}
+		if toInt == 0 {
+			toInt = int(current.Version)
+		}
+
+		a, err := db.SongAt(current, revs, int64(fromInt))
+		if err != nil {
+			writeStoreError(writer, request, err)
+			return
+		}
+		b, err := db.SongAt(current, revs, int64(toInt))
+		if err != nil {
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeJSON(writer, 200, songDiff{From: a.Version, To: b.Version, Changes: db.SongChanges(a, b)})
+	}
+}
+
+// возвращает песне данные, которые были у неё в ревизии version. Восстановление -
+// обычное изменение песни: у неё появляется новая версия, а в истории - новая ревизия
+func (s *APIServer) restoreSong() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("restore song request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		version, ok := pathVersion(writer, request)
+		if !ok {
+			return
+		}
+		current, revs, ok := s.songHistory(writer, request)
+		if !ok {
+			return
+		}
+		expected, ok := checkIfMatch(writer, request, current)
+		if !ok {
+			return
+		}
+
+		song, err := db.SongAt(current, revs, version)
+		if err != nil {
+			writeStoreError(writer, request, err)
+			return
+		}
+		// в ревизии может быть прежнее имя переименованного исполнителя: такого
+		// исполнителя больше нет, и песня остаётся у нынешнего, а не переходит
+		// к новому исполнителю с прежним именем
+		if song.Group != current.Group {
+			_, err = s.store.FindArtist(song.Group)
+			switch {
+			case errors.Is(err, db.ErrNotFound):
+				song.Group = current.Group
+			case err != nil:
+				slog.Error("error retrieving from db", "error", err.Error())
+				writeInternalError(writer, request)
+				return
+			}
+		}
+		if len(db.SongChanges(current, song)) == 0 {
+			writeSong(writer, request, current)
+			return
+		}
+
+		// данные ревизии вычислены по текущей версии, она и должна измениться
+		if expected == 0 {
+			expected = current.Version
+		}
+		song.Version = expected
+		err = s.store.UpdateSongByID(current.ID, song, actor(request))
+		if err != nil {
+			slog.Error("error restoring song", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		song, err = s.store.GetSong(current.ID)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeSong(writer, request, song)
+	}
+}
+
+// история изменений исполнителя, от новых ревизий к старым
+func (s *APIServer) artistRevisions() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("artist revisions request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		_, revs, ok := s.artistHistory(writer, request)
+		if !ok {
+			return
+		}
+		writeJSON(writer, 200, revs)
+	}
+}
+
+// возвращает исполнителю имя, которое было у него в ревизии version
+func (s *APIServer) restoreArtist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("restore artist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		version, ok := pathVersion(writer, request)
+		if !ok {
+			return
+		}
+		artist, revs, ok := s.artistHistory(writer, request)
+		if !ok {
+			return
+		}
+
+		name, err := db.ArtistNameAt(artist.Name, revs, version)
+		if err != nil {
+			writeStoreError(writer, request, err)
+			return
+		}
+		if name != artist.Name {
+			err = s.store.RenameArtist(artist.ID, name, actor(request))
+			if err != nil {
+				slog.Error("error restoring artist", "error", err.Error())
+				writeStoreError(writer, request, err)
+				return
+			}
+		}
+
+		artist, err = s.store.GetArtist(artist.ID)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeJSON(writer, 200, artist)
+	}
+}
+
+// песня из пути запроса и её история. При ошибке ответ уже отправлен
+func (s *APIServer) songHistory(writer http.ResponseWriter, request *http.Request) (db.Song, []db.Revision, bool) {
+	id, err := pathID(request)
+	if err != nil {
+		slog.Error("bad song id", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadParameter, "id", "bad song id")
+		return db.Song{}, nil, false
+	}
+
+	song, err := s.store.GetSong(id)
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeStoreError(writer, request, err)
+		return db.Song{}, nil, false
+	}
+	revs, err := s.store.SongRevisions(id)
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeInternalError(writer, request)
+		return db.Song{}, nil, false
+	}
+	return song, revs, true
+}
+
+// исполнитель из пути запроса и его история. При ошибке ответ уже отправлен
+func (s *APIServer) artistHistory(writer http.ResponseWriter, request *http.Request) (db.Artist, []db.Revision, bool) {
+	id, err := pathID(request)
+	if err != nil {
+		slog.Error("bad artist id", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadParameter, "id", "bad artist id")
+		return db.Artist{}, nil, false
+	}
+
+	artist, err := s.store.GetArtist(int(id))
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeStoreError(writer, request, err)
+		return db.Artist{}, nil, false
+	}
+	revs, err := s.store.ArtistRevisions(int(id))
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeInternalError(writer, request)
+		return db.Artist{}, nil, false
+	}
+	return artist, revs, true
+}
+
+// номер ревизии из пути запроса. При ошибке ответ уже отправлен
+func pathVersion(writer http.ResponseWriter, request *http.Request) (int64, bool) {
+	version, err := pathInt(request, "version")
+	if err != nil {
+		slog.Error("bad revision", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadParameter, "version", "bad revision")
+		return 0, false
+	}
+	return version, true
+}
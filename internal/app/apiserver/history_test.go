@@ -0,0 +1,142 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// ревизии песни или исполнителя по адресу path, от новых к старым
+func revisions(t *testing.T, ts *httptest.Server, path string) []db.Revision {
+	t.Helper()
+	status, body := call(t, ts, "GET", path, "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var revs []db.Revision
+	err := json.Unmarshal([]byte(body), &revs)
+	if err != nil {
+		t.Fatalf("decode revisions: %v", err)
+	}
+	return revs
+}
+
+func checkChanges(t *testing.T, rev db.Revision, version int64, want ...db.Change) {
+	t.Helper()
+	if rev.Version != version || rev.ChangedBy != "anonymous" || !slices.Equal(rev.Changes, want) {
+		t.Errorf("revision = %+v, want version %d with %+v", rev, version, want)
+	}
+}
+
+func TestSongHistory(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria")
+
+	status, body := call(t, ts, "PATCH", "/library/update?author=Muse&song=Uprising", `{"text":"Paranoia"}`,
+		http.Header{"If-Match": {`"1"`}})
+	checkStatus(t, status, body, wantStatus{status: 200})
+	status, body = call(t, ts, "PATCH", "/library/update?author=Muse&song=Uprising", `{"releaseDate":"07.09.2009"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+
+	revs := revisions(t, ts, "/songs/1/revisions")
+	if len(revs) != 2 {
+		t.Fatalf("revisions = %+v, want 2", revs)
+	}
+	checkChanges(t, revs[0], 3, db.Change{Field: "releaseDate", Old: "2006-07-16", New: "2009-09-07"})
+	checkChanges(t, revs[1], 2, db.Change{Field: "text", Old: externalText, New: "Paranoia"})
+
+	status, body = call(t, ts, "GET", "/songs/1/revisions/1", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var song db.Song
+	json.Unmarshal([]byte(body), &song)
+	if song.Version != 1 || song.Text != externalText || song.ReleaseDate != "2006-07-16" {
+		t.Errorf("revision 1 = %+v", song)
+	}
+
+	status, body = call(t, ts, "GET", "/songs/1/diff?from=1", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var diff songDiff
+	json.Unmarshal([]byte(body), &diff)
+	want := []db.Change{
+		{Field: "releaseDate", Old: "2006-07-16", New: "2009-09-07"},
+		{Field: "text", Old: externalText, New: "Paranoia"},
+	}
+	if diff.From != 1 || diff.To != 3 || !slices.Equal(diff.Changes, want) {
+		t.Errorf("diff = %+v, want 1..3 with %+v", diff, want)
+	}
+
+	steps := []struct {
+		method, path string
+		header       http.Header
+		want         wantStatus
+	}{
+		{"GET", "/songs/9/revisions", nil, wantStatus{404, codeNotFound}},
+		{"GET", "/songs/1/revisions/9", nil, wantStatus{404, codeNotFound}},
+		{"GET", "/songs/1/diff", nil, wantStatus{400, codeValidation}},
+		{"GET", "/songs/1/diff?from=0", nil, wantStatus{400, codeValidation}},
+		{"GET", "/songs/1/diff?from=1&to=9", nil, wantStatus{404, codeNotFound}},
+		{"POST", "/songs/1/revisions/9/restore", nil, wantStatus{404, codeNotFound}},
+		{"POST", "/songs/1/revisions/1/restore", http.Header{"If-Match": {`"2"`}}, wantStatus{412, codePreconditionFailed}},
+	}
+	for _, step := range steps {
+		status, body := call(t, ts, step.method, step.path, "", step.header)
+		t.Run(step.method+" "+step.path, func(t *testing.T) {
+			checkStatus(t, status, body, step.want)
+		})
+	}
+
+	// восстановление - новая ревизия, история не переписывается
+	status, body = call(t, ts, "POST", "/songs/1/revisions/1/restore", "", http.Header{"If-Match": {`"3"`}})
+	checkStatus(t, status, body, wantStatus{status: 200})
+	song = db.Song{}
+	json.Unmarshal([]byte(body), &song)
+	if song.Version != 4 || song.Text != externalText || song.ReleaseDate != "2006-07-16" {
+		t.Errorf("restored song = %+v, want revision 1 data as version 4", song)
+	}
+	revs = revisions(t, ts, "/songs/1/revisions")
+	if len(revs) != 3 {
+		t.Fatalf("revisions after restore = %+v, want 3", revs)
+	}
+	checkChanges(t, revs[0], 4,
+		db.Change{Field: "releaseDate", Old: "2009-09-07", New: "2006-07-16"},
+		db.Change{Field: "text", Old: "Paranoia", New: externalText})
+}
+
+func TestArtistHistory(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Queen/Innuendo")
+
+	status, body := call(t, ts, "PATCH", "/artists/1", `{"name":"MUSE"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	revs := revisions(t, ts, "/artists/1/revisions")
+	if len(revs) != 1 {
+		t.Fatalf("artist revisions = %+v, want 1", revs)
+	}
+	checkChanges(t, revs[0], 2, db.Change{Field: "name", Old: "Muse", New: "MUSE"})
+	// имя исполнителя входит в данные песни, поэтому переименование есть и в истории песни
+	checkChanges(t, revisions(t, ts, "/songs/1/revisions")[0], 2, db.Change{Field: "group", Old: "Muse", New: "MUSE"})
+
+	steps := []struct {
+		method, path, body string
+		want               wantStatus
+	}{
+		{"GET", "/artists/9/revisions", "", wantStatus{404, codeNotFound}},
+		{"POST", "/artists/1/revisions/9/restore", "", wantStatus{404, codeNotFound}},
+		{"PATCH", "/artists/2", `{"name":"MUSE"}`, wantStatus{409, codeAlreadyExists}},
+		{"POST", "/artists/1/revisions/1/restore", "", wantStatus{status: 200}},
+	}
+	for _, step := range steps {
+		status, body := call(t, ts, step.method, step.path, step.body, nil)
+		t.Run(step.method+" "+step.path, func(t *testing.T) {
+			checkStatus(t, status, body, step.want)
+		})
+	}
+
+	revs = revisions(t, ts, "/artists/1/revisions")
+	if len(revs) != 2 {
+		t.Fatalf("artist revisions after restore = %+v, want 2", revs)
+	}
+	checkChanges(t, revs[0], 3, db.Change{Field: "name", Old: "MUSE", New: "Muse"})
+	if got := libraryNames(t, ts, "?author=Muse"); len(got) != 1 || got[0] != "Uprising" {
+		t.Errorf("songs of Muse after restore = %q", got)
+	}
+}
@@ -0,0 +1,243 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// издатель токенов, проверяется при их разборе
+const tokenIssuer = "music-lib"
+
+// алгоритмы подписи в JWT_KEYS
+const (
+	algHS256   = "hs256"
+	algEd25519 = "ed25519"
+)
+
+// ключ подписи токенов, загруженный из файла
+type signingKey struct {
+	id  string
+	alg string
+	// секрет для HS256
+	secret []byte
+	// ключи Ed25519, private == nil - ключ только для проверки подписи
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+func (k *signingKey) method() jwt.SigningMethod {
+	if k.alg == algHS256 {
+		return jwt.SigningMethodHS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// keySet - ключи, которыми проверяются токены. Новые токены подписываются
+// ключом signing, остальные ключи позволяют принимать токены, выданные до смены ключа
+type keySet struct {
+	keys    map[string]*signingKey
+	signing *signingKey
+	ttl     time.Duration
+}
+
+// данные токена, role определяет права так же, как роль ключа доступа.
+// keyId - ключ, по которому выдан токен: после его отзыва токен не принимается
+type tokenClaims struct {
+	Role  db.Role `json:"role"`
+	KeyID int64   `json:"keyId"`
+	jwt.RegisteredClaims
+}
+
+// загружает ключи из списка "id:алгоритм:путь к файлу" через запятую (JWT_KEYS)
+// файл HS256 содержит секрет, файл Ed25519 - закрытый (PKCS #8) или открытый (PKIX) ключ в PEM
+func loadKeySet(spec, signingID string, ttl time.Duration) (*keySet, error) {
+	set := &keySet{keys: make(map[string]*signingKey), ttl: ttl}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("bad jwt key %q, expected id:algorithm:path", item)
+		}
+		key, err := loadKey(parts[0], strings.ToLower(parts[1]), parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", parts[0], err)
+		}
+		if _, ok := set.keys[key.id]; ok {
+			return nil, fmt.Errorf("duplicate jwt key id %q", key.id)
+		}
+		set.keys[key.id] = key
+	}
+
+	if len(set.keys) == 0 {
+		return nil, nil
+	}
+	signing, ok := set.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("signing jwt key %q is not in the key list", signingID)
+	}
+	if signing.alg == algEd25519 && signing.private == nil {
+		return nil, fmt.Errorf("signing jwt key %q has no private key", signingID)
+	}
+	set.signing = signing
+	return set, nil
+}
+
+func loadKey(id, alg, path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := &signingKey{id: id, alg: alg}
+
+	switch alg {
+	case algHS256:
+		key.secret = []byte(strings.TrimSpace(string(data)))
+		if len(key.secret) < 32 {
+			return nil, errors.New("hs256 secret must be at least 32 bytes long")
+		}
+	case algEd25519:
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM data found")
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			private, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("not an ed25519 private key")
+			}
+			key.private, key.public = private, private.Public().(ed25519.PublicKey)
+		case "PUBLIC KEY":
+			parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			public, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, errors.New("not an ed25519 public key")
+			}
+			key.public = public
+		default:
+			return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q, expected hs256 or ed25519", alg)
+	}
+	return key, nil
+}
+
+// выдаёт токен владельцу запроса id
+func (ks *keySet) issue(id identity) (string, tokenClaims, error) {
+	jti := make([]byte, 16)
+	_, err := rand.Read(jti)
+	if err != nil {
+		return "", tokenClaims{}, err
+	}
+
+	now := time.Now()
+	claims := tokenClaims{
+		Role:  id.Role,
+		KeyID: id.keyID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   id.Name,
+			ID:        hex.EncodeToString(jti),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ks.ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(ks.signing.method(), claims)
+	token.Header["kid"] = ks.signing.id
+	var signed string
+	if ks.signing.alg == algHS256 {
+		signed, err = token.SignedString(ks.signing.secret)
+	} else {
+		signed, err = token.SignedString(ks.signing.private)
+	}
+	return signed, claims, err
+}
+
+// проверяет подпись и срок действия токена, возвращает его данные
+func (ks *keySet) verify(token string) (tokenClaims, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		// алгоритм задаётся ключом, а не заголовком токена
+		if t.Method != key.method() {
+			return nil, fmt.Errorf("key %q doesn't sign with %s", kid, t.Method.Alg())
+		}
+		if key.alg == algHS256 {
+			return key.secret, nil
+		}
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return tokenClaims{}, err
+	}
+	if claims.ID == "" || claims.KeyID == 0 || !claims.Role.Valid() {
+		return tokenClaims{}, errors.New("token has no id, api key or valid role")
+	}
+	return claims, nil
+}
+
+// открытый ключ в формате JWK (RFC 8037)
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// открытые ключи Ed25519 для проверки токенов сторонними сервисами,
+// секреты HS256 не публикуются
+func (ks *keySet) jwks() []jwk {
+	keys := make([]jwk, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		if k.alg != algEd25519 {
+			continue
+		}
+		keys = append(keys, jwk{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k.public),
+			Kid: k.id,
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Use: "sig",
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Kid < keys[j].Kid
+	})
+	return keys
+}
@@ -0,0 +1,203 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// секрет ключа hs в JWT_KEYS тестового сервера
+const testHSSecret = "0123456789abcdef0123456789abcdef"
+
+// ключи подписи тестового сервера: ed - текущий ключ ed25519, old - открытая часть
+// прежнего ключа ed25519, hs - секрет hs256. Возвращает закрытые части ed и old
+func writeJWTKeys(t *testing.T) (spec string, ed, old ed25519.PrivateKey) {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name string, block *pem.Block) string {
+		path := filepath.Join(dir, name)
+		data := []byte(testHSSecret + "\n")
+		if block != nil {
+			data = pem.EncodeToMemory(block)
+		}
+		err := os.WriteFile(path, data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	_, ed, _ = ed25519.GenerateKey(rand.Reader)
+	_, old, _ = ed25519.GenerateKey(rand.Reader)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(old.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec = "ed:ed25519:" + write("ed", &pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}) +
+		",old:ed25519:" + write("old.pub", &pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}) +
+		",hs:hs256:" + write("hs", nil)
+	return spec, ed, old
+}
+
+// подписывает claims ключом key с заголовком kid, в обход keySet.issue
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims tokenClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func TestJWT(t *testing.T) {
+	spec, ed, old := writeJWTKeys(t)
+	config := testConfig()
+	config.AuthEnabled = true
+	config.JWTKeys = spec
+	config.JWTSigningKey = "ed"
+	config.JWTTTL = 15 * time.Minute
+	s, ts := startTestServer(t, config)
+	key, secret := mintKey(t, s, "importer", db.RoleEditor)
+
+	issue := func(secret string) string {
+		t.Helper()
+		status, body := call(t, ts, "POST", "/auth/token", "", apiKey(secret))
+		checkStatus(t, status, body, wantStatus{status: 200})
+		var resp tokenResponse
+		err := json.Unmarshal([]byte(body), &resp)
+		if err != nil || resp.TokenType != "Bearer" || resp.ExpiresIn != 900 {
+			t.Fatalf("token response = %+v, %v", resp, err)
+		}
+		return resp.AccessToken
+	}
+	token := issue(secret)
+
+	claims := func(expires time.Duration) tokenClaims {
+		now := time.Now()
+		return tokenClaims{
+			Role:  db.RoleEditor,
+			KeyID: key.ID,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    tokenIssuer,
+				Subject:   "importer",
+				ID:        "0123456789abcdef",
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(expires)),
+			},
+		}
+	}
+	noKey := claims(time.Minute)
+	noKey.KeyID = 0
+	noneToken := signToken(t, jwt.SigningMethodNone, "ed", jwt.UnsafeAllowNoneSignatureType, claims(time.Minute))
+	_, stranger, _ := ed25519.GenerateKey(rand.Reader)
+
+	tokens := []struct {
+		name  string
+		token string
+		want  wantStatus
+	}{
+		{"issued", token, wantStatus{status: 200}},
+		{"previous key", signToken(t, jwt.SigningMethodEdDSA, "old", old, claims(time.Minute)), wantStatus{status: 200}},
+		{"hs256", signToken(t, jwt.SigningMethodHS256, "hs", []byte(testHSSecret), claims(time.Minute)), wantStatus{status: 200}},
+		{"expired", signToken(t, jwt.SigningMethodEdDSA, "ed", ed, claims(-time.Minute)), wantStatus{401, codeUnauthorized}},
+		{"unknown kid", signToken(t, jwt.SigningMethodEdDSA, "other", stranger, claims(time.Minute)), wantStatus{401, codeUnauthorized}},
+		{"foreign key", signToken(t, jwt.SigningMethodEdDSA, "ed", stranger, claims(time.Minute)), wantStatus{401, codeUnauthorized}},
+		// открытый ключ ed25519 как секрет hs256 и токен без подписи
+		{"hs256 with ed25519 kid", signToken(t, jwt.SigningMethodHS256, "ed", []byte(ed.Public().(ed25519.PublicKey)), claims(time.Minute)),
+			wantStatus{401, codeUnauthorized}},
+		{"alg none", noneToken, wantStatus{401, codeUnauthorized}},
+		{"no api key", signToken(t, jwt.SigningMethodEdDSA, "ed", ed, noKey), wantStatus{401, codeUnauthorized}},
+	}
+	for _, tt := range tokens {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(t, ts, "GET", "/trash", "", bearer(tt.token))
+			checkStatus(t, status, body, tt.want)
+		})
+	}
+
+	// по токену новый токен не выдаётся
+	status, body := call(t, ts, "POST", "/auth/token", "", bearer(token))
+	checkStatus(t, status, body, wantStatus{403, codeForbidden})
+
+	// отозванный токен не принимается, другие токены того же ключа действуют
+	other := issue(secret)
+	status, body = call(t, ts, "DELETE", "/auth/token", "", bearer(token))
+	checkStatus(t, status, body, wantStatus{status: 204})
+	status, body = call(t, ts, "GET", "/trash", "", bearer(token))
+	checkStatus(t, status, body, wantStatus{401, codeUnauthorized})
+	status, body = call(t, ts, "GET", "/trash", "", bearer(other))
+	checkStatus(t, status, body, wantStatus{status: 200})
+
+	// после отзыва ключа не принимаются и выданные по нему токены
+	err := s.store.RevokeAPIKey(key.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	status, body = call(t, ts, "GET", "/trash", "", bearer(other))
+	checkStatus(t, status, body, wantStatus{401, codeUnauthorized})
+}
+
+// в JWKS только открытые ключи ed25519, секрет hs256 не публикуется
+func TestJWKS(t *testing.T) {
+	spec, ed, old := writeJWTKeys(t)
+	config := testConfig()
+	config.AuthEnabled = true
+	config.JWTKeys = spec
+	config.JWTSigningKey = "ed"
+	ts := newTestServer(t, config)
+
+	status, body := call(t, ts, "GET", "/auth/jwks.json", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	if strings.Contains(body, testHSSecret) || strings.Contains(body, base64.RawURLEncoding.EncodeToString([]byte(testHSSecret))) {
+		t.Errorf("jwks exposes the hs256 secret: %s", body)
+	}
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	err := json.Unmarshal([]byte(body), &set)
+	if err != nil {
+		t.Fatalf("decode jwks: %v", err)
+	}
+	want := []map[string]string{
+		{"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(ed.Public().(ed25519.PublicKey)),
+			"kid": "ed", "alg": "EdDSA", "use": "sig"},
+		{"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(old.Public().(ed25519.PublicKey)),
+			"kid": "old", "alg": "EdDSA", "use": "sig"},
+	}
+	if len(set.Keys) != len(want) {
+		t.Fatalf("jwks keys = %v, want ed and old", set.Keys)
+	}
+	for i, key := range set.Keys {
+		if len(key) != len(want[i]) {
+			t.Errorf("key %d = %v, want only %v", i, key, want[i])
+			continue
+		}
+		for field, value := range want[i] {
+			if key[field] != value {
+				t.Errorf("key %d %s = %q, want %q", i, field, key[field], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/validate"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// режимы проверки запросов по описанию api (OPENAPI_VALIDATION)
+const (
+	// проверка выключена, описание не загружается
+	specOff = "off"
+	// несоответствия только пишутся в лог
+	specLog = "log"
+	// запрос, не соответствующий описанию, получает 400, ответ - 500
+	specReject = "reject"
+)
+
+// проверка запросов и ответов по описанию api (api_swagger.yaml)
+type specValidator struct {
+	router routers.Router
+	reject bool
+	// ответы проверяются только в режиме отладки (-d): их приходится целиком держать в памяти
+	responses bool
+}
+
+// проверка по описанию api из файла path или, если он не указан, по встроенному
+// описанию embedded; nil - проверка выключена
+func loadSpec(embedded *openapi3.T, path, mode string, responses bool) (*specValidator, error) {
+	switch mode {
+	case "", specOff:
+		return nil, nil
+	case specLog, specReject:
+	default:
+		return nil, fmt.Errorf("unknown OPENAPI_VALIDATION %q, must be off, log or reject", mode)
+	}
+
+	doc := embedded
+	if path != "" {
+		loader := openapi3.NewLoader()
+		var err error
+		doc, err = loader.LoadFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("load api spec %s: %w", path, err)
+		}
+		err = doc.Validate(loader.Context)
+		if err != nil {
+			return nil, fmt.Errorf("invalid api spec %s: %w", path, err)
+		}
+	} else {
+		path = "embedded"
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("api spec routes %s: %w", path, err)
+	}
+
+	slog.Info("api spec validation is enabled", "spec", path, "mode", mode, "responses", responses)
+	return &specValidator{router: router, reject: mode == specReject, responses: responses}, nil
+}
+
+// middleware, сверяющий запрос (и в режиме отладки - ответ) с описанием api.
+// У ответа проверяются код и заголовки, тело - только у json (см. isJSON).
+// Ключи проверяет authenticate, здесь схемы безопасности не проверяются
+func (v *specValidator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route, pathParams, err := v.router.FindRoute(request)
+		if err != nil {
+			// маршрут есть в configureRouter, но не описан в api_swagger.yaml
+			slog.Warn("route is not described in api spec", "method", request.Method, "path", request.URL.Path)
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    request,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:         true,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+				// значения по умолчанию подставляют сами обработчики, иначе
+				// в запросе появились бы параметры, которых клиент не передавал
+				SkipSettingDefaults: true,
+			},
+		}
+		err = openapi3filter.ValidateRequest(request.Context(), input)
+		if err != nil {
+			errs := specErrors(err, "", nil)
+			slog.Warn("request doesn't match api spec", "method", request.Method, "path", request.URL.Path,
+				"error", errs.Error())
+			if v.reject {
+				writeInvalid(writer, request, errs)
+				return
+			}
+		}
+
+		if !v.responses {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: writer, status: 200}
+		next.ServeHTTP(rec, request)
+
+		err = openapi3filter.ValidateResponse(request.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rec.status,
+			Header:                 writer.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+			Options: &openapi3filter.Options{
+				MultiError:            true,
+				IncludeResponseStatus: true,
+				// тело сверяется со схемой только у json: описание api отдаётся
+				// в yaml, страница документации - в html, текст песни - строками
+				ExcludeResponseBody: !isJSON(writer.Header().Get("Content-Type")),
+			},
+		})
+		if err != nil {
+			slog.Warn("response doesn't match api spec", "method", request.Method, "path", request.URL.Path,
+				"status", rec.status, "error", err.Error())
+			if v.reject {
+				// заголовки ответа обработчика к ошибке не относятся
+				for key := range writer.Header() {
+					if key != requestIDHeader {
+						writer.Header().Del(key)
+					}
+				}
+				writeProblem(writer, request, 500, codeInternal, "", "response doesn't match api spec")
+				return
+			}
+		}
+		writer.WriteHeader(rec.status)
+		writer.Write(rec.body.Bytes())
+	})
+}
+
+// тип содержимого json: application/json или с суффиксом +json (application/problem+json)
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// ошибки проверки по описанию api в виде ошибок полей запроса:
+// параметр запроса или путь к полю тела через точку
+func specErrors(err error, field string, errs validate.Errors) validate.Errors {
+	var multi openapi3.MultiError
+	var reqErr *openapi3filter.RequestError
+	var schemaErr *openapi3.SchemaError
+	switch {
+	case errors.As(err, &multi) && !errors.As(err, &reqErr):
+		for _, err := range multi {
+			errs = specErrors(err, field, errs)
+		}
+		return errs
+	case errors.As(err, &reqErr) && field == "":
+		switch {
+		case reqErr.Parameter != nil:
+			field = reqErr.Parameter.Name
+		case reqErr.RequestBody != nil:
+			field = "body"
+		default:
+			field = "request"
+		}
+		if reqErr.Err != nil && (errors.As(reqErr.Err, &multi) || errors.As(reqErr.Err, &schemaErr)) {
+			return specErrors(reqErr.Err, field, errs)
+		}
+	case errors.As(err, &schemaErr):
+		if path := schemaErr.JSONPointer(); len(path) > 0 {
+			field = strings.Join(path, ".")
+		}
+		return append(errs, validate.FieldError{Field: field, Code: validate.CodeBadValue, Message: schemaErr.Reason})
+	}
+
+	code := validate.CodeBadValue
+	if errors.Is(err, openapi3filter.ErrInvalidRequired) {
+		code = validate.CodeRequired
+	}
+	message := err.Error()
+	if reqErr != nil && reqErr.Reason != "" {
+		message = reqErr.Reason
+	}
+	return append(errs, validate.FieldError{Field: field, Code: code, Message: message})
+}
+
+// ответ обработчика, задержанный для проверки: заголовки пишутся сразу
+// в исходный ResponseWriter, код и тело - после проверки
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
@@ -0,0 +1,60 @@
+package apiserver
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// описание api и страница документации не json, но в режиме reject с проверкой
+// ответов должны отдаваться как есть, а не ошибкой несоответствия описанию
+func TestSpecRoutesPassResponseValidation(t *testing.T) {
+	ts := newTestServer(t, testConfig())
+
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{"/openapi.yaml", "application/yaml"},
+		{"/openapi.json", "application/json"},
+		{"/docs", "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != 200 {
+				t.Fatalf("status = %d, want 200, body: %s", resp.StatusCode, body)
+			}
+			if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if len(body) == 0 {
+				t.Error("empty body")
+			}
+		})
+	}
+}
+
+func TestIsJSON(t *testing.T) {
+	tests := map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"application/problem+json":        true,
+		"application/yaml":                false,
+		"text/html; charset=utf-8":        false,
+		"text/plain":                      false,
+		"":                                false,
+	}
+	for contentType, want := range tests {
+		if got := isJSON(contentType); got != want {
+			t.Errorf("isJSON(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// размер страницы при выводе по курсору, если limit не указан
+const defaultPageSize = 50
+
+// страница списка песен при выводе по курсору
+type songsPage struct {
+	Songs db.Library `json:"songs"`
+	// курсоры следующей и предыдущей страниц, пусто - страницы нет
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+	// количество песен по фильтрам на всех страницах, только для count=true
+	Total *int `json:"total,omitempty"`
+}
+
+// параметры вывода списка песен по страницам
+type pageRequest struct {
+	// параметр cursor включает вывод по курсору вместо offset: пустой - первая
+	// страница, иначе - next или prev из предыдущего ответа. Без него ответ остаётся
+	// массивом песен, как у старых клиентов
+	cursor bool
+	// размер страницы при выводе по курсору
+	limit int
+	// count=true - вернуть общее количество песен по фильтрам
+	count bool
+}
+
+// читает параметры страницы из запроса, курсор записывается в params;
+// ошибки добавляются в v
+func pageParams(request *http.Request, params *db.ListParams, v *validate.Validator) pageRequest {
+	var page pageRequest
+	page.count, _ = v.Bool("count", request.FormValue("count"))
+	page.cursor = request.URL.Query().Has("cursor")
+	if !page.cursor {
+		return page
+	}
+
+	v.Check(params.Offset == "", "offset", validate.CodeBadValue, "offset can't be combined with cursor")
+	if !v.Has("limit") {
+		page.limit, _ = v.Int("limit", params.Limit, 1, defaultPageSize)
+	}
+	// порядок по сходству не задаёт ключ, по которому можно продолжить список
+	v.Check(params.Similarity == 0 || params.Sort != "", "sort", validate.CodeRequired,
+		"cursor with fuzzy=true requires sort")
+	if token := request.URL.Query().Get("cursor"); token != "" {
+		cursor, err := db.ParseCursor(token)
+		if err == nil && cursor.Sort != params.Sort {
+			err = fmt.Errorf("%w: cursor was issued for sort %q", db.ErrBadCursor, cursor.Sort)
+		}
+		if err != nil {
+			v.Add("cursor", validate.CodeBadValue, "%s", err.Error())
+		}
+		params.Cursor = &cursor
+	}
+	return page
+}
+
+// offset и limit для вывода по смещению - неотрицательные числа, пусто - без ограничения
+func checkPage(v *validate.Validator, offset, limit string) {
+	v.Int("offset", offset, 0, 0)
+	v.Int("limit", limit, 0, 0)
+}
+
+// страница песен по курсору из запроса: песни, курсоры соседних страниц
+// и ссылки на них в заголовке Link (RFC 8288)
+func (s *APIServer) songsPage(writer http.ResponseWriter, request *http.Request, params db.ListParams, page pageRequest) {
+	limit := page.limit
+
+	// лишняя песня показывает, есть ли страница дальше; для курсоров
+	// нужны поля сортировки, даже если они не запрошены
+	fields := params.Fields
+	params.Limit = strconv.Itoa(limit + 1)
+	if fields != nil {
+		params.Fields = append(slices.Clone(fields), "id", "group", "song", "releaseDate")
+	}
+	lib, err := s.store.ListAllLibrary(params)
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeInternalError(writer, request)
+		return
+	}
+
+	var resp songsPage
+	more := len(lib) > limit
+	if params.Cursor == nil || !params.Cursor.Before {
+		if more {
+			lib = lib[:limit]
+			resp.Next = db.NewCursor(params.Sort, lib[len(lib)-1], false).String()
+		}
+		if params.Cursor != nil && len(lib) > 0 {
+			resp.Prev = db.NewCursor(params.Sort, lib[0], true).String()
+		}
+	} else {
+		if more {
+			lib = lib[1:]
+			resp.Prev = db.NewCursor(params.Sort, lib[0], true).String()
+		}
+		if len(lib) > 0 {
+			resp.Next = db.NewCursor(params.Sort, lib[len(lib)-1], false).String()
+		}
+	}
+	for i := range lib {
+		lib[i] = lib[i].Only(fields)
+	}
+	resp.Songs = lib
+
+	if page.count {
+		total, err := s.store.CountLibrary(params)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+		resp.Total = &total
+	}
+
+	links := []string{pageLink(request, "first", "")}
+	if resp.Next != "" {
+		links = append(links, pageLink(request, "next", resp.Next))
+	}
+	if resp.Prev != "" {
+		links = append(links, pageLink(request, "prev", resp.Prev))
+	}
+	writer.Header().Set("Link", strings.Join(links, ", "))
+
+	writeJSON(writer, 200, resp)
+}
+
+// ссылка на страницу в формате заголовка Link: тот же запрос с другим курсором
+func pageLink(request *http.Request, rel, cursor string) string {
+	u := *request.URL
+	query := u.Query()
+	query.Set("cursor", cursor)
+	u.RawQuery = query.Encode()
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
+
+// общее количество песен для ответа без курсора - в заголовке X-Total-Count,
+// тело ответа остаётся массивом
+func (s *APIServer) setTotalCount(writer http.ResponseWriter, request *http.Request, params db.ListParams) (ok bool) {
+	total, err := s.store.CountLibrary(params)
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeInternalError(writer, request)
+		return false
+	}
+	writer.Header().Set("X-Total-Count", strconv.Itoa(total))
+	return true
+}
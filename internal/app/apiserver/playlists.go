@@ -0,0 +1,259 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// тело запроса на добавление песни в плейлист
+type entryRequest struct {
+	SongID int64 `json:"songId"`
+}
+
+// тело запроса на перемещение песни в плейлисте
+type moveRequest struct {
+	Position int `json:"position"`
+}
+
+// список плейлистов, параметр owner - только плейлисты этого пользователя
+func (s *APIServer) listPlaylists() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("list playlists request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		offset, limit := request.FormValue("offset"), request.FormValue("limit")
+		checkPage(&v, offset, limit)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		playlists, err := s.store.ListPlaylists(request.FormValue("owner"), offset, limit)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeJSON(writer, 200, playlists)
+	}
+}
+
+// плейлист вместе с песнями
+func (s *APIServer) getPlaylist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("get playlist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad playlist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad playlist id")
+			return
+		}
+		var v validate.Validator
+		fields := songFields(request, &v)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		s.writePlaylist(writer, request, id, 200, fields)
+	}
+}
+
+func (s *APIServer) addPlaylist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("add playlist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var playlist db.Playlist
+		if !readBody(writer, request, &playlist) {
+			return
+		}
+		playlist.Name = strings.TrimSpace(playlist.Name)
+		playlist.Owner = strings.TrimSpace(playlist.Owner)
+		var v validate.Validator
+		checkNameAndOwner(&v, playlist.Name, playlist.Owner)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		playlist, err := s.store.AddPlaylist(db.Playlist{Name: playlist.Name, Owner: playlist.Owner})
+		if err != nil {
+			slog.Error("error adding playlist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		writeJSON(writer, 201, playlist)
+	}
+}
+
+// удаление плейлиста, его песни остаются в библиотеке
+func (s *APIServer) deletePlaylist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("delete playlist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad playlist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad playlist id")
+			return
+		}
+
+		err = s.store.DeletePlaylist(id)
+		if err != nil {
+			slog.Error("error deleting playlist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// добавление песни в конец плейлиста
+func (s *APIServer) appendToPlaylist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("append to playlist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad playlist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad playlist id")
+			return
+		}
+
+		var entry entryRequest
+		if !readBody(writer, request, &entry) {
+			return
+		}
+		var v validate.Validator
+		if !v.Check(entry.SongID != 0, "songId", validate.CodeRequired, "song id is required") {
+			writeInvalid(writer, request, v.Err())
+			return
+		}
+
+		_, err = s.store.AppendToPlaylist(id, entry.SongID)
+		if err != nil {
+			slog.Error("error appending to playlist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		s.writePlaylist(writer, request, id, 201, db.DefaultSongFields)
+	}
+}
+
+// перемещение песни плейлиста на другую позицию,
+// позиция больше длины плейлиста перемещает песню в конец
+func (s *APIServer) movePlaylistEntry() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("move playlist entry request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, entryID, ok := playlistEntryIDs(writer, request)
+		if !ok {
+			return
+		}
+
+		var move moveRequest
+		if !readBody(writer, request, &move) {
+			return
+		}
+		var v validate.Validator
+		if !v.Min("position", move.Position, 1) {
+			writeInvalid(writer, request, v.Err())
+			return
+		}
+
+		err := s.store.MovePlaylistEntry(id, entryID, move.Position)
+		if err != nil {
+			slog.Error("error moving playlist entry", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		s.writePlaylist(writer, request, id, 200, db.DefaultSongFields)
+	}
+}
+
+// удаление песни из плейлиста, сама песня остаётся в библиотеке
+func (s *APIServer) removePlaylistEntry() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("remove playlist entry request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, entryID, ok := playlistEntryIDs(writer, request)
+		if !ok {
+			return
+		}
+
+		err := s.store.RemovePlaylistEntry(id, entryID)
+		if err != nil {
+			slog.Error("error removing playlist entry", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// отвечает плейлистом id с кодом status, в песнях - только поля fields
+func (s *APIServer) writePlaylist(writer http.ResponseWriter, request *http.Request, id int64, status int, fields []string) {
+	playlist, err := s.store.GetPlaylist(id)
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeStoreError(writer, request, err)
+		return
+	}
+	for i := range playlist.Entries {
+		playlist.Entries[i].Song = playlist.Entries[i].Song.Only(fields)
+	}
+	writeJSON(writer, status, playlist)
+}
+
+// id плейлиста и его записи из пути запроса, при ошибке сам отвечает 400
+func playlistEntryIDs(writer http.ResponseWriter, request *http.Request) (int64, int64, bool) {
+	id, err := pathID(request)
+	if err != nil {
+		slog.Error("bad playlist id", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadParameter, "id", "bad playlist id")
+		return 0, 0, false
+	}
+	entryID, err := pathInt(request, "entryId")
+	if err != nil {
+		slog.Error("bad playlist entry id", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadParameter, "entryId", "bad playlist entry id")
+		return 0, 0, false
+	}
+	return id, entryID, true
+}
+
+// имя и владелец плейлиста обязательны
+func checkNameAndOwner(v *validate.Validator, name, owner string) {
+	v.Name("name", name)
+	v.Name("owner", owner)
+}
+
+// читает json из тела запроса в v, при ошибке сам отвечает 400
+func readBody(writer http.ResponseWriter, request *http.Request, v any) bool {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		slog.Error("error reading request body", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
+		return false
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		slog.Error("unmarshal error", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", err.Error())
+		return false
+	}
+	return true
+}
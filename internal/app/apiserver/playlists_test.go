@@ -0,0 +1,79 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"encoding/json"
+	"testing"
+)
+
+func TestPlaylists(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria", "Queen/Innuendo")
+
+	steps := []struct {
+		method, path, body string
+		want               wantStatus
+	}{
+		{"POST", "/playlists", `{"name":"Road","owner":"alice"}`, wantStatus{status: 201}},
+		{"POST", "/playlists", `{"name":"Road","owner":"alice"}`, wantStatus{409, codeAlreadyExists}},
+		{"POST", "/playlists", `{"name":"Road","owner":"bob"}`, wantStatus{status: 201}},
+		{"POST", "/playlists", `{"name":"","owner":"bob"}`, wantStatus{400, codeValidation}},
+		{"POST", "/playlists/1/entries", `{"songId":3}`, wantStatus{status: 201}},
+		{"POST", "/playlists/1/entries", `{"songId":1}`, wantStatus{status: 201}},
+		{"POST", "/playlists/1/entries", `{"songId":3}`, wantStatus{status: 201}},
+		{"POST", "/playlists/1/entries", `{"songId":9}`, wantStatus{404, codeNotFound}},
+		{"POST", "/playlists/1/entries", `{}`, wantStatus{400, codeValidation}},
+		{"POST", "/playlists/7/entries", `{"songId":1}`, wantStatus{404, codeNotFound}},
+		{"PUT", "/playlists/1/entries/2", `{"position":1}`, wantStatus{status: 200}},
+		{"PUT", "/playlists/1/entries/2", `{"position":0}`, wantStatus{400, codeValidation}},
+		{"PUT", "/playlists/1/entries/9", `{"position":1}`, wantStatus{404, codeNotFound}},
+		{"DELETE", "/playlists/1/entries/9", "", wantStatus{404, codeNotFound}},
+		{"GET", "/playlists/7", "", wantStatus{404, codeNotFound}},
+		{"GET", "/playlists/1?fields=lyrics", "", wantStatus{400, codeValidation}},
+	}
+	for _, step := range steps {
+		status, body := call(t, ts, step.method, step.path, step.body, nil)
+		t.Run(step.method+" "+step.path, func(t *testing.T) {
+			checkStatus(t, status, body, step.want)
+		})
+	}
+
+	// песня может повторяться, записи идут по позициям, текст по умолчанию не отдаётся
+	status, body := call(t, ts, "GET", "/playlists/1", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var playlist db.Playlist
+	err := json.Unmarshal([]byte(body), &playlist)
+	if err != nil {
+		t.Fatalf("decode playlist: %v", err)
+	}
+	if playlist.SongCount != 3 || len(playlist.Entries) != 3 {
+		t.Fatalf("playlist = %+v, want 3 entries", playlist)
+	}
+	var names []string
+	for i, e := range playlist.Entries {
+		if e.Position != i+1 || e.Song.Text != "" || e.Song.Version != 1 {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+		names = append(names, e.Song.SongName)
+	}
+	if want := []string{"Uprising", "Innuendo", "Innuendo"}; len(names) != 3 ||
+		names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("entries = %q, want %q", names, want)
+	}
+
+	status, body = call(t, ts, "GET", "/playlists/1?fields=song,text", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	playlist = db.Playlist{}
+	json.Unmarshal([]byte(body), &playlist)
+	if len(playlist.Entries) != 3 || playlist.Entries[0].Song != (db.Song{SongName: "Uprising", Text: externalText}) {
+		t.Errorf("entries with fields = %+v", playlist.Entries)
+	}
+
+	// после удаления плейлиста его песни остаются в библиотеке
+	status, body = call(t, ts, "DELETE", "/playlists/1", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 204})
+	status, body = call(t, ts, "DELETE", "/playlists/1", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+	if got := libraryNames(t, ts, ""); len(got) != 3 {
+		t.Errorf("library after playlist delete = %q", got)
+	}
+}
@@ -0,0 +1,160 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+// заголовок с id запроса: берётся из запроса клиента или создаётся сервером
+const requestIDHeader = "X-Request-ID"
+
+// допустимый id запроса от клиента, иначе сервер создаёт свой
+var clientRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// машиночитаемые коды ошибок (поле code в problem)
+const (
+	// параметры запроса или поля тела не прошли проверку, подробности - в errors
+	codeValidation = "validation_failed"
+	// переменная пути некорректна
+	codeBadParameter = "bad_parameter"
+	// тело запроса не прочитано или не является json нужного вида
+	codeBadBody          = "bad_body"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	// запись с такими данными уже есть
+	codeAlreadyExists = "already_exists"
+	// название добавляемой песни занято песней в корзине
+	codeInTrash = "in_trash"
+	// изменение противоречит текущим данным (у исполнителя есть песни и т.п.)
+	codeConflict = "conflict"
+	// If-Match не совпал с ETag: данные успели изменить
+	codePreconditionFailed = "precondition_failed"
+	// внешний api с данными песен вернул ошибку или недоступен
+	codeExternalAPI = "external_api_error"
+	codeInternal    = "internal_error"
+)
+
+// problem - тело ответа с ошибкой (RFC 7807, application/problem+json)
+type problem struct {
+	// тип не уточняется, title - стандартный текст кода ответа
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Code   string `json:"code"`
+	// описание ошибки для человека
+	Detail string `json:"detail,omitempty"`
+	// параметр запроса, тела или пути, из-за которого произошла ошибка
+	Param     string `json:"param,omitempty"`
+	Instance  string `json:"instance"`
+	RequestID string `json:"requestId,omitempty"`
+	// ошибки всех полей для validation_failed
+	Errors validate.Errors `json:"errors,omitempty"`
+}
+
+// отправляет ошибку в формате application/problem+json
+func writeProblem(writer http.ResponseWriter, request *http.Request, status int, code, param, detail string) {
+	sendProblem(writer, request, problem{Status: status, Code: code, Param: param, Detail: detail})
+}
+
+func sendProblem(writer http.ResponseWriter, request *http.Request, p problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = request.URL.Path
+	p.RequestID, _ = request.Context().Value(requestIDKey).(string)
+
+	writer.Header().Set("Content-type", "application/problem+json")
+	writer.WriteHeader(p.Status)
+	err := json.NewEncoder(writer).Encode(p)
+	if err != nil {
+		slog.Error("error encoding response", "error", err.Error())
+	}
+}
+
+// запрос не прошёл проверку (см. validate.Validator): 400 со всеми ошибками полей,
+// param указывается, если ошибка одна
+func writeInvalid(writer http.ResponseWriter, request *http.Request, err error) {
+	slog.Error("bad request", "error", err.Error())
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		writeProblem(writer, request, 400, codeValidation, "", err.Error())
+		return
+	}
+	p := problem{Status: 400, Code: codeValidation, Detail: errs.Error(), Errors: errs}
+	if len(errs) == 1 {
+		p.Param = errs[0].Field
+	}
+	sendProblem(writer, request, p)
+}
+
+// внутренняя ошибка: подробности остаются в логе сервера, найти их можно по id запроса
+func writeInternalError(writer http.ResponseWriter, request *http.Request) {
+	requestID, _ := request.Context().Value(requestIDKey).(string)
+	slog.Error("internal server error", "request id", requestID, "to", request.Host+request.URL.String())
+	writeProblem(writer, request, 500, codeInternal, "", "internal server error")
+}
+
+// ошибка, полученная от хранилища: отсутствующие и конфликтующие данные
+// сообщаются клиенту, остальное - внутренняя ошибка
+func writeStoreError(writer http.ResponseWriter, request *http.Request, err error) {
+	var trashed *db.TrashedSongError
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeProblem(writer, request, 404, codeNotFound, "", err.Error())
+	case errors.As(err, &trashed):
+		writeProblem(writer, request, 409, codeInTrash, "", fmt.Sprintf("song %d with this name is in the trash, "+
+			"restore it with POST /trash/%[1]d/restore or purge it with DELETE /trash/%[1]d", trashed.ID))
+	case errors.Is(err, db.ErrAlreadyExists):
+		writeProblem(writer, request, 409, codeAlreadyExists, "", err.Error())
+	case errors.Is(err, db.ErrArtistHasSongs), errors.Is(err, db.ErrMergeConflict), errors.Is(err, db.ErrBadTagParent):
+		writeProblem(writer, request, 409, codeConflict, "", err.Error())
+	case errors.Is(err, db.ErrVersionMismatch):
+		writeProblem(writer, request, 412, codePreconditionFailed, "If-Match", err.Error())
+	default:
+		writeInternalError(writer, request)
+	}
+}
+
+// middleware, присваивающий запросу id: он возвращается в заголовке X-Request-ID
+// и в ошибках, чтобы по нему можно было найти запрос в логе сервера
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		id := request.Header.Get(requestIDHeader)
+		if !clientRequestID.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		writer.Header().Set(requestIDHeader, id)
+
+		ctx := context.WithValue(request.Context(), requestIDKey, id)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+// ответ для путей, которых нет в api
+func routeNotFound() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("unknown route", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+		writeProblem(writer, request, 404, codeNotFound, "", "no such route")
+	}
+}
+
+// ответ для метода, не поддерживаемого маршрутом
+func methodNotAllowed() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("method not allowed", "method", request.Method, "from", request.RemoteAddr,
+			"to", request.Host+request.URL.String())
+		writeProblem(writer, request, 405, codeMethodNotAllowed, "", request.Method+" is not supported by this route")
+	}
+}
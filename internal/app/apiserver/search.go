@@ -0,0 +1,74 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"log/slog"
+	"net/http"
+)
+
+// поиск по текстам песен: /library/search?q=...
+// q - слова через пробел (должны встретиться все), "фраза в кавычках", начало*
+// фильтры и пагинация - как в /library/all, результаты упорядочены по релевантности
+func (s *APIServer) searchLyrics() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("search lyrics request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		query, err := db.ParseSearchQuery(request.FormValue("q"))
+		if err != nil {
+			v.Add("q", validate.CodeBadValue, "%s", err.Error())
+		}
+		params := libraryFilter(request, &v)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+		slog.Debug("search parameters", "query", request.FormValue("q"), "struct", params)
+
+		hits, err := s.store.SearchLyrics(query, params)
+		if err != nil {
+			slog.Error("error searching db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeJSON(writer, 200, hits)
+	}
+}
+
+// количество подсказок по умолчанию и максимальное
+const (
+	defaultSuggestions = 10
+	maxSuggestions     = 50
+)
+
+// подсказки при наборе: /library/suggest?prefix=...&kind=artist|song[&limit=n]
+// исполнители или песни, имя которых начинается с prefix без учёта регистра и диакритики
+func (s *APIServer) suggest() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		// вызывается на каждое нажатие клавиши, поэтому запросы пишутся в лог только в debug
+		slog.Debug("suggest request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		prefix := request.FormValue("prefix")
+		kind := request.FormValue("kind")
+		v.Name("prefix", prefix)
+		v.OneOf("kind", kind, db.SuggestKinds)
+		limit, _ := v.Int("limit", request.FormValue("limit"), 1, defaultSuggestions)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+		limit = min(limit, maxSuggestions)
+
+		suggestions, err := s.store.Suggest(kind, prefix, limit)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeJSON(writer, 200, suggestions)
+	}
+}
@@ -0,0 +1,147 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSearchLyrics(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria", "Queen/Innuendo")
+	status, body := call(t, ts, "PATCH", "/library/update?author=Muse&song=Uprising",
+		`{"text":"Paranoia is in bloom\n\nThey will not force us\nThey will stop degrading us"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+
+	search := func(query string) []db.SearchHit {
+		t.Helper()
+		status, body := call(t, ts, "GET", "/library/search?"+query, "", nil)
+		checkStatus(t, status, body, wantStatus{status: 200})
+		var hits []db.SearchHit
+		err := json.Unmarshal([]byte(body), &hits)
+		if err != nil {
+			t.Fatalf("decode hits: %v", err)
+		}
+		return hits
+	}
+	names := func(hits []db.SearchHit) string {
+		var names []string
+		for _, h := range hits {
+			names = append(names, h.SongName)
+		}
+		slices.Sort(names)
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		name, q string
+		want    string
+		verse   int
+		snippet string
+	}{
+		{"word", "paranoia", "Uprising", 1, "<b>Paranoia</b> is in bloom"},
+		{"phrase", `"force us"`, "Uprising", 2, "They will not <b>force</b> <b>us</b>"},
+		{"prefix", "degrad*", "Uprising", 2, "<b>degrading</b>"},
+		{"all words", "second verse", "Hysteria,Innuendo", 2, "<b>Second</b> <b>verse</b>"},
+		// слова из разных куплетов совпадают только с текстом целиком
+		{"across verses", "bloom force", "Uprising", 0, "<b>bloom</b>"},
+		{"no match", "bloom verse", "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := search("q=" + url.QueryEscape(tt.q))
+			if got := names(hits); got != tt.want {
+				t.Fatalf("hits = %q, want %q", got, tt.want)
+			}
+			for _, h := range hits {
+				if h.Verse != tt.verse || !strings.Contains(h.Snippet, tt.snippet) || h.Text != "" {
+					t.Errorf("hit = %+v, want verse %d with %q", h, tt.verse, tt.snippet)
+				}
+			}
+		})
+	}
+
+	// фильтры и пагинация - как в /library/all
+	if got := names(search("q=second&author=Queen")); got != "Innuendo" {
+		t.Errorf("hits of Queen = %q, want Innuendo", got)
+	}
+	if got := search("q=second&limit=1"); len(got) != 1 {
+		t.Errorf("hits with limit 1 = %+v", got)
+	}
+	for _, query := range []string{"", "q=", "q=" + url.QueryEscape(`"`), "q=second&limit=-1"} {
+		status, body := call(t, ts, "GET", "/library/search?"+query, "", nil)
+		t.Run("bad "+query, func(t *testing.T) {
+			checkStatus(t, status, body, wantStatus{400, codeValidation})
+		})
+	}
+
+	// песни в корзине не находятся
+	status, body = call(t, ts, "DELETE", "/songs/2", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 204})
+	if got := names(search("q=second")); got != "Innuendo" {
+		t.Errorf("hits after delete = %q, want Innuendo", got)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	ts := newLibraryServer(t, "Motörhead/Ace of Spades", "Mötley Crüe/Kickstart My Heart", "Muse/Uprising",
+		"Кино/Группа крови", "Кино/Кукушка", "Ёлка/Прованс")
+
+	suggest := func(query string) string {
+		t.Helper()
+		status, body := call(t, ts, "GET", "/library/suggest?"+query, "", nil)
+		checkStatus(t, status, body, wantStatus{status: 200})
+		var suggestions []db.Suggestion
+		err := json.Unmarshal([]byte(body), &suggestions)
+		if err != nil {
+			t.Fatalf("decode suggestions: %v", err)
+		}
+		var names []string
+		for _, s := range suggestions {
+			name := s.Name
+			if s.Group != "" {
+				name = s.Group + "/" + name
+			}
+			names = append(names, name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"kind=artist&prefix=mot", "Mötley Crüe,Motörhead"},
+		{"kind=artist&prefix=MÖT", "Mötley Crüe,Motörhead"},
+		{"kind=artist&prefix=mo&limit=1", "Mötley Crüe"},
+		{"kind=artist&prefix=" + url.QueryEscape("кИ"), "Кино"},
+		{"kind=artist&prefix=" + url.QueryEscape("елк"), "Ёлка"},
+		{"kind=song&prefix=" + url.QueryEscape("к"), "Кино/Кукушка"},
+		{"kind=song&prefix=" + url.QueryEscape("Гру"), "Кино/Группа крови"},
+		{"kind=song&prefix=kick", "Mötley Crüe/Kickstart My Heart"},
+		{"kind=song&prefix=zz", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := suggest(tt.query); got != tt.want {
+				t.Errorf("suggestions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"kind=artist", "prefix=mo", "kind=album&prefix=mo", "kind=song&prefix=mo&limit=0"} {
+		status, body := call(t, ts, "GET", "/library/suggest?"+query, "", nil)
+		t.Run("bad "+query, func(t *testing.T) {
+			checkStatus(t, status, body, wantStatus{400, codeValidation})
+		})
+	}
+
+	// песни в корзине не подсказываются
+	status, body := call(t, ts, "DELETE", "/songs/5", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 204})
+	if got := suggest("kind=song&prefix=" + url.QueryEscape("к")); got != "" {
+		t.Errorf("suggestions after delete = %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"net/http/httptest"
+	"testing"
+)
+
+// настройки тестового сервера: хранилище в памяти, без авторизации,
+// запросы и ответы проверяются по описанию api (как при -d и OPENAPI_VALIDATION=reject)
+func testConfig() *Config {
+	return &Config{
+		LogLevel:          "debug",
+		OpenAPIValidation: specReject,
+		Database:          &db.Config{Driver: db.DriverMemory},
+	}
+}
+
+// запускает сервер с настройками config на свободном порту, останавливается в конце теста
+func newTestServer(t *testing.T, config *Config) *httptest.Server {
+	t.Helper()
+	_, ts := startTestServer(t, config)
+	return ts
+}
+
+// как newTestServer, но возвращает и сам сервер - например, для доступа к его хранилищу
+func startTestServer(t *testing.T, config *Config) (*APIServer, *httptest.Server) {
+	t.Helper()
+	s := NewAPIServer(config)
+	err := s.configure()
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	ts := httptest.NewServer(s.server.Handler)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
@@ -0,0 +1,171 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// список умных плейлистов без песен, параметр owner - только плейлисты этого пользователя
+func (s *APIServer) listSmartPlaylists() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("list smart playlists request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		offset, limit := request.FormValue("offset"), request.FormValue("limit")
+		checkPage(&v, offset, limit)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		playlists, err := s.store.ListSmartPlaylists(request.FormValue("owner"), offset, limit)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeJSON(writer, 200, playlists)
+	}
+}
+
+// умный плейлист вместе с песнями, выбранными по его правилам на момент запроса
+func (s *APIServer) getSmartPlaylist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("get smart playlist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad smart playlist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad smart playlist id")
+			return
+		}
+		var v validate.Validator
+		fields := songFields(request, &v)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		s.writeSmartPlaylist(writer, request, id, 200, fields)
+	}
+}
+
+func (s *APIServer) addSmartPlaylist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("add smart playlist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		playlist, ok := readSmartPlaylist(writer, request)
+		if !ok {
+			return
+		}
+
+		playlist, err := s.store.AddSmartPlaylist(playlist)
+		if err != nil {
+			slog.Error("error adding smart playlist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		s.writeSmartPlaylist(writer, request, playlist.ID, 201, db.DefaultSongFields)
+	}
+}
+
+// замена определения умного плейлиста
+func (s *APIServer) updateSmartPlaylist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("update smart playlist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad smart playlist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad smart playlist id")
+			return
+		}
+
+		playlist, ok := readSmartPlaylist(writer, request)
+		if !ok {
+			return
+		}
+
+		err = s.store.UpdateSmartPlaylist(id, playlist)
+		if err != nil {
+			slog.Error("error updating smart playlist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		s.writeSmartPlaylist(writer, request, id, 200, db.DefaultSongFields)
+	}
+}
+
+func (s *APIServer) deleteSmartPlaylist() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("delete smart playlist request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad smart playlist id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad smart playlist id")
+			return
+		}
+
+		err = s.store.DeleteSmartPlaylist(id)
+		if err != nil {
+			slog.Error("error deleting smart playlist", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// отвечает умным плейлистом id вместе с его песнями с кодом status,
+// в песнях - только поля fields
+func (s *APIServer) writeSmartPlaylist(writer http.ResponseWriter, request *http.Request, id int64, status int, fields []string) {
+	playlist, err := s.store.GetSmartPlaylist(id)
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeStoreError(writer, request, err)
+		return
+	}
+
+	params := playlist.Params()
+	params.Fields = fields
+	playlist.Songs, err = s.store.ListAllLibrary(params)
+	if err != nil {
+		slog.Error("error evaluating smart playlist rules", "error", err.Error())
+		writeInternalError(writer, request)
+		return
+	}
+	writeJSON(writer, status, playlist)
+}
+
+// читает и проверяет определение умного плейлиста, при ошибке сам отвечает 400
+func readSmartPlaylist(writer http.ResponseWriter, request *http.Request) (db.SmartPlaylist, bool) {
+	var playlist db.SmartPlaylist
+	if !readBody(writer, request, &playlist) {
+		return db.SmartPlaylist{}, false
+	}
+
+	playlist.Name = strings.TrimSpace(playlist.Name)
+	playlist.Owner = strings.TrimSpace(playlist.Owner)
+	var v validate.Validator
+	checkNameAndOwner(&v, playlist.Name, playlist.Owner)
+	v.Min("limit", playlist.Limit, 0)
+	v.Check(db.ValidSort(playlist.Sort), "sort", validate.CodeBadValue, "bad sort %q", playlist.Sort)
+	if err := playlist.Rules.Validate(); err != nil {
+		v.Add("rules", validate.CodeBadValue, "%s", err.Error())
+	}
+	if err := v.Err(); err != nil {
+		writeInvalid(writer, request, err)
+		return db.SmartPlaylist{}, false
+	}
+	playlist.Songs = nil
+	return playlist, true
+}
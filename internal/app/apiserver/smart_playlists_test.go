@@ -0,0 +1,81 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSmartPlaylists(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria", "Queen/Innuendo")
+
+	muse := `{"name":"Muse","owner":"alice","rules":{"field":"artist","op":"in","values":["Muse"]},"sort":"song"}`
+	steps := []struct {
+		method, path, body string
+		want               wantStatus
+	}{
+		{"POST", "/smart-playlists", muse, wantStatus{status: 201}},
+		{"POST", "/smart-playlists", muse, wantStatus{409, codeAlreadyExists}},
+		{"POST", "/smart-playlists", `{"name":"Bad","owner":"alice","rules":{"field":"artist","op":"between","values":["A"]}}`,
+			wantStatus{400, codeValidation}},
+		{"POST", "/smart-playlists", `{"name":"Bad","owner":"alice","rules":{"all":[]}}`, wantStatus{400, codeValidation}},
+		{"POST", "/smart-playlists", `{"name":"Bad","owner":"alice","rules":{"field":"song","op":"in","values":["A"]},"sort":"lyrics"}`,
+			wantStatus{400, codeValidation}},
+		{"GET", "/smart-playlists/7", "", wantStatus{404, codeNotFound}},
+		{"GET", "/smart-playlists/1?fields=lyrics", "", wantStatus{400, codeValidation}},
+		{"PUT", "/smart-playlists/7", muse, wantStatus{404, codeNotFound}},
+		{"DELETE", "/smart-playlists/7", "", wantStatus{404, codeNotFound}},
+	}
+	for _, step := range steps {
+		status, body := call(t, ts, step.method, step.path, step.body, nil)
+		t.Run(step.method+" "+step.path, func(t *testing.T) {
+			checkStatus(t, status, body, step.want)
+		})
+	}
+
+	// песни выбираются по правилам на момент запроса
+	songs := func(query string) []db.Song {
+		t.Helper()
+		status, body := call(t, ts, "GET", "/smart-playlists/1"+query, "", nil)
+		checkStatus(t, status, body, wantStatus{status: 200})
+		var playlist db.SmartPlaylist
+		err := json.Unmarshal([]byte(body), &playlist)
+		if err != nil {
+			t.Fatalf("decode smart playlist: %v", err)
+		}
+		return playlist.Songs
+	}
+	names := func(songs []db.Song) string {
+		var names []string
+		for _, s := range songs {
+			names = append(names, s.SongName)
+		}
+		return strings.Join(names, ",")
+	}
+
+	got := songs("")
+	if names(got) != "Hysteria,Uprising" || got[0].Text != "" || got[0].Version != 1 {
+		t.Errorf("songs = %+v, want Hysteria, Uprising without text", got)
+	}
+	if got := songs("?fields=song,text"); len(got) != 2 || got[0] != (db.Song{SongName: "Hysteria", Text: externalText}) {
+		t.Errorf("songs with fields = %+v", got)
+	}
+
+	call(t, ts, "POST", "/library/add", `{"group":"Muse","song":"Madness"}`, nil)
+	if got := names(songs("")); got != "Hysteria,Madness,Uprising" {
+		t.Errorf("songs after add = %s", got)
+	}
+
+	status, body := call(t, ts, "PUT", "/smart-playlists/1",
+		`{"name":"Muse","owner":"alice","rules":{"not":{"field":"artist","op":"in","values":["Muse"]}},"limit":1}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	if got := names(songs("")); got != "Innuendo" {
+		t.Errorf("songs after update = %s", got)
+	}
+
+	status, body = call(t, ts, "DELETE", "/smart-playlists/1", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 204})
+	status, body = call(t, ts, "GET", "/smart-playlists/1", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+}
@@ -0,0 +1,229 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// обработчики /songs - работа с песнями по их id, в отличие от /library/*,
+// где песня определяется парой исполнитель + название
+
+// список песен с теми же параметрами фильтрации, что и /library/all,
+// но при отсутствии песен возвращается пустой список, а не 404
+func (s *APIServer) listSongs() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("list songs request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		params := libraryFilter(request, &v)
+		page := pageParams(request, &params, &v)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+		slog.Debug("filter parameters", "struct", params)
+
+		if page.cursor {
+			s.songsPage(writer, request, params, page)
+			return
+		}
+		if page.count && !s.setTotalCount(writer, request, params) {
+			return
+		}
+
+		lib, err := s.store.ListAllLibrary(params)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeList(writer, request, lib)
+	}
+}
+
+func (s *APIServer) getSong() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("get song request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad song id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad song id")
+			return
+		}
+
+		song, err := s.store.GetSong(id)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		writeSong(writer, request, song)
+	}
+}
+
+// полная замена данных песни: поля, отсутствующие в теле, будут очищены
+// исполнитель и название песни обязательны
+func (s *APIServer) replaceSong() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("replace song request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var song db.Song
+		s.saveSong(writer, request, song, false)
+	}
+}
+
+// частичное обновление песни: изменяются только поля, указанные в теле
+func (s *APIServer) patchSong() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("patch song request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		// см. updateSong
+		song := db.Song{
+			Group:       "no_data",
+			SongName:    "no_data",
+			ReleaseDate: "no_data",
+			Text:        "no_data",
+			Link:        "no_data",
+		}
+		s.saveSong(writer, request, song, true)
+	}
+}
+
+// общая часть PUT и PATCH: читает тело поверх song, проверяет его (см. checkSong),
+// сохраняет изменения, если песня не изменилась с момента чтения (If-Match),
+// и возвращает обновлённую песню
+func (s *APIServer) saveSong(writer http.ResponseWriter, request *http.Request, song db.Song, patch bool) {
+	id, err := pathID(request)
+	if err != nil {
+		slog.Error("bad song id", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadParameter, "id", "bad song id")
+		return
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		slog.Error("error reading request body", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
+		return
+	}
+
+	err = json.Unmarshal(body, &song)
+	if err != nil {
+		slog.Error("unmarshal error", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", err.Error())
+		return
+	}
+	var v validate.Validator
+	checkSong(&v, song, patch)
+	if err := v.Err(); err != nil {
+		writeInvalid(writer, request, err)
+		return
+	}
+
+	// версия из тела (поле только для чтения) не учитывается, её задаёт If-Match
+	var ok bool
+	song.Version, ok = s.ifMatch(writer, request, id)
+	if !ok {
+		return
+	}
+
+	slog.Debug("", "song id", id, "update song data", song)
+
+	err = s.store.UpdateSongByID(id, song, actor(request))
+	if err != nil {
+		slog.Error("updating song error", "error", err.Error())
+		writeStoreError(writer, request, err)
+		return
+	}
+
+	song, err = s.store.GetSong(id)
+	if err != nil {
+		slog.Error("error retrieving from db", "error", err.Error())
+		writeStoreError(writer, request, err)
+		return
+	}
+	writeSong(writer, request, song)
+}
+
+// удаление песни по id в корзину (см. trash.go)
+func (s *APIServer) deleteSongByID() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("delete song request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad song id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad song id")
+			return
+		}
+
+		version, ok := s.ifMatch(writer, request, id)
+		if !ok {
+			return
+		}
+
+		err = s.store.DeleteSongByID(id, version, actor(request))
+		if err != nil {
+			slog.Error("error deleting from database", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// проверяет данные песни из тела запроса: исполнитель и название обязательны,
+// дата - в одном из форматов validate.Date, ссылка - http(s) url.
+// При частичном обновлении (patch) поля со значением no_data не указаны и не проверяются
+func checkSong(v *validate.Validator, song db.Song, patch bool) {
+	given := func(value string) bool {
+		return !patch || value != "no_data"
+	}
+	if given(song.Group) {
+		v.Name("group", song.Group)
+	}
+	if given(song.SongName) {
+		v.Name("song", song.SongName)
+	}
+	if given(song.ReleaseDate) {
+		v.Date("releaseDate", song.ReleaseDate)
+	}
+	if given(song.Text) {
+		v.MaxLen("text", song.Text, validate.MaxTextLength)
+	}
+	if given(song.Link) {
+		v.URL("link", song.Link, "http", "https")
+	}
+}
+
+// id песни, исполнителя и т.д. из пути запроса
+func pathID(request *http.Request) (int64, error) {
+	return pathInt(request, "id")
+}
+
+// числовая переменная name из пути запроса
+func pathInt(request *http.Request, name string) (int64, error) {
+	return strconv.ParseInt(mux.Vars(request)[name], 10, 64)
+}
+
+// кодирует v в json и отправляет его с указанным кодом ответа
+func writeJSON(writer http.ResponseWriter, status int, v any) {
+	writer.Header().Set("Content-type", "application/json")
+	writer.WriteHeader(status)
+	err := json.NewEncoder(writer).Encode(v)
+	if err != nil {
+		slog.Error("error encoding response", "error", err.Error())
+	}
+}
@@ -0,0 +1,252 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"ApiServer/internal/app/validate"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// список тегов, параметр kind - только жанры (genre) или только обычные теги (tag)
+func (s *APIServer) listTags() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("list tags request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		kind := request.FormValue("kind")
+		var v validate.Validator
+		if kind != "" && !v.OneOf("kind", kind, db.TagKinds) {
+			writeInvalid(writer, request, v.Err())
+			return
+		}
+
+		tags, err := s.store.ListTags(kind)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeJSON(writer, 200, tags)
+	}
+}
+
+// тег вместе с поджанрами
+func (s *APIServer) getTag() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("get tag request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad tag id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad tag id")
+			return
+		}
+
+		tag, err := s.store.GetTag(id)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		writeJSON(writer, 200, tag)
+	}
+}
+
+func (s *APIServer) addTag() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("add tag request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		tag, ok := readTag(writer, request)
+		if !ok {
+			return
+		}
+
+		tag, err := s.store.AddTag(tag)
+		if err != nil {
+			slog.Error("error adding tag", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		writeJSON(writer, 201, tag)
+	}
+}
+
+// замена имени, вида и родителя тега
+func (s *APIServer) updateTag() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		defer request.Body.Close()
+		slog.Info("update tag request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad tag id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad tag id")
+			return
+		}
+
+		tag, ok := readTag(writer, request)
+		if !ok {
+			return
+		}
+
+		err = s.store.UpdateTag(id, tag)
+		if err != nil {
+			slog.Error("error updating tag", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		tag, err = s.store.GetTag(id)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeJSON(writer, 200, tag)
+	}
+}
+
+// удаление тега, он снимается со всех песен
+func (s *APIServer) deleteTag() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("delete tag request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad tag id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad tag id")
+			return
+		}
+
+		err = s.store.DeleteTag(id)
+		if err != nil {
+			slog.Error("error deleting tag", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+func (s *APIServer) songTags() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("song tags request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad song id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad song id")
+			return
+		}
+
+		tags, err := s.store.SongTags(id)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		writeJSON(writer, 200, tags)
+	}
+}
+
+// отмечает песню тегом и возвращает все теги песни
+func (s *APIServer) attachTag() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("attach tag request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		songID, tagID, ok := songTagIDs(writer, request)
+		if !ok {
+			return
+		}
+
+		err := s.store.AttachTag(songID, tagID)
+		if err != nil {
+			slog.Error("error attaching tag", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		tags, err := s.store.SongTags(songID)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeJSON(writer, 200, tags)
+	}
+}
+
+func (s *APIServer) detachTag() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("detach tag request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		songID, tagID, ok := songTagIDs(writer, request)
+		if !ok {
+			return
+		}
+
+		err := s.store.DetachTag(songID, tagID)
+		if err != nil {
+			slog.Error("error detaching tag", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// id песни и тега из пути запроса, при ошибке сам отвечает 400
+func songTagIDs(writer http.ResponseWriter, request *http.Request) (int64, int64, bool) {
+	songID, err := pathID(request)
+	if err != nil {
+		slog.Error("bad song id", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadParameter, "id", "bad song id")
+		return 0, 0, false
+	}
+	tagID, err := pathInt(request, "tagId")
+	if err != nil {
+		slog.Error("bad tag id", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadParameter, "tagId", "bad tag id")
+		return 0, 0, false
+	}
+	return songID, tagID, true
+}
+
+// читает данные тега из тела запроса, при ошибке сам отвечает 400
+// вид по умолчанию - обычный тег
+func readTag(writer http.ResponseWriter, request *http.Request) (db.Tag, bool) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		slog.Error("error reading request body", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", "error reading request body")
+		return db.Tag{}, false
+	}
+
+	tag := db.Tag{Kind: db.TagKindTag}
+	err = json.Unmarshal(body, &tag)
+	if err != nil {
+		slog.Error("unmarshal error", "error", err.Error())
+		writeProblem(writer, request, 400, codeBadBody, "", err.Error())
+		return db.Tag{}, false
+	}
+
+	tag.Name = strings.TrimSpace(tag.Name)
+	var v validate.Validator
+	if v.Name("name", tag.Name) {
+		// запятая разделяет теги в фильтре tag= списка песен
+		v.Check(!strings.Contains(tag.Name, ","), "name", validate.CodeBadValue, "tag name can't contain commas")
+	}
+	v.OneOf("kind", tag.Kind, db.TagKinds)
+	if err := v.Err(); err != nil {
+		writeInvalid(writer, request, err)
+		return db.Tag{}, false
+	}
+	return tag, true
+}
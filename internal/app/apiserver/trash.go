@@ -0,0 +1,115 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/validate"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// обработчики корзины (см. db.TrashedSong): удалённые песни можно посмотреть,
+// восстановить или удалить окончательно, не дожидаясь срока хранения
+
+// как часто фоновая очистка проверяет корзину (при более коротком сроке
+// хранения - с периодом, равным сроку)
+const trashPurgeInterval = time.Hour
+
+// песни в корзине, последние удалённые - первыми
+func (s *APIServer) listTrash() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("list trash request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		var v validate.Validator
+		offset, limit := request.FormValue("offset"), request.FormValue("limit")
+		checkPage(&v, offset, limit)
+		if err := v.Err(); err != nil {
+			writeInvalid(writer, request, err)
+			return
+		}
+
+		trash, err := s.store.ListTrash(offset, limit)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeInternalError(writer, request)
+			return
+		}
+
+		writeJSON(writer, 200, trash)
+	}
+}
+
+// возвращает песню из корзины со всеми её тегами, треками альбомов и записями плейлистов
+func (s *APIServer) restoreTrashed() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("restore song from trash request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad song id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad song id")
+			return
+		}
+
+		err = s.store.RestoreSong(id, actor(request))
+		if err != nil {
+			slog.Error("error restoring song", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+
+		song, err := s.store.GetSong(id)
+		if err != nil {
+			slog.Error("error retrieving from db", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writeSong(writer, request, song)
+	}
+}
+
+// окончательно удаляет песню из корзины вместе с её историей
+func (s *APIServer) purgeTrashed() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		slog.Info("purge song request", "from", request.RemoteAddr, "to", request.Host+request.URL.String())
+
+		id, err := pathID(request)
+		if err != nil {
+			slog.Error("bad song id", "error", err.Error())
+			writeProblem(writer, request, 400, codeBadParameter, "id", "bad song id")
+			return
+		}
+
+		err = s.store.PurgeSong(id)
+		if err != nil {
+			slog.Error("error purging song", "error", err.Error())
+			writeStoreError(writer, request, err)
+			return
+		}
+		writer.WriteHeader(204)
+	}
+}
+
+// фоновая очистка корзины: песни, пролежавшие в ней дольше retention,
+// удаляются окончательно. Работает до отмены ctx
+func (s *APIServer) purgeTrash(ctx context.Context, retention time.Duration) {
+	interval := min(retention, trashPurgeInterval)
+	slog.Info("trash purge is enabled", "retention", retention.String(), "interval", interval.String())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := s.store.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("error purging trash", "error", err.Error())
+		} else if purged > 0 {
+			slog.Info("trash purged", "songs", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
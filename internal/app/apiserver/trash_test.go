@@ -0,0 +1,114 @@
+package apiserver
+
+import (
+	"ApiServer/internal/app/db"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// песни в корзине: id и исполнители
+func trashGroups(t *testing.T, ts *httptest.Server) map[int64]string {
+	t.Helper()
+	status, body := call(t, ts, "GET", "/trash", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var trash []db.TrashedSong
+	err := json.Unmarshal([]byte(body), &trash)
+	if err != nil {
+		t.Fatalf("decode trash: %v", err)
+	}
+	groups := make(map[int64]string, len(trash))
+	for _, song := range trash {
+		groups[song.ID] = song.Group
+	}
+	return groups
+}
+
+func TestTrash(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "Muse/Hysteria")
+
+	stale := http.Header{"If-Match": {`"2"`}}
+	status, body := call(t, ts, "DELETE", "/songs/1", "", stale)
+	checkStatus(t, status, body, wantStatus{412, codePreconditionFailed})
+	status, body = call(t, ts, "DELETE", "/songs/1", "", http.Header{"If-Match": {`"1"`}})
+	checkStatus(t, status, body, wantStatus{status: 204})
+	status, body = call(t, ts, "GET", "/songs/1", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+	if got := trashGroups(t, ts); len(got) != 1 || got[1] != "Muse" {
+		t.Errorf("trash = %v, want song 1 of Muse", got)
+	}
+
+	// название песни в корзине занято, ответ указывает, что с ней сделать
+	status, body = call(t, ts, "POST", "/library/add", `{"group":"Muse","song":"Uprising"}`, nil)
+	checkStatus(t, status, body, wantStatus{409, codeInTrash})
+	if !strings.Contains(body, "/trash/1/restore") {
+		t.Errorf("in_trash problem doesn't point to the trashed song: %s", body)
+	}
+
+	status, body = call(t, ts, "POST", "/trash/1/restore", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var song db.Song
+	json.Unmarshal([]byte(body), &song)
+	if song.SongName != "Uprising" || song.Version != 2 {
+		t.Errorf("restored song = %+v, want Uprising version 2", song)
+	}
+	status, body = call(t, ts, "POST", "/trash/1/restore", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+
+	status, body = call(t, ts, "GET", "/songs/1/revisions", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var revs []db.Revision
+	json.Unmarshal([]byte(body), &revs)
+	if len(revs) == 0 || revs[0].Version != 2 || revs[0].ChangedBy != "anonymous" ||
+		len(revs[0].Changes) != 1 || revs[0].Changes[0] != (db.Change{Field: "deleted", Old: "true", New: "false"}) {
+		t.Errorf("revisions = %+v, want the restore as version 2", revs)
+	}
+
+	call(t, ts, "DELETE", "/songs/2", "", nil)
+	status, body = call(t, ts, "DELETE", "/trash/2", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 204})
+	status, body = call(t, ts, "DELETE", "/trash/2", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+	status, body = call(t, ts, "DELETE", "/trash/1", "", nil)
+	checkStatus(t, status, body, wantStatus{404, codeNotFound})
+	status, body = call(t, ts, "GET", "/trash?limit=-1", "", nil)
+	checkStatus(t, status, body, wantStatus{400, codeValidation})
+	if got := trashGroups(t, ts); len(got) != 0 {
+		t.Errorf("trash after purge = %v, want empty", got)
+	}
+}
+
+// песни удалённого исполнителя и дубликаты, отброшенные при объединении,
+// попадают в корзину и восстанавливаются
+func TestTrashArtists(t *testing.T) {
+	ts := newLibraryServer(t, "Muse/Uprising", "MUSE/Uprising", "MUSE/Starlight", "Queen/Innuendo")
+
+	status, body := call(t, ts, "POST", "/artists/1/merge", `{"sources":[2],"policy":"keep_target"}`, nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	var result db.MergeResult
+	json.Unmarshal([]byte(body), &result)
+	if result.Moved != 1 || len(result.Dropped) != 1 || result.Dropped[0] != 2 {
+		t.Errorf("merge result = %+v, want Starlight moved and song 2 dropped", result)
+	}
+
+	status, body = call(t, ts, "DELETE", "/artists/3", "", nil)
+	checkStatus(t, status, body, wantStatus{409, codeConflict})
+	status, body = call(t, ts, "DELETE", "/artists/3?cascade=true", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 204})
+
+	want := map[int64]string{2: "MUSE", 4: "Queen"}
+	if got := trashGroups(t, ts); len(got) != 2 || got[2] != want[2] || got[4] != want[4] {
+		t.Errorf("trash = %v, want %v", got, want)
+	}
+
+	// у исполнителя уже есть Uprising
+	status, body = call(t, ts, "POST", "/trash/2/restore", "", nil)
+	checkStatus(t, status, body, wantStatus{409, codeAlreadyExists})
+	status, body = call(t, ts, "POST", "/trash/4/restore", "", nil)
+	checkStatus(t, status, body, wantStatus{status: 200})
+	if got := libraryNames(t, ts, "?author=Queen"); len(got) != 1 || got[0] != "Innuendo" {
+		t.Errorf("songs of Queen after restore = %q", got)
+	}
+}
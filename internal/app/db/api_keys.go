@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+)
+
+// Role - роль владельца ключа, каждая следующая роль включает права предыдущих
+type Role string
+
+const (
+	// чтение библиотеки
+	RoleReader Role = "reader"
+	// изменение библиотеки
+	RoleEditor Role = "editor"
+	// операции, затрагивающие много данных сразу (объединение и удаление исполнителей)
+	RoleAdmin Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleReader: 1, RoleEditor: 2, RoleAdmin: 3}
+
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// Allows проверяет, достаточно ли роли r для действия, требующего роль required
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[required]
+}
+
+// ключ доступа к api, сам ключ не хранится и известен только при создании
+type APIKey struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Role      Role       `json:"role"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// префикс выдаваемых ключей, упрощает их поиск в логах и конфигах
+const apiKeyPrefix = "mlk_"
+
+// NewAPIKeySecret создаёт случайный ключ и возвращает его вместе с хешем для хранения
+func NewAPIKeySecret() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	secret = apiKeyPrefix + hex.EncodeToString(b)
+	return secret, HashAPIKey(secret), nil
+}
+
+// HashAPIKey - хеш, по которому ключ ищется в хранилище
+// ключи случайные и длинные, поэтому медленный хеш с солью не нужен
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// все ключи, включая отозванные, в порядке создания
+func (db *Database) ListAPIKeys() ([]APIKey, error) {
+	rows, err := db.dbConn.Query(context.Background(), `select key_id, name, role, created_at, revoked_at
+from api_keys order by key_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0, 16)
+	for rows.Next() {
+		var k APIKey
+		err = rows.Scan(&k.ID, &k.Name, &k.Role, &k.CreatedAt, &k.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// сохраняет ключ с хешем hash (см. NewAPIKeySecret)
+func (db *Database) AddAPIKey(k APIKey, hash string) (APIKey, error) {
+	err := db.dbConn.QueryRow(context.Background(), `insert into api_keys (name, key_hash, role) values ($1, $2, $3)
+returning key_id, created_at`, k.Name, hash, k.Role).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return APIKey{}, mapError(err)
+	}
+	return k, nil
+}
+
+// действующий (не отозванный) ключ по его хешу
+func (db *Database) FindAPIKey(hash string) (APIKey, error) {
+	var k APIKey
+	err := db.dbConn.QueryRow(context.Background(), `select key_id, name, role, created_at from api_keys
+where key_hash=$1 and revoked_at is null`, hash).Scan(&k.ID, &k.Name, &k.Role, &k.CreatedAt)
+	if err != nil {
+		return APIKey{}, mapError(err)
+	}
+	return k, nil
+}
+
+// отзыв ключа, отозванный ключ остаётся в списке
+func (db *Database) RevokeAPIKey(id int64) error {
+	tag, err := db.dbConn.Exec(context.Background(), `update api_keys set revoked_at=now()
+where key_id=$1 and revoked_at is null`, id)
+	slog.Debug("revoking api key", "db response", tag.String())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// добавляет токен с идентификатором jti в список отозванных до момента expires,
+// заодно удаляя из списка токены, срок которых уже истёк
+func (db *Database) RevokeToken(jti string, expires time.Time) error {
+	ctx := context.Background()
+	_, err := db.dbConn.Exec(ctx, `delete from revoked_tokens where expires_at < now()`)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbConn.Exec(ctx, `insert into revoked_tokens (jti, expires_at) values ($1, $2)
+on conflict do nothing`, jti, expires)
+	return err
+}
+
+// отозван ли токен jti или ключ keyID, по которому он выдан:
+// после отзыва ключа его токены не принимаются, не дожидаясь истечения их срока
+func (db *Database) TokenRevoked(jti string, keyID int64) (bool, error) {
+	var revoked bool
+	err := db.dbConn.QueryRow(context.Background(), `select exists(select 1 from revoked_tokens where jti=$1)
+or not exists(select 1 from api_keys where key_id=$2 and revoked_at is null)`, jti, keyID).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,380 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrArtistHasSongs возвращается при удалении исполнителя, у которого
+	// ещё остались песни (в том числе в корзине), без каскадного удаления.
+	// Песни в корзине называются в ошибке отдельно (см. artistSongsError)
+	ErrArtistHasSongs = errors.New("artist still has songs")
+	// ErrMergeConflict возвращается при объединении исполнителей с политикой MergeFail,
+	// если у них есть песни с одинаковым названием
+	ErrMergeConflict = errors.New("merged artists have songs with the same name")
+)
+
+// MergePolicy определяет, какая из двух одноимённых песен остаётся
+// при объединении исполнителей
+type MergePolicy string
+
+const (
+	// остаётся песня, добавленная позже (с большим id)
+	MergeKeepNewest MergePolicy = "keep_newest"
+	// остаётся песня исполнителя, в которого происходит объединение
+	MergeKeepTarget MergePolicy = "keep_target"
+	// объединение отменяется
+	MergeFail MergePolicy = "fail"
+)
+
+func (p MergePolicy) Valid() bool {
+	switch p {
+	case MergeKeepNewest, MergeKeepTarget, MergeFail:
+		return true
+	}
+	return false
+}
+
+// результат объединения исполнителей
+type MergeResult struct {
+	Target Artist `json:"target"`
+	// сколько песен перенесено к исполнителю Target
+	Moved int `json:"moved"`
+	// id песен, перенесённых в корзину как дубликаты
+	Dropped []int64 `json:"dropped"`
+}
+
+// выбирает, какую из двух одноимённых песен отбросить при объединении
+func (p MergePolicy) drop(targetSong, sourceSong int64) (int64, error) {
+	switch p {
+	case MergeKeepTarget:
+		return sourceSong, nil
+	case MergeKeepNewest:
+		if sourceSong > targetSong {
+			return targetSong, nil
+		}
+		return sourceSong, nil
+	default:
+		return 0, ErrMergeConflict
+	}
+}
+
+// исполнитель (строка таблицы groups) с количеством его песен
+type Artist struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	SongCount int     `json:"songCount"`
+	Songs     Library `json:"songs,omitempty"`
+}
+
+// список исполнителей, отсортированный по имени, с количеством песен у каждого
+func (db *Database) ListArtists(offset, limit string) ([]Artist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), `select groups.author_id, groups.author_name, count(songs.song_id)
+from groups left join songs on songs.author_id = groups.author_id and songs.deleted_at is null
+group by groups.author_id, groups.author_name
+order by groups.author_name offset $1 limit $2`, offsetInt, nullLimit(limitInt))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := make([]Artist, 0, 64)
+	for rows.Next() {
+		var a Artist
+		err = rows.Scan(&a.ID, &a.Name, &a.SongCount)
+		if err != nil {
+			return nil, err
+		}
+		artists = append(artists, a)
+	}
+	return artists, rows.Err()
+}
+
+// исполнитель без песен по имени, в том числе по прежнему имени
+// объединённого исполнителя (см. authorByName)
+func (db *Database) FindArtist(name string) (Artist, error) {
+	var a Artist
+	err := db.dbConn.QueryRow(context.Background(), `select author_id, author_name from groups
+where author_id=`+authorByName("$1"), name).Scan(&a.ID, &a.Name)
+	if err != nil {
+		return Artist{}, mapError(err)
+	}
+	return a, nil
+}
+
+// исполнитель вместе со всеми его песнями
+func (db *Database) GetArtist(id int) (Artist, error) {
+	a := Artist{ID: id}
+	err := db.dbConn.QueryRow(context.Background(), `select author_name from groups where author_id=$1`, id).Scan(&a.Name)
+	if err != nil {
+		return Artist{}, mapError(err)
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), `select songs.song_id, songs.song_name,
+coalesce(songs.release_date::text, ''), coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version
+from songs where author_id=$1 and deleted_at is null order by songs.song_name`, id)
+	if err != nil {
+		return Artist{}, err
+	}
+	defer rows.Close()
+
+	a.Songs = make(Library, 0, 16)
+	for rows.Next() {
+		s := Song{Group: a.Name}
+		err = rows.Scan(&s.ID, &s.SongName, &s.ReleaseDate, &s.Text, &s.Link, &s.Version)
+		if err != nil {
+			return Artist{}, err
+		}
+		a.Songs = append(a.Songs, s)
+	}
+	a.SongCount = len(a.Songs)
+	return a, rows.Err()
+}
+
+// добавление исполнителя без песен
+func (db *Database) AddArtist(name string) (Artist, error) {
+	a := Artist{Name: name}
+	err := db.dbConn.QueryRow(context.Background(), `insert into groups (author_name) values ($1) returning author_id`, name).Scan(&a.ID)
+	if err != nil {
+		return Artist{}, mapError(err)
+	}
+	return a, nil
+}
+
+// переименование исполнителя по его id
+func (db *Database) RenameArtist(id int, name, by string) error {
+	return db.renameArtist(`select author_id, author_name from groups where author_id=$1 for update`, id, name, by)
+}
+
+// переименование исполнителя, найденного по key запросом find
+// (select author_id, author_name ... for update), смена имени записывается
+// в историю исполнителя от имени by. Имя исполнителя входит в данные его песен,
+// поэтому их версии тоже увеличиваются, а в их историю записывается смена group
+func (db *Database) renameArtist(find string, key any, name, by string) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	var old string
+	err = tx.QueryRow(ctx, find, key).Scan(&id, &old)
+	if err != nil {
+		return mapError(err)
+	}
+	_, err = tx.Exec(ctx, `update groups set author_name=$1 where author_id=$2`, name, id)
+	if err != nil {
+		return mapError(err)
+	}
+	if old != name {
+		_, err = tx.Exec(ctx, `insert into song_history (song_id, version, changed_by, field, old_value, new_value)
+select song_id, version + 1, $2, 'group', $3, $4 from songs where author_id=$1`, id, by, old, name)
+		if err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `update songs set version=version+1 where author_id=$1`, id)
+		slog.Debug("renaming artist", "author_id", id, "songs", tag.RowsAffected())
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `insert into artist_history (author_id, version, changed_by, field, old_value, new_value)
+select $1, coalesce(max(version), 1) + 1, $2, 'name', $3, $4 from artist_history where author_id=$1`, id, by, old, name)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// удаление исполнителя. Если у него есть песни, они удаляются вместе с ним
+// только при cascade, иначе возвращается ErrArtistHasSongs. При каскадном
+// удалении песни уходят в корзину от имени by и отвязываются от исполнителя
+// вместе с теми, что уже были в корзине (см. trash.go)
+func (db *Database) DeleteArtist(id int, cascade bool, by string) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var songs, trashed int
+	err = tx.QueryRow(ctx, `select count(*), count(deleted_at) from songs where author_id=$1`, id).Scan(&songs, &trashed)
+	if err != nil {
+		return err
+	}
+	if songs > 0 {
+		if !cascade {
+			return artistSongsError(songs-trashed, trashed)
+		}
+		_, err = tx.Exec(ctx, `update songs set deleted_at=now(), deleted_by=$2
+where author_id=$1 and deleted_at is null`, id, by)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, detachTrashed+`songs.author_id=$1`, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `delete from groups where author_id=$1`, id)
+	slog.Debug("deleting artist", "db response", tag.String(), "songs trashed", songs-trashed)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+// ErrArtistHasSongs с пояснением: песни в корзине не видны у исполнителя,
+// поэтому, если остались только они, ошибка говорит об этом отдельно
+func artistSongsError(live, trashed int) error {
+	if live == 0 {
+		return fmt.Errorf("%w: %d songs of the artist are in the trash, purge them or use cascade", ErrArtistHasSongs, trashed)
+	}
+	if trashed > 0 {
+		return fmt.Errorf("%w: %d songs and %d more in the trash", ErrArtistHasSongs, live, trashed)
+	}
+	return ErrArtistHasSongs
+}
+
+// отвязывает песни из корзины, выбранные условием, дописанным к запросу, от исполнителя,
+// сохраняя его имя (см. trash.go). Общий для postgres и sqlite
+const detachTrashed = `update songs set deleted_group=(select author_name from groups where groups.author_id=songs.author_id),
+author_id=null where songs.deleted_at is not null and `
+
+// limit < 0 (без ограничения) передаётся в запрос как null, "limit null" = без ограничения
+func nullLimit(limit int) any {
+	if limit < 0 {
+		return nil
+	}
+	return limit
+}
+
+// объединяет исполнителей sources с исполнителем target в одной транзакции:
+// все их песни переходят к target, одноимённые песни разрешаются согласно policy
+// (отброшенная песня уходит в корзину от имени by),
+// сами исполнители удаляются, а их имена остаются псевдонимами target.
+// Смена исполнителя перенесённых песен записывается в их историю от имени by
+func (db *Database) MergeArtists(target int, sources []int, policy MergePolicy, by string) (MergeResult, error) {
+	ctx := context.Background()
+	result := MergeResult{Dropped: make([]int64, 0)}
+
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокируем исполнителя, чтобы его не удалили во время объединения
+	var targetName string
+	err = tx.QueryRow(ctx, `select author_name from groups where author_id=$1 for update`, target).Scan(&targetName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return result, fmt.Errorf("%w: artist %d", ErrNotFound, target)
+		}
+		return result, err
+	}
+
+	for _, source := range sources {
+		if source == target {
+			continue
+		}
+
+		var name string
+		err = tx.QueryRow(ctx, `select author_name from groups where author_id=$1 for update`, source).Scan(&name)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return result, fmt.Errorf("%w: artist %d", ErrNotFound, source)
+			}
+			return result, err
+		}
+
+		// пары одноимённых песен не из корзины: (песня target, песня source)
+		rows, err := tx.Query(ctx, `select t.song_id, s.song_id from songs s
+inner join songs t on t.song_name = s.song_name and t.author_id = $1
+where s.author_id = $2 and s.deleted_at is null and t.deleted_at is null`, target, source)
+		if err != nil {
+			return result, err
+		}
+		drop, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (int64, error) {
+			var targetSong, sourceSong int64
+			err := row.Scan(&targetSong, &sourceSong)
+			if err != nil {
+				return 0, err
+			}
+			return policy.drop(targetSong, sourceSong)
+		})
+		if err != nil {
+			return result, err
+		}
+
+		if len(drop) > 0 {
+			_, err = tx.Exec(ctx, `update songs set deleted_at=now(), deleted_by=$2 where song_id = any($1)`, drop, by)
+			if err != nil {
+				return result, err
+			}
+			result.Dropped = append(result.Dropped, drop...)
+		}
+		// песни из корзины не переносятся, а отвязываются от исполнителя: все песни
+		// source и песни target, чьё название займёт перенесённая песня
+		_, err = tx.Exec(ctx, detachTrashed+`(songs.author_id=$2 or songs.author_id=$1 and songs.song_name in
+(select song_name from songs where author_id=$2 and deleted_at is null))`, target, source)
+		if err != nil {
+			return result, err
+		}
+
+		_, err = tx.Exec(ctx, `insert into song_history (song_id, version, changed_by, field, old_value, new_value)
+select song_id, version + 1, $2, 'group', $3, $4 from songs where author_id=$1`, source, by, name, targetName)
+		if err != nil {
+			return result, err
+		}
+		tag, err := tx.Exec(ctx, `update songs set author_id=$1, version=version+1 where author_id=$2`, target, source)
+		if err != nil {
+			return result, err
+		}
+		result.Moved += int(tag.RowsAffected())
+
+		_, err = tx.Exec(ctx, `update artist_aliases set author_id=$1 where author_id=$2`, target, source)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.Exec(ctx, `update albums set author_id=$1 where author_id=$2`, target, source)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.Exec(ctx, `delete from groups where author_id=$1`, source)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.Exec(ctx, `insert into artist_aliases (alias, author_id) values ($1, $2)
+on conflict (alias) do update set author_id=excluded.author_id`, name, target)
+		if err != nil {
+			return result, err
+		}
+		slog.Debug("artist merged", "source", source, "target", target, "alias", name)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	result.Target, err = db.GetArtist(target)
+	return result, err
+}
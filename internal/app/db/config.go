@@ -5,24 +5,48 @@ import (
 	"os"
 )
 
+// поддерживаемые значения DB_DRIVER
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+	DriverSQLite   = "sqlite"
+)
+
 type Config struct {
+	Driver   string
 	Host     string
 	Port     string
 	DBName   string
 	User     string
 	Password string
+	// путь к файлу базы данных sqlite
+	Path string
 }
 
 func NewConfig() *Config {
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = DriverPostgres
+	}
 	return &Config{
+		Driver:   driver,
 		Host:     os.Getenv("DB_HOST"),
 		Port:     os.Getenv("DB_PORT"),
 		DBName:   os.Getenv("DB_NAME"),
 		User:     os.Getenv("DB_USER"),
 		Password: os.Getenv("DB_PASSWORD"),
+		Path:     os.Getenv("DB_PATH"),
 	}
 }
 
 func (c *Config) ConnString() string {
 	return fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s", c.Host, c.Port, c.DBName, c.User, c.Password)
 }
+
+// строка подключения к sqlite: внешние ключи включены, при блокировке
+// базы другим соединением ждём до 5 секунд вместо немедленной ошибки.
+// Транзакции начинаются с begin immediate: транзакция, которая сначала читает,
+// а потом пишет, иначе получает SQLITE_BUSY сразу, не дожидаясь busy_timeout
+func (c *Config) SQLiteDSN() string {
+	return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate", c.Path)
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrBadCursor возвращается для курсора, который не удалось разобрать
+// или который выдан для другой сортировки
+var ErrBadCursor = errors.New("bad cursor")
+
+// Cursor - граница страницы списка песен для постраничного вывода по ключу (keyset):
+// значения полей сортировки крайней песни страницы. В отличие от offset
+// страница не сдвигается при добавлении и удалении песен и не требует
+// пропуска всех предыдущих строк
+type Cursor struct {
+	// сортировка, для которой выдан курсор
+	Sort string `json:"s"`
+	// true - страница перед песней, иначе - после неё
+	Before      bool   `json:"b,omitempty"`
+	ID          int64  `json:"i"`
+	Group       string `json:"a"`
+	SongName    string `json:"n"`
+	ReleaseDate string `json:"d,omitempty"`
+}
+
+// курсор страницы после (before = false) или перед песней s в сортировке sort
+func NewCursor(sort string, s Song, before bool) Cursor {
+	return Cursor{Sort: sort, Before: before, ID: s.ID, Group: s.Group, SongName: s.SongName, ReleaseDate: s.ReleaseDate}
+}
+
+// String кодирует курсор в непрозрачную для клиента строку
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor разбирает строку, полученную от Cursor.String
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("%w: %w", ErrBadCursor, err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%w: %w", ErrBadCursor, err)
+	}
+	if _, ok := sortKeys(c.Sort); !ok {
+		return c, fmt.Errorf("%w: unknown sort %q", ErrBadCursor, c.Sort)
+	}
+	return c, nil
+}
+
+// песня на границе страницы, значения полей которой сравниваются с песнями списка
+func (c Cursor) song() Song {
+	return Song{ID: c.ID, Group: c.Group, SongName: c.SongName, ReleaseDate: c.ReleaseDate}
+}
+
+// условие "песня идёт после (перед) курсором" в порядке songsOrder:
+// (k1 > v1) or (k1 = v1 and k2 > v2) or ... по всем колонкам сортировки.
+// Отсутствующая дата сравнивается как пустая строка - песни без даты
+// и так отделены от остальных колонкой release_date is null
+func (c Cursor) sql(w *whereClause, d dialect) string {
+	values := map[string]struct {
+		key   string
+		value any
+	}{
+		"(songs.release_date is null)": {"(songs.release_date is null)", c.ReleaseDate == ""},
+		"songs.release_date":           {"coalesce(" + d.releaseDate + ", '')", c.ReleaseDate},
+		"groups.author_name":           {"groups.author_name", c.Group},
+		"songs.song_name":              {"songs.song_name", c.SongName},
+		"songs.song_id":                {"songs.song_id", c.ID},
+	}
+
+	var conds, equal []string
+	seen := make(map[string]bool)
+	for _, t := range songsOrderTerms(c.Sort) {
+		// колонки сортировки могут повторяться в конце порядка, повторы ничего не меняют
+		if seen[t.column] {
+			continue
+		}
+		seen[t.column] = true
+
+		v := values[t.column]
+		arg := w.arg(v.value)
+		op := " > "
+		if t.desc != c.Before {
+			op = " < "
+		}
+		cond := append(slices.Clip(equal), v.key+op+arg)
+		conds = append(conds, "("+strings.Join(cond, " and ")+")")
+		equal = append(equal, v.key+" = "+arg)
+	}
+	return "(" + strings.Join(conds, " or ") + ")"
+}
+
+// песня s идёт после (перед) курсором, повторяет sql для хранилища в памяти
+func (c Cursor) match(s Song) bool {
+	less := songLess(c.Sort)
+	if c.Before {
+		return less(s, c.song())
+	}
+	return less(c.song(), s)
+}
@@ -5,30 +5,67 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 	"log/slog"
-	"strconv"
+	"slices"
 	"strings"
 )
 
 type Song struct {
+	ID          int64  `json:"id,omitempty"`
 	Group       string `json:"group,omitempty"`
 	SongName    string `json:"song,omitempty"`
 	ReleaseDate string `json:"releaseDate,omitempty"`
 	Text        string `json:"text,omitempty"`
 	Link        string `json:"link,omitempty"`
+	Version     int64  `json:"version,omitempty"`
 }
 
 type Library []Song
 
+// параметры выборки списка песен
+type ListParams struct {
+	// фильтры на точное совпадение полей песни, пустое поле - без фильтрации
+	Filter Song
+	// id альбома, 0 - без фильтрации
+	Album int64
+	// имена тегов или жанров, жанр включает все свои поджанры
+	Tags []string
+	// TagModeAny - песня отмечена хотя бы одним из тегов, TagModeAll - всеми
+	TagMode string
+	// дерево правил умного плейлиста, nil - без фильтрации
+	Rule *Rule
+	// порог сходства (0..1] для нечёткого сравнения исполнителя и названия песни
+	// по триграммам (как pg_trgm), 0 - точное совпадение. Без сортировки
+	// песни упорядочиваются по сходству
+	Similarity float64
+	// поле сортировки (см. ValidSort), по умолчанию - исполнитель и название
+	Sort string
+	// поля песни, которые нужно выбрать (см. SongFields), nil - все поля
+	Fields []string
+	// граница страницы при выводе по ключу, nil - страница задаётся Offset.
+	// Песни возвращаются в порядке сортировки и для страницы перед курсором
+	Cursor *Cursor
+	Offset string
+	Limit  string
+}
+
+const (
+	TagModeAny = "any"
+	TagModeAll = "all"
+)
+
 type Database struct {
 	config *Config
 	dbConn *pgxpool.Pool
 }
 
-const targetDBver = 20250124172213
+// версия схемы, общая для postgres и sqlite: у каждой миграции postgres
+// есть миграция sqlite с той же версией
+const targetDBver = 20261017010000
 
 func New(config *Config) *Database {
 	return &Database{config: config}
@@ -36,7 +73,15 @@ func New(config *Config) *Database {
 
 // открывает соединение с базой данных
 func (db *Database) Open() error {
-	dbConn, err := pgxpool.New(context.Background(), db.config.ConnString())
+	poolConfig, err := pgxpool.ParseConfig(db.config.ConnString())
+	if err != nil {
+		return err
+	}
+	// формат даты задаётся в параметрах каждого подключения пула, set datestyle
+	// подействовал бы только на одно из них. Даты передаются в запросы
+	// в формате yyyy-mm-dd (см. normalizeDate) и так же возвращаются
+	poolConfig.ConnConfig.RuntimeParams["datestyle"] = "iso,dmy"
+	dbConn, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return err
 	}
@@ -52,13 +97,6 @@ func (db *Database) Open() error {
 
 	db.dbConn = dbConn
 
-	// устанавливаем формат даты на текущую сессию подключения
-	// для более удобной работы с датами
-	_, err = db.dbConn.Exec(context.Background(), `set datestyle to iso,dmy`)
-	if err != nil {
-		return err
-	}
-
 	err = db.fixDBVersion()
 	if err != nil {
 		return err
@@ -69,6 +107,10 @@ func (db *Database) Open() error {
 
 // устанавливает заданную версию базы данных
 func (db *Database) fixDBVersion() error {
+	err := goose.SetDialect("postgres")
+	if err != nil {
+		return err
+	}
 	dbSQL := stdlib.OpenDBFromPool(db.dbConn)
 	ver, err := goose.GetDBVersion(dbSQL)
 	if err != nil {
@@ -90,104 +132,161 @@ func (db *Database) fixDBVersion() error {
 }
 
 // выдает все песни, удовлетворяющие параметрам фильтрации (если они есть)
-func (db *Database) ListAllLibrary(s Song, offset, limit string) (Library, error) {
-	q := `select groups.author_name, songs.song_name, songs.release_date::text, songs.song_text, songs.link 
-from songs inner join groups using (author_id) where (
-		($1 = '' or groups.author_name = $1) and
-		($2 = '' or songs.song_name = $2) and
-		($3 = '' or songs.release_date::text = $3) and
-		($4 = '' or songs.song_text = $4) and
-		($5 = '' or songs.link = $5)) order by groups.author_name, songs.song_name`
-
-	if offset != "" {
-		offsetInt, err := strconv.Atoi(offset)
-		if err != nil {
-			return nil, err
-		}
-		q = q + fmt.Sprintf(" offset %d", offsetInt)
+func (db *Database) ListAllLibrary(p ListParams) (Library, error) {
+	offsetInt, limitInt, err := parsePage(p.Offset, p.Limit)
+	if err != nil {
+		return nil, err
 	}
 
-	if limit != "" {
-		limitInt, err := strconv.Atoi(limit)
-		if err != nil {
-			return nil, err
-		}
-		q = q + fmt.Sprintf(" limit %d", limitInt)
-	}
+	w := songsWhere(p, pgDialect)
+	columns, dest := songsProjection(p.Fields, pgDialect)
+	q := `select ` + columns + `
+from songs inner join groups using (author_id) where ` + w.String() + `
+order by ` + songsListOrder(p, w) + ` offset ` + w.arg(offsetInt) + ` limit ` + w.arg(nullLimit(limitInt))
 
-	slog.Debug("list all library database query", "filter params", s, "offset", offset, "limit", limit)
+	slog.Debug("list all library database query", "params", p)
 
-	rows, err := db.dbConn.Query(context.Background(), q, s.Group, s.SongName, s.ReleaseDate, s.Text, s.Link)
-	defer rows.Close()
+	// песни будут возвращены слайсом, чтобы можно было их закодировать
+	// в один json и отправить клиенту
+	lib := make(Library, 0, 64)
+	err = db.querySongs(p, q, w.args, func(rows pgx.Rows) error {
+		var s Song
+		err := rows.Scan(dest(&s)...)
+		lib = append(lib, s)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	if p.Cursor != nil && p.Cursor.Before {
+		slices.Reverse(lib)
+	}
+	return lib, nil
+}
 
-	// песни будут возвращены слайсом, чтобы можно было их закодировать
-	// в один json и отправить клиенту
-	sTmp := Song{}
-	lib := make(Library, 0, 64)
+// количество песен, удовлетворяющих параметрам фильтрации, без учёта страницы
+func (db *Database) CountLibrary(p ListParams) (int, error) {
+	p.Cursor = nil
+	w := songsWhere(p, pgDialect)
+	q := `select count(*) from songs inner join groups using (author_id) where ` + w.String()
 
+	var count int
+	err := db.querySongs(p, q, w.args, func(rows pgx.Rows) error {
+		return rows.Scan(&count)
+	})
+	return count, err
+}
+
+// выполняет запрос списка песен и передаёт каждую строку в scan.
+// Для нечёткого поиска запрос выполняется в транзакции, в которой
+// порог оператора % (pg_trgm) равен p.Similarity - так условие использует индекс
+func (db *Database) querySongs(p ListParams, query string, args []any, scan func(pgx.Rows) error) error {
+	ctx := context.Background()
+	var conn interface {
+		Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	} = db.dbConn
+
+	if p.Similarity > 0 {
+		tx, err := db.dbConn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		_, err = tx.Exec(ctx, `select set_config('pg_trgm.similarity_threshold', $1, true)`, similarityLiteral(p.Similarity))
+		if err != nil {
+			return err
+		}
+		conn = tx
+	}
+
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 	for rows.Next() {
-		err = rows.Scan(&sTmp.Group, &sTmp.SongName, &sTmp.ReleaseDate, &sTmp.Text, &sTmp.Link)
+		err = scan(rows)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		lib = append(lib, sTmp)
 	}
-	return lib, nil
+	return rows.Err()
 }
 
-// удаление определенной песни из базы данных
-func (db *Database) DeleteSong(author_name, songName string) (string, error) {
-	tag, err := db.dbConn.Exec(context.Background(), `delete from songs where song_name=$1 and author_id in (select author_id from groups where author_name=$2)`, songName, author_name)
+// удаление определенной песни в корзину от имени by (см. trash.go)
+// возвращает количество удалённых песен
+func (db *Database) DeleteSong(author_name, songName, by string) (int64, error) {
+	tag, err := db.dbConn.Exec(context.Background(), `update songs set deleted_at=now(), deleted_by=$3
+where song_name=$1 and author_id=`+authorByName("$2")+` and deleted_at is null`, songName, author_name, by)
 	slog.Debug("deleting from DB", "db response", tag.String())
 	if err != nil {
-		return "", err
+		return 0, err
 	}
 
-	return tag.String(), nil
+	return tag.RowsAffected(), nil
+}
+
+// удаление песни в корзину по её id, если её версия - version (0 - любая)
+func (db *Database) DeleteSongByID(id, version int64, by string) error {
+	tag, err := db.dbConn.Exec(context.Background(), `update songs set deleted_at=now(), deleted_by=$3
+where song_id=$1 and ($2::bigint = 0 or version=$2) and deleted_at is null`, id, version, by)
+	slog.Debug("deleting from DB", "db response", tag.String())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return songChangeError(db.GetSong, id)
+	}
+	return nil
 }
 
 // добавление песни в базу данных
+// исполнитель добавляется в той же транзакции: если песню добавить не удалось
+// (например, она уже есть), новый исполнитель без песен не остаётся
 func (db *Database) AddSong(s Song) error {
-	var id int
-	// проверяем, есть ли уже такой исполнитель в бд
-	// если есть, получаем его id
-	err := db.dbConn.QueryRow(context.Background(), `select (author_id) from groups where author_name=$1`, "author_name_test_1").Scan(&id)
+	date, err := normalizeDate(s.ReleaseDate)
 	if err != nil {
-		if !errors.Is(err, pgx.ErrNoRows) {
-			return err
-		}
-		// если нет - добавляем его, с получением его id
-		err = db.dbConn.QueryRow(context.Background(), `insert into groups (author_name) values ($1) returning author_id`, s.Group).Scan(&id)
-		if err != nil {
-			return err
-		}
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := getAuthorID(ctx, tx, s.Group)
+	if err != nil {
+		return err
+	}
+	var trashed int64
+	err = tx.QueryRow(ctx, `select song_id from songs where author_id=$1 and song_name=$2 and deleted_at is not null`,
+		id, s.SongName).Scan(&trashed)
+	if err == nil {
+		return &TrashedSongError{ID: trashed}
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
 	}
 
 	// добавляем данные о песне в бд с указанием полученного выше id исполнителя
-	tag, err := db.dbConn.Exec(context.Background(), `insert into songs (author_id, song_name, release_date, song_text, link) 
-values ($1, $2, $3, $4, $5)`, id, s.SongName, s.ReleaseDate, s.Text, s.Link)
+	tag, err := tx.Exec(ctx, `insert into songs (author_id, song_name, release_date, song_text, link) 
+values ($1, $2, nullif($3, '')::date, $4, $5)`, id, s.SongName, date, s.Text, s.Link)
 	slog.Debug("adding song to db", "db reply", tag.String())
 	if err != nil {
-		return err
+		return mapError(err)
 	}
-	return nil
+	return tx.Commit(ctx)
 }
 
 // обновление имени исполнителя в бд
 // будет выполнено только если в query запросе и в теле запроса
 // указаны только данные исполнителя
 // (в query - текущее имя, в теле - имя, на которое поменять)
-func (db *Database) UpdateGroupName(author_name string, s Song) error {
-	tag, err := db.dbConn.Exec(context.Background(), `update groups
-set author_name=$1 where author_name=$2`, s.Group, author_name)
-	slog.Debug("updating author_name's name", "db response", tag.String())
-	if err != nil {
-		return err
-	}
-	return nil
+func (db *Database) UpdateGroupName(author_name string, s Song, by string) error {
+	return db.renameArtist(`select author_id, author_name from groups
+where author_id=`+authorByName("$1")+` for update`, author_name, s.Group, by)
 }
 
 // обновление данных песни, будет выполнено вместо UpdateGroupName при любой
@@ -197,58 +296,140 @@ set author_name=$1 where author_name=$2`, s.Group, author_name)
 // исполнитель не найден в базе данных - он будет добавлен
 // Если в поле структуры указано "no_data" (стандартное значение) - эти данные обновляться не будут,
 // позволяя записать пустое значение в базу данных (за исключением id исполнителя и названия песни)
-func (db *Database) UpdateSongDetails(author_name, song_name string, s Song) error {
-	query := `update songs
-set`
-
-	var id int
-	if s.Group != "no_data" {
-		err := db.dbConn.QueryRow(context.Background(), `select (groups.author_id) from groups where groups.author_name=$1`, s.Group).Scan(&id)
-		if err != nil {
-			if !errors.Is(err, pgx.ErrNoRows) {
-				return err
-			}
-			err = db.dbConn.QueryRow(context.Background(), `insert into groups (author_name) values ($1) returning groups.author_id`, s.Group).Scan(&id)
-			if err != nil {
-				return err
-			}
-		}
-		strconv.Itoa(id)
-		query = query + ` author_id='` + strconv.Itoa(id) + `',`
+func (db *Database) UpdateSongDetails(author_name, song_name string, s Song, by string) error {
+	var id int64
+	err := db.dbConn.QueryRow(context.Background(), `select songs.song_id from songs where songs.author_id=`+authorByName("$1")+`
+and songs.song_name=$2 and songs.deleted_at is null`, author_name, song_name).Scan(&id)
+	if err != nil {
+		return mapError(err)
 	}
+
+	return db.UpdateSongByID(id, s, by)
+}
+
+// обновление данных песни по её id, правила те же, что и в UpdateSongDetails.
+// Изменённые поля записываются в историю песни от имени by
+func (db *Database) UpdateSongByID(id int64, s Song, by string) error {
+	var set setClause
+
 	if s.SongName != "no_data" {
-		query = query + ` song_name='` + s.SongName + `',`
+		set.add("song_name", s.SongName)
 	}
 	if s.ReleaseDate != "no_data" {
-		query = query + ` release_date='` + s.ReleaseDate + `',`
+		date, err := normalizeDate(s.ReleaseDate)
+		if err != nil {
+			return err
+		}
+		set.addf("release_date", "nullif(%s, '')::date", date)
 	}
 	if s.Text != "no_data" {
-		query = query + ` song_text='` + s.Text + `',`
+		set.add("song_text", s.Text)
 	}
 	if s.Link != "no_data" {
-		query = query + ` link='` + s.Link + `',`
+		set.add("link", s.Link)
+	}
+
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	query = query[:len(query)-1] + fmt.Sprintf(` where songs.song_name='%s' and songs.author_id in (select author_id from groups where author_name='%s')`, song_name, author_name)
-	tag, err := db.dbConn.Exec(context.Background(), query)
+	// песня блокируется до конца транзакции: её версия и прежние значения
+	// полей для истории не должны измениться до обновления
+	old, err := getSong(ctx, tx, id, " for update of songs")
+	if err != nil {
+		return mapError(err)
+	}
+	err = checkVersion(old, s.Version)
+	if err != nil {
+		return err
+	}
+	// исполнитель ищется (и добавляется) только после проверки песни и её версии,
+	// чтобы отклонённое изменение не оставило исполнителя без песен
+	if s.Group != "no_data" {
+		authorID, err := getAuthorID(ctx, tx, s.Group)
+		if err != nil {
+			return err
+		}
+		set.add("author_id", authorID)
+	}
+	// обновлять нечего, об отсутствии песни (или другой её версии) уже сообщено
+	if set.empty() {
+		return nil
+	}
+
+	query := fmt.Sprintf(`update songs set %s, version=version+1 where song_id=%s`, &set, set.arg(id))
+	tag, err := tx.Exec(ctx, query, set.args...)
 	slog.Debug("updating song details", "db response", tag.String())
+	if err != nil {
+		return mapError(err)
+	}
+	updated, err := getSong(ctx, tx, id, "")
+	if err != nil {
+		return err
+	}
+	err = addSongRevision(ctx, tx, old, updated, by)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit(ctx)
+}
+
+// получение песни по её id
+func (db *Database) GetSong(id int64) (Song, error) {
+	s, err := getSong(context.Background(), db.dbConn, id, "")
+	if err != nil {
+		return Song{}, mapError(err)
+	}
+	return s, nil
+}
+
+// песня не из корзины по её id из conn (пула соединений или транзакции), lock дописывается
+// в конец запроса (" for update ...")
+func getSong(ctx context.Context, conn interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}, id int64, lock string) (Song, error) {
+	var s Song
+	err := conn.QueryRow(ctx, `select songs.song_id, groups.author_name, songs.song_name,
+coalesce(songs.release_date::text, ''), coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version
+from songs inner join groups using (author_id) where songs.song_id=$1 and songs.deleted_at is null`+lock, id).
+		Scan(&s.ID, &s.Group, &s.SongName, &s.ReleaseDate, &s.Text, &s.Link, &s.Version)
+	return s, err
 }
 
-// получение текста песни
-func (db *Database) GetSongText(author_name, songName string) (string, error) {
-	row := db.dbConn.QueryRow(context.Background(), `select songs.song_text from songs 
-    inner join groups using (author_id) where groups.author_name=$1 and songs.song_name=$2`, author_name, songName)
-	var t string
-	err := row.Scan(&t)
+// возвращает id исполнителя, добавляя его при отсутствии, в транзакции tx
+func getAuthorID(ctx context.Context, tx pgx.Tx, author_name string) (int, error) {
+	var id *int
+	// проверяем, есть ли уже такой исполнитель в бд (в том числе под прежним именем)
+	// если есть, получаем его id
+	err := tx.QueryRow(ctx, `select `+authorByName("$1"), author_name).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if id != nil {
+		return *id, nil
+	}
+
+	// если нет - добавляем его, с получением его id
+	var newID int
+	err = tx.QueryRow(ctx, `insert into groups (author_name) values ($1) returning author_id`, author_name).Scan(&newID)
 	if err != nil {
-		slog.Error("error retrieving from db", "error", err.Error())
-		return "", err
+		return 0, err
 	}
+	return newID, nil
+}
 
-	return t, nil
+// приводит ошибки postgres к ошибкам пакета, не зависящим от реализации хранилища
+func mapError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, pgErr.Detail)
+	}
+	return err
 }
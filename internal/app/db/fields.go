@@ -0,0 +1,97 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// поля песни (имена как в json), которые можно выбрать в ListParams.Fields
+var SongFields = []string{"id", "group", "song", "releaseDate", "text", "link", "version"}
+
+// поля списка песен по умолчанию - всё, кроме текста
+var DefaultSongFields = []string{"id", "group", "song", "releaseDate", "link", "version"}
+
+// ParseFields разбирает список полей через запятую (fields=group,song),
+// повторы отбрасываются, поля возвращаются в порядке SongFields
+func ParseFields(s string) ([]string, error) {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if !slices.Contains(SongFields, f) {
+			return nil, fmt.Errorf("unknown field %q, fields are %s", f, strings.Join(SongFields, ", "))
+		}
+		fields = append(fields, f)
+	}
+	return onlyFields(fields), nil
+}
+
+// поля из fields без повторов в порядке SongFields
+func onlyFields(fields []string) []string {
+	return slices.DeleteFunc(slices.Clone(SongFields), func(f string) bool {
+		return !slices.Contains(fields, f)
+	})
+}
+
+// Only оставляет в песне только поля fields, nil - все поля
+func (s Song) Only(fields []string) Song {
+	if fields == nil {
+		return s
+	}
+	var o Song
+	for _, f := range fields {
+		switch f {
+		case "id":
+			o.ID = s.ID
+		case "group":
+			o.Group = s.Group
+		case "song":
+			o.SongName = s.SongName
+		case "releaseDate":
+			o.ReleaseDate = s.ReleaseDate
+		case "text":
+			o.Text = s.Text
+		case "link":
+			o.Link = s.Link
+		case "version":
+			o.Version = s.Version
+		}
+	}
+	return o
+}
+
+// колонки select для полей fields (nil - все поля) и поля песни,
+// в которые они считываются. Повторы полей отбрасываются
+func songsProjection(fields []string, d dialect) (string, func(s *Song) []any) {
+	if fields == nil {
+		fields = SongFields
+	}
+	fields = onlyFields(fields)
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = map[string]string{
+			"id":          "songs.song_id",
+			"group":       "groups.author_name",
+			"song":        "songs.song_name",
+			"releaseDate": "coalesce(" + d.releaseDate + ", '')",
+			"text":        "coalesce(songs.song_text, '')",
+			"link":        "coalesce(songs.link, '')",
+			"version":     "songs.version",
+		}[f]
+	}
+	return strings.Join(columns, ", "), func(s *Song) []any {
+		dest := make([]any, len(fields))
+		for i, f := range fields {
+			dest[i] = map[string]any{
+				"id":          &s.ID,
+				"group":       &s.Group,
+				"song":        &s.SongName,
+				"releaseDate": &s.ReleaseDate,
+				"text":        &s.Text,
+				"link":        &s.Link,
+				"version":     &s.Version,
+			}[f]
+		}
+		return dest
+	}
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// история изменений песен и исполнителей: каждое изменение (ревизия) хранится
+// как список изменённых полей со старыми и новыми значениями.
+// Ревизия песни - её версия после изменения (см. Song.Version), ревизия исполнителя -
+// порядковый номер изменения, 1 - исходное имя. Переименование исполнителя
+// записывается и в историю каждой его песни как изменение group
+
+// Revision - одно изменение песни или исполнителя
+type Revision struct {
+	Version   int64     `json:"version"`
+	ChangedBy string    `json:"changedBy"`
+	ChangedAt time.Time `json:"changedAt"`
+	Changes   []Change  `json:"changes"`
+}
+
+// Change - изменение одного поля (имена полей как в json)
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// поля песни, изменения которых попадают в историю
+var historyFields = []string{"group", "song", "releaseDate", "text", "link"}
+
+func songField(s *Song, field string) *string {
+	switch field {
+	case "group":
+		return &s.Group
+	case "song":
+		return &s.SongName
+	case "releaseDate":
+		return &s.ReleaseDate
+	case "text":
+		return &s.Text
+	case "link":
+		return &s.Link
+	}
+	panic("unknown song field " + field)
+}
+
+// SongChanges возвращает поля, которыми отличаются данные песни old и new
+func SongChanges(old, new Song) []Change {
+	changes := make([]Change, 0, len(historyFields))
+	for _, f := range historyFields {
+		o, n := *songField(&old, f), *songField(&new, f)
+		if o != n {
+			changes = append(changes, Change{Field: f, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+// SongAt восстанавливает данные песни current на момент ревизии version
+// по её истории revs. ErrNotFound, если такой ревизии у песни нет
+func SongAt(current Song, revs []Revision, version int64) (Song, error) {
+	if version < 1 || version > current.Version {
+		return Song{}, fmt.Errorf("%w: song %d has no revision %d", ErrNotFound, current.ID, version)
+	}
+	s := current
+	s.Version = version
+	for _, f := range historyFields {
+		*songField(&s, f) = valueAt(revs, f, version, *songField(&current, f))
+	}
+	return s, nil
+}
+
+// ArtistNameAt - имя исполнителя на момент ревизии version по его истории revs
+func ArtistNameAt(current string, revs []Revision, version int64) (string, error) {
+	last := int64(1)
+	if len(revs) > 0 {
+		last = revs[0].Version
+	}
+	if version < 1 || version > last {
+		return "", fmt.Errorf("%w: artist has no revision %d", ErrNotFound, version)
+	}
+	return valueAt(revs, "name", version, current), nil
+}
+
+// значение поля field на момент ревизии version: новое значение последнего
+// изменения не позже version, иначе старое значение первого изменения после неё,
+// а если поле не менялось - его текущее значение
+func valueAt(revs []Revision, field string, version int64, current string) string {
+	var before, after *Change
+	var beforeVersion, afterVersion int64
+	for i := range revs {
+		for j := range revs[i].Changes {
+			c := &revs[i].Changes[j]
+			if c.Field != field {
+				continue
+			}
+			v := revs[i].Version
+			if v <= version && (before == nil || v > beforeVersion) {
+				before, beforeVersion = c, v
+			}
+			if v > version && (after == nil || v < afterVersion) {
+				after, afterVersion = c, v
+			}
+		}
+	}
+	switch {
+	case before != nil:
+		return before.New
+	case after != nil:
+		return after.Old
+	}
+	return current
+}
+
+// добавляет изменение к ревизиям, прочитанным из таблицы истории
+// в порядке убывания версий
+func appendChange(revs []Revision, version int64, by string, at time.Time, c Change) []Revision {
+	if n := len(revs); n == 0 || revs[n-1].Version != version {
+		revs = append(revs, Revision{Version: version, ChangedBy: by, ChangedAt: at, Changes: make([]Change, 0, 1)})
+	}
+	last := &revs[len(revs)-1]
+	last.Changes = append(last.Changes, c)
+	return revs
+}
+
+// записывает в историю песни изменения её данных old -> updated
+func addSongRevision(ctx context.Context, tx pgx.Tx, old, updated Song, by string) error {
+	for _, c := range SongChanges(old, updated) {
+		_, err := tx.Exec(ctx, `insert into song_history (song_id, version, changed_by, field, old_value, new_value)
+values ($1, $2, $3, $4, $5, $6)`, updated.ID, updated.Version, by, c.Field, c.Old, c.New)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// история изменений песни, от новых ревизий к старым
+func (db *Database) SongRevisions(id int64) ([]Revision, error) {
+	return db.revisions(`select version, changed_by, changed_at, field, old_value, new_value
+from song_history where song_id=$1 order by version desc, history_id`, id)
+}
+
+// история изменений исполнителя, от новых ревизий к старым
+func (db *Database) ArtistRevisions(id int) ([]Revision, error) {
+	return db.revisions(`select version, changed_by, changed_at, field, old_value, new_value
+from artist_history where author_id=$1 order by version desc, history_id`, id)
+}
+
+func (db *Database) revisions(query string, id any) ([]Revision, error) {
+	rows, err := db.dbConn.Query(context.Background(), query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revs := make([]Revision, 0)
+	for rows.Next() {
+		var version int64
+		var by string
+		var at time.Time
+		var c Change
+		err = rows.Scan(&version, &by, &at, &c.Field, &c.Old, &c.New)
+		if err != nil {
+			return nil, err
+		}
+		revs = appendChange(revs, version, by, at, c)
+	}
+	return revs, rows.Err()
+}
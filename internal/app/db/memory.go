@@ -0,0 +1,578 @@
+package db
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore - хранилище библиотеки в памяти процесса.
+// Повторяет поведение Database и позволяет запускать сервер
+// (и его обработчики в тестах) без postgres.
+// Данные теряются при остановке сервера
+type MemoryStore struct {
+	mu           sync.RWMutex
+	groups       map[int]string
+	aliases      map[string]int
+	nextAuthorID int
+	nextSongID   int64
+	songs        []memSong
+	albums       map[int64]*memAlbum
+	nextAlbumID  int64
+	tags         map[int64]*memTag
+	nextTagID    int64
+	// id песни -> множество id её тегов (таблица song_tags)
+	songTags       map[int64]map[int64]bool
+	playlists      map[int64]*memPlaylist
+	nextPlaylistID int64
+	nextEntryID    int64
+	// определения хранятся целиком, как после разбора json из таблицы
+	smartPlaylists      map[int64]SmartPlaylist
+	nextSmartPlaylistID int64
+	apiKeys             map[int64]*memAPIKey
+	nextAPIKeyID        int64
+	// jti отозванного токена -> срок его действия
+	revokedTokens map[string]time.Time
+	// ревизии песен и исполнителей от старых к новым
+	songHistory   map[int64][]Revision
+	artistHistory map[int][]Revision
+}
+
+// строка таблицы songs
+type memSong struct {
+	id          int64
+	authorID    int
+	songName    string
+	releaseDate string
+	text        string
+	link        string
+	version     int64
+	// время удаления в корзину (нулевое - песня не удалена) и кто удалил
+	deletedAt time.Time
+	deletedBy string
+	// имя исполнителя песни из корзины, отвязанной от него (authorID = 0, см. trash.go)
+	deletedGroup string
+}
+
+// песня в корзине (см. trash.go)
+func (s memSong) trashed() bool {
+	return !s.deletedAt.IsZero()
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		groups:              make(map[int]string),
+		aliases:             make(map[string]int),
+		nextAuthorID:        1,
+		nextSongID:          1,
+		albums:              make(map[int64]*memAlbum),
+		nextAlbumID:         1,
+		tags:                make(map[int64]*memTag),
+		nextTagID:           1,
+		songTags:            make(map[int64]map[int64]bool),
+		playlists:           make(map[int64]*memPlaylist),
+		nextPlaylistID:      1,
+		nextEntryID:         1,
+		smartPlaylists:      make(map[int64]SmartPlaylist),
+		nextSmartPlaylistID: 1,
+		apiKeys:             make(map[int64]*memAPIKey),
+		nextAPIKeyID:        1,
+		revokedTokens:       make(map[string]time.Time),
+		songHistory:         make(map[int64][]Revision),
+		artistHistory:       make(map[int][]Revision),
+	}
+}
+
+// хранилищу в памяти не требуется соединение, метод нужен для соответствия Store
+func (m *MemoryStore) Open() error {
+	slog.Info("using in-memory storage, data will be lost on shutdown")
+	return nil
+}
+
+// выдает все песни, удовлетворяющие параметрам фильтрации (если они есть),
+// отсортированные по имени исполнителя и названию песни
+func (m *MemoryStore) ListAllLibrary(p ListParams) (Library, error) {
+	offsetInt, limitInt, err := parsePage(p.Offset, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lib := make(Library, 0, 64)
+	for _, song := range m.filterSongs(p) {
+		lib = append(lib, m.toSong(song))
+	}
+
+	less := songLess(p.Sort)
+	if p.Similarity > 0 && p.Sort == "" {
+		less = func(a, b Song) bool {
+			x, y := songSimilarity(p, a), songSimilarity(p, b)
+			if x != y {
+				return x > y
+			}
+			return songLess("")(a, b)
+		}
+	}
+	sort.Slice(lib, func(i, j int) bool {
+		return less(lib[i], lib[j])
+	})
+
+	if c := p.Cursor; c != nil {
+		lib = slices.DeleteFunc(lib, func(s Song) bool { return !c.match(s) })
+		// страница перед курсором - ближайшие к нему песни
+		if c.Before && limitInt >= 0 && limitInt < len(lib) {
+			lib = lib[len(lib)-limitInt:]
+		}
+	}
+
+	lib = page(lib, offsetInt, limitInt)
+	for i := range lib {
+		lib[i] = lib[i].Only(p.Fields)
+	}
+	return lib, nil
+}
+
+// количество песен, удовлетворяющих параметрам фильтрации, без учёта страницы
+func (m *MemoryStore) CountLibrary(p ListParams) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.filterSongs(p)), nil
+}
+
+// фильтры author и song: точное совпадение (исполнитель ищется и по прежним
+// именам) или, при p.Similarity > 0, сходство не ниже порога
+func (m *MemoryStore) matchesName(p ListParams, song memSong, authorID int) bool {
+	s := p.Filter
+	if p.Similarity > 0 {
+		return (s.Group == "" || similarity(m.groups[song.authorID], s.Group) >= p.Similarity) &&
+			(s.SongName == "" || similarity(song.songName, s.SongName) >= p.Similarity)
+	}
+	return (s.Group == "" || song.authorID == authorID) &&
+		(s.SongName == "" || song.songName == s.SongName)
+}
+
+// сходство песни с фильтрами author и song для упорядочивания, см. songsListOrder
+func songSimilarity(p ListParams, s Song) float64 {
+	var score float64
+	if p.Filter.Group != "" {
+		score += similarity(s.Group, p.Filter.Group)
+	}
+	if p.Filter.SongName != "" {
+		score += similarity(s.SongName, p.Filter.SongName)
+	}
+	return score
+}
+
+// песни, удовлетворяющие фильтрам p, в порядке добавления;
+// вызывается при захваченной блокировке
+func (m *MemoryStore) filterSongs(p ListParams) []memSong {
+	s := p.Filter
+
+	// postgres сравнивает дату в формате yyyy-mm-dd, поэтому приводим
+	// к нему и фильтр. Некорректная дата просто ни с чем не совпадёт
+	releaseDate := s.ReleaseDate
+	if date, err := normalizeDate(releaseDate); err == nil {
+		releaseDate = date
+	}
+
+	authorID, _ := m.resolveGroup(s.Group)
+	album, albumFound := m.albums[p.Album]
+	if p.Album != 0 && !albumFound {
+		return nil
+	}
+
+	subtrees := make([]map[int64]bool, len(p.Tags))
+	for i, tag := range p.Tags {
+		subtrees[i] = m.tagSubtree(tag)
+	}
+	hasTags := func(songID int64) bool {
+		if len(subtrees) == 0 {
+			return true
+		}
+		for _, subtree := range subtrees {
+			found := m.songHasTag(songID, subtree)
+			if found && p.TagMode != TagModeAll {
+				return true
+			}
+			if !found && p.TagMode == TagModeAll {
+				return false
+			}
+		}
+		return p.TagMode == TagModeAll
+	}
+
+	songs := make([]memSong, 0, 64)
+	for _, song := range m.songs {
+		if !song.trashed() &&
+			m.matchesName(p, song, authorID) &&
+			hasTags(song.id) &&
+			(p.Rule == nil || p.Rule.match(m.toSong(song), song.authorID, m.resolveGroup)) &&
+			(p.Album == 0 || album.hasSong(song.id)) &&
+			(releaseDate == "" || song.releaseDate == releaseDate) &&
+			(s.Text == "" || song.text == s.Text) &&
+			(s.Link == "" || song.link == s.Link) {
+			songs = append(songs, song)
+		}
+	}
+	return songs
+}
+
+// сравнение песен в порядке songsOrder
+func songLess(sort string) func(a, b Song) bool {
+	keys, _ := sortKeys(sort)
+	keys = append(keys, sortKey{field: "artist"}, sortKey{field: "song"}, sortKey{field: "id"})
+	return func(a, b Song) bool {
+		for _, k := range keys {
+			var c int
+			switch k.field {
+			case "id":
+				c = cmp.Compare(a.ID, b.ID)
+			case "artist":
+				c = strings.Compare(a.Group, b.Group)
+			case "song":
+				c = strings.Compare(a.SongName, b.SongName)
+			case "releaseDate":
+				// песни без даты - последние при любом направлении
+				if (a.ReleaseDate == "") != (b.ReleaseDate == "") {
+					return b.ReleaseDate == ""
+				}
+				c = strings.Compare(a.ReleaseDate, b.ReleaseDate)
+			}
+			if c != 0 {
+				return (c < 0) != k.desc
+			}
+		}
+		return false
+	}
+}
+
+// удаление определенной песни в корзину, возвращает количество удалённых песен
+func (m *MemoryStore) DeleteSong(author_name, songName, by string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.findSong(author_name, songName)
+	if i < 0 {
+		return 0, nil
+	}
+	m.trashSong(i, by)
+	return 1, nil
+}
+
+// удаление песни в корзину по её id, если её версия - version (0 - любая)
+func (m *MemoryStore) DeleteSongByID(id, version int64, by string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.findSongByID(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	err := checkVersion(m.toSong(m.songs[i]), version)
+	if err != nil {
+		return err
+	}
+	m.trashSong(i, by)
+	return nil
+}
+
+// добавление песни, исполнитель создаётся, если его ещё нет
+func (m *MemoryStore) AddSong(s Song) error {
+	date, err := normalizeDate(s.ReleaseDate)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i := m.findSongName(s.Group, s.SongName); i >= 0 {
+		if m.songs[i].trashed() {
+			return &TrashedSongError{ID: m.songs[i].id}
+		}
+		return fmt.Errorf("%w: song %q by %q", ErrAlreadyExists, s.SongName, s.Group)
+	}
+
+	m.songs = append(m.songs, memSong{
+		id:          m.nextSongID,
+		authorID:    m.authorID(s.Group),
+		songName:    s.SongName,
+		releaseDate: date,
+		text:        s.Text,
+		link:        s.Link,
+		version:     1,
+	})
+	m.nextSongID++
+	return nil
+}
+
+// переименование исполнителя, все его песни остаются за ним
+func (m *MemoryStore) UpdateGroupName(author_name string, s Song, by string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.resolveGroup(author_name)
+	if !ok {
+		return ErrNotFound
+	}
+	if other, ok := m.findGroup(s.Group); ok && other != id {
+		return fmt.Errorf("%w: author %q", ErrAlreadyExists, s.Group)
+	}
+	m.renameGroup(id, s.Group, by)
+	return nil
+}
+
+// обновление данных песни, поля со значением "no_data" не изменяются
+// (см. Database.UpdateSongDetails)
+func (m *MemoryStore) UpdateSongDetails(author_name, song_name string, s Song, by string) error {
+	m.mu.RLock()
+	var id int64
+	i := m.findSong(author_name, song_name)
+	if i >= 0 {
+		id = m.songs[i].id
+	}
+	m.mu.RUnlock()
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	return m.UpdateSongByID(id, s, by)
+}
+
+// обновление данных песни по её id, правила те же, что и в UpdateSongDetails,
+// изменения записываются в историю песни (см. Database.UpdateSongByID)
+func (m *MemoryStore) UpdateSongByID(id int64, s Song, by string) error {
+	date := s.ReleaseDate
+	if date != "no_data" {
+		var err error
+		date, err = normalizeDate(date)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.findSongByID(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	song := m.songs[i]
+	err := checkVersion(m.toSong(song), s.Version)
+	if err != nil {
+		return err
+	}
+	// как и в Database, обновление без изменяемых полей не меняет версию
+	if s.Group == "no_data" && s.SongName == "no_data" && date == "no_data" &&
+		s.Text == "no_data" && s.Link == "no_data" {
+		return nil
+	}
+
+	newAuthor, newName := m.groups[song.authorID], song.songName
+	if s.Group != "no_data" {
+		newAuthor = s.Group
+	}
+	if s.SongName != "no_data" {
+		newName = s.SongName
+	}
+	if j := m.findSongName(newAuthor, newName); j >= 0 && j != i {
+		return fmt.Errorf("%w: song %q by %q", ErrAlreadyExists, newName, newAuthor)
+	}
+
+	if s.Group != "no_data" {
+		song.authorID = m.authorID(s.Group)
+	}
+	song.songName = newName
+	if date != "no_data" {
+		song.releaseDate = date
+	}
+	if s.Text != "no_data" {
+		song.text = s.Text
+	}
+	if s.Link != "no_data" {
+		song.link = s.Link
+	}
+	song.version++
+	m.addSongRevision(m.toSong(m.songs[i]), m.toSong(song), by)
+	m.songs[i] = song
+	return nil
+}
+
+// получение песни по её id
+func (m *MemoryStore) GetSong(id int64) (Song, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := m.findSongByID(id)
+	if i < 0 {
+		return Song{}, ErrNotFound
+	}
+	return m.toSong(m.songs[i]), nil
+}
+
+// далее вспомогательные методы, вызывающий должен держать блокировку
+
+func (m *MemoryStore) toSong(song memSong) Song {
+	group := m.groups[song.authorID]
+	if song.authorID == 0 {
+		group = song.deletedGroup
+	}
+	return Song{
+		ID:          song.id,
+		Group:       group,
+		SongName:    song.songName,
+		ReleaseDate: song.releaseDate,
+		Text:        song.text,
+		Link:        song.link,
+		Version:     song.version,
+	}
+}
+
+func (m *MemoryStore) findGroup(author_name string) (int, bool) {
+	for id, name := range m.groups {
+		if name == author_name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// ищет исполнителя по имени, а если такого нет - по прежним именам
+// объединённых исполнителей (см. authorByName)
+func (m *MemoryStore) resolveGroup(author_name string) (int, bool) {
+	if id, ok := m.findGroup(author_name); ok {
+		return id, true
+	}
+	id, ok := m.aliases[author_name]
+	return id, ok
+}
+
+// переименовывает исполнителя, имя входит в данные его песен,
+// поэтому их версии увеличиваются, а смена group попадает в их историю
+// (см. Database.renameArtist)
+func (m *MemoryStore) renameGroup(id int, name, by string) {
+	old := m.groups[id]
+	if old == name {
+		return
+	}
+	revs := m.artistHistory[id]
+	m.artistHistory[id] = append(revs, Revision{
+		Version:   int64(len(revs)) + 2,
+		ChangedBy: by,
+		ChangedAt: time.Now().UTC(),
+		Changes:   []Change{{Field: "name", Old: old, New: name}},
+	})
+	for i := range m.songs {
+		if m.songs[i].authorID == id {
+			before := m.toSong(m.songs[i])
+			m.songs[i].version++
+			after := m.toSong(m.songs[i])
+			after.Group = name
+			m.addSongRevision(before, after, by)
+		}
+	}
+	m.groups[id] = name
+}
+
+// возвращает id исполнителя, создавая его при необходимости
+func (m *MemoryStore) authorID(author_name string) int {
+	if id, ok := m.resolveGroup(author_name); ok {
+		return id
+	}
+	id := m.nextAuthorID
+	m.nextAuthorID++
+	m.groups[id] = author_name
+	return id
+}
+
+// возвращает индекс песни не из корзины в m.songs или -1
+func (m *MemoryStore) findSong(author_name, songName string) int {
+	i := m.findSongName(author_name, songName)
+	if i >= 0 && m.songs[i].trashed() {
+		return -1
+	}
+	return i
+}
+
+// как findSong, но с песнями в корзине: они занимают своё название
+// у исполнителя, как и в Database
+func (m *MemoryStore) findSongName(author_name, songName string) int {
+	id, ok := m.resolveGroup(author_name)
+	if !ok {
+		return -1
+	}
+	for i, song := range m.songs {
+		if song.authorID == id && song.songName == songName {
+			return i
+		}
+	}
+	return -1
+}
+
+// возвращает индекс песни не из корзины с указанным id в m.songs или -1
+func (m *MemoryStore) findSongByID(id int64) int {
+	for i, song := range m.songs {
+		if song.id == id && !song.trashed() {
+			return i
+		}
+	}
+	return -1
+}
+
+// возвращает часть списка в соответствии с offset и limit (limit < 0 - без ограничения)
+func page[T any](list []T, offset, limit int) []T {
+	if offset >= len(list) {
+		return list[:0]
+	}
+	list = list[offset:]
+	if limit >= 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return list
+}
+
+// записывает в историю песни изменения её данных old -> updated
+func (m *MemoryStore) addSongRevision(old, updated Song, by string) {
+	changes := SongChanges(old, updated)
+	if len(changes) == 0 {
+		return
+	}
+	m.songHistory[updated.ID] = append(m.songHistory[updated.ID], Revision{
+		Version:   updated.Version,
+		ChangedBy: by,
+		ChangedAt: time.Now().UTC(),
+		Changes:   changes,
+	})
+}
+
+// удаляет ссылки на песню из связанных с ней данных (аналог on delete cascade)
+func (m *MemoryStore) forgetSong(id int64) {
+	delete(m.songTags, id)
+	delete(m.songHistory, id)
+	for _, album := range m.albums {
+		for i, t := range album.tracks {
+			if t.songID == id {
+				album.tracks = append(album.tracks[:i], album.tracks[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, playlist := range m.playlists {
+		entries := playlist.entries[:0]
+		for _, e := range playlist.entries {
+			if e.songID != id {
+				entries = append(entries, e)
+			}
+		}
+		playlist.entries = entries
+	}
+}
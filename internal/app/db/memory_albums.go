@@ -0,0 +1,210 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// строка таблицы albums вместе с треками из album_tracks
+type memAlbum struct {
+	id          int64
+	title       string
+	authorID    int
+	releaseDate string
+	albumType   string
+	tracks      []memTrack
+}
+
+type memTrack struct {
+	songID   int64
+	disc     int
+	position int
+}
+
+// список альбомов, при artistID != 0 - только альбомы этого исполнителя
+func (m *MemoryStore) ListAlbums(artistID int, offset, limit string) ([]Album, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	albums := make([]Album, 0, len(m.albums))
+	for _, a := range m.albums {
+		if artistID == 0 || a.authorID == artistID {
+			albums = append(albums, m.toAlbum(a))
+		}
+	}
+	sort.Slice(albums, func(i, j int) bool {
+		if albums[i].Title != albums[j].Title {
+			return albums[i].Title < albums[j].Title
+		}
+		return albums[i].ID < albums[j].ID
+	})
+
+	return page(albums, offsetInt, limitInt), nil
+}
+
+// альбом вместе с треками, упорядоченными по диску и позиции
+func (m *MemoryStore) GetAlbum(id int64) (Album, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	a, ok := m.albums[id]
+	if !ok {
+		return Album{}, ErrNotFound
+	}
+
+	album := m.toAlbum(a)
+	album.Tracks = make([]Track, 0, len(a.tracks))
+	for _, t := range a.tracks {
+		i := m.findSongByID(t.songID)
+		if i < 0 {
+			continue
+		}
+		album.Tracks = append(album.Tracks, Track{Disc: t.disc, Position: t.position, Song: m.toSong(m.songs[i])})
+	}
+	sort.Slice(album.Tracks, func(i, j int) bool {
+		if album.Tracks[i].Disc != album.Tracks[j].Disc {
+			return album.Tracks[i].Disc < album.Tracks[j].Disc
+		}
+		return album.Tracks[i].Position < album.Tracks[j].Position
+	})
+	return album, nil
+}
+
+// добавление альбома без треков, исполнитель должен существовать
+func (m *MemoryStore) AddAlbum(a Album) (Album, error) {
+	date, err := normalizeDate(a.ReleaseDate)
+	if err != nil {
+		return Album{}, err
+	}
+
+	m.mu.Lock()
+	if _, ok := m.groups[a.ArtistID]; !ok {
+		m.mu.Unlock()
+		return Album{}, fmt.Errorf("%w: artist %d", ErrNotFound, a.ArtistID)
+	}
+	id := m.nextAlbumID
+	m.nextAlbumID++
+	m.albums[id] = &memAlbum{
+		id:          id,
+		title:       a.Title,
+		authorID:    a.ArtistID,
+		releaseDate: date,
+		albumType:   a.Type,
+	}
+	m.mu.Unlock()
+
+	return m.GetAlbum(id)
+}
+
+// замена данных альбома (название, исполнитель, дата, тип), треки не меняются
+func (m *MemoryStore) UpdateAlbum(id int64, a Album) error {
+	date, err := normalizeDate(a.ReleaseDate)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[a.ArtistID]; !ok {
+		return fmt.Errorf("%w: artist %d", ErrNotFound, a.ArtistID)
+	}
+	album, ok := m.albums[id]
+	if !ok {
+		return ErrNotFound
+	}
+	album.title = a.Title
+	album.authorID = a.ArtistID
+	album.releaseDate = date
+	album.albumType = a.Type
+	return nil
+}
+
+// удаление альбома, песни альбома остаются в библиотеке
+func (m *MemoryStore) DeleteAlbum(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.albums[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.albums, id)
+	return nil
+}
+
+// добавляет или перемещает песню в альбоме (см. Database.SetTrack)
+func (m *MemoryStore) SetTrack(albumID, songID int64, disc, position int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	album, ok := m.albums[albumID]
+	if !ok {
+		return fmt.Errorf("%w: album %d", ErrNotFound, albumID)
+	}
+	if m.findSongByID(songID) < 0 {
+		return fmt.Errorf("%w: song %d", ErrNotFound, songID)
+	}
+
+	existing := -1
+	for i, t := range album.tracks {
+		if t.songID == songID {
+			existing = i
+			continue
+		}
+		if t.disc == disc && t.position == position {
+			return fmt.Errorf("%w: disc %d position %d is taken", ErrAlreadyExists, disc, position)
+		}
+	}
+
+	track := memTrack{songID: songID, disc: disc, position: position}
+	if existing >= 0 {
+		album.tracks[existing] = track
+	} else {
+		album.tracks = append(album.tracks, track)
+	}
+	return nil
+}
+
+// удаление песни из альбома, сама песня остаётся в библиотеке
+func (m *MemoryStore) RemoveTrack(albumID, songID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	album, ok := m.albums[albumID]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, t := range album.tracks {
+		if t.songID == songID {
+			album.tracks = append(album.tracks[:i], album.tracks[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) toAlbum(a *memAlbum) Album {
+	return Album{
+		ID:          a.id,
+		Title:       a.title,
+		ArtistID:    a.authorID,
+		Artist:      m.groups[a.authorID],
+		ReleaseDate: a.releaseDate,
+		Type:        a.albumType,
+	}
+}
+
+// есть ли песня в альбоме
+func (a *memAlbum) hasSong(songID int64) bool {
+	for _, t := range a.tracks {
+		if t.songID == songID {
+			return true
+		}
+	}
+	return false
+}
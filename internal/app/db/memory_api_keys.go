@@ -0,0 +1,103 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// строка таблицы api_keys
+type memAPIKey struct {
+	APIKey
+	hash string
+}
+
+// все ключи, включая отозванные, в порядке создания
+func (m *MemoryStore) ListAPIKeys() ([]APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(m.apiKeys))
+	for _, k := range m.apiKeys {
+		keys = append(keys, k.APIKey)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// сохраняет ключ с хешем hash (см. NewAPIKeySecret)
+func (m *MemoryStore) AddAPIKey(k APIKey, hash string) (APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.apiKeys {
+		if other.hash == hash {
+			return APIKey{}, fmt.Errorf("%w: api key", ErrAlreadyExists)
+		}
+	}
+
+	k.ID = m.nextAPIKeyID
+	m.nextAPIKeyID++
+	k.CreatedAt = time.Now().UTC()
+	k.RevokedAt = nil
+	m.apiKeys[k.ID] = &memAPIKey{APIKey: k, hash: hash}
+	return k, nil
+}
+
+// действующий (не отозванный) ключ по его хешу
+func (m *MemoryStore) FindAPIKey(hash string) (APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.apiKeys {
+		if k.hash == hash && k.RevokedAt == nil {
+			return k.APIKey, nil
+		}
+	}
+	return APIKey{}, ErrNotFound
+}
+
+// отзыв ключа, отозванный ключ остаётся в списке
+func (m *MemoryStore) RevokeAPIKey(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.apiKeys[id]
+	if !ok || k.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now().UTC()
+	k.RevokedAt = &now
+	return nil
+}
+
+// добавляет токен в список отозванных (см. Database.RevokeToken)
+func (m *MemoryStore) RevokeToken(jti string, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range m.revokedTokens {
+		if exp.Before(now) {
+			delete(m.revokedTokens, id)
+		}
+	}
+	if _, ok := m.revokedTokens[jti]; !ok {
+		m.revokedTokens[jti] = expires
+	}
+	return nil
+}
+
+// отозван ли токен или ключ, по которому он выдан (см. Database.TokenRevoked)
+func (m *MemoryStore) TokenRevoked(jti string, keyID int64) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, revoked := m.revokedTokens[jti]; revoked {
+		return true, nil
+	}
+	k, ok := m.apiKeys[keyID]
+	return !ok || k.RevokedAt != nil, nil
+}
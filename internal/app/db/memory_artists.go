@@ -0,0 +1,248 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// список исполнителей, отсортированный по имени, с количеством песен у каждого
+func (m *MemoryStore) ListArtists(offset, limit string) ([]Artist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[int]int, len(m.groups))
+	for _, song := range m.songs {
+		if !song.trashed() {
+			counts[song.authorID]++
+		}
+	}
+
+	artists := make([]Artist, 0, len(m.groups))
+	for id, name := range m.groups {
+		artists = append(artists, Artist{ID: id, Name: name, SongCount: counts[id]})
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		return artists[i].Name < artists[j].Name
+	})
+
+	return page(artists, offsetInt, limitInt), nil
+}
+
+// исполнитель без песен по имени (см. Database.FindArtist)
+func (m *MemoryStore) FindArtist(name string) (Artist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.resolveGroup(name)
+	if !ok {
+		return Artist{}, ErrNotFound
+	}
+	return Artist{ID: id, Name: m.groups[id]}, nil
+}
+
+// исполнитель вместе со всеми его песнями
+func (m *MemoryStore) GetArtist(id int) (Artist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	name, ok := m.groups[id]
+	if !ok {
+		return Artist{}, ErrNotFound
+	}
+
+	a := Artist{ID: id, Name: name, Songs: make(Library, 0, 16)}
+	for _, song := range m.songs {
+		if song.authorID == id && !song.trashed() {
+			a.Songs = append(a.Songs, m.toSong(song))
+		}
+	}
+	sort.Slice(a.Songs, func(i, j int) bool {
+		return a.Songs[i].SongName < a.Songs[j].SongName
+	})
+	a.SongCount = len(a.Songs)
+	return a, nil
+}
+
+// добавление исполнителя без песен
+func (m *MemoryStore) AddArtist(name string) (Artist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findGroup(name); ok {
+		return Artist{}, fmt.Errorf("%w: author %q", ErrAlreadyExists, name)
+	}
+	return Artist{ID: m.authorID(name), Name: name}, nil
+}
+
+// переименование исполнителя по его id
+func (m *MemoryStore) RenameArtist(id int, name, by string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[id]; !ok {
+		return ErrNotFound
+	}
+	if other, ok := m.findGroup(name); ok && other != id {
+		return fmt.Errorf("%w: author %q", ErrAlreadyExists, name)
+	}
+	m.renameGroup(id, name, by)
+	return nil
+}
+
+// удаление исполнителя (см. Database.DeleteArtist)
+func (m *MemoryStore) DeleteArtist(id int, cascade bool, by string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[id]; !ok {
+		return ErrNotFound
+	}
+
+	live, trashed := 0, 0
+	for _, song := range m.songs {
+		switch {
+		case song.authorID != id:
+		case song.trashed():
+			trashed++
+		default:
+			live++
+		}
+	}
+	if live+trashed > 0 && !cascade {
+		return artistSongsError(live, trashed)
+	}
+
+	for i, song := range m.songs {
+		if song.authorID != id {
+			continue
+		}
+		if !song.trashed() {
+			m.trashSong(i, by)
+		}
+		m.detachSong(i)
+	}
+	for albumID, album := range m.albums {
+		if album.authorID == id {
+			delete(m.albums, albumID)
+		}
+	}
+	delete(m.groups, id)
+	delete(m.artistHistory, id)
+	for alias, authorID := range m.aliases {
+		if authorID == id {
+			delete(m.aliases, alias)
+		}
+	}
+	return nil
+}
+
+// объединяет исполнителей sources с исполнителем target (см. Database.MergeArtists)
+// изменения применяются только если объединение прошло без ошибок
+func (m *MemoryStore) MergeArtists(target int, sources []int, policy MergePolicy, by string) (MergeResult, error) {
+	result := MergeResult{Dropped: make([]int64, 0)}
+
+	m.mu.Lock()
+	if _, ok := m.groups[target]; !ok {
+		m.mu.Unlock()
+		return result, fmt.Errorf("%w: artist %d", ErrNotFound, target)
+	}
+
+	// работаем с копиями, чтобы при ошибке ничего не изменилось
+	songs := make([]memSong, len(m.songs))
+	copy(songs, m.songs)
+	dropped := make(map[int64]bool)
+	merged := make([]int, 0, len(sources))
+	// перенесённые песни до и после переноса, для истории
+	var moved [][2]Song
+
+	for _, source := range sources {
+		if source == target {
+			continue
+		}
+		if _, ok := m.groups[source]; !ok {
+			m.mu.Unlock()
+			return result, fmt.Errorf("%w: artist %d", ErrNotFound, source)
+		}
+
+		// пары одноимённых песен не из корзины
+		for _, s := range songs {
+			if s.authorID != source || s.trashed() {
+				continue
+			}
+			for _, t := range songs {
+				if t.authorID != target || t.trashed() || t.songName != s.songName {
+					continue
+				}
+				song, err := policy.drop(t.id, s.id)
+				if err != nil {
+					m.mu.Unlock()
+					return result, err
+				}
+				dropped[song] = true
+				result.Dropped = append(result.Dropped, song)
+			}
+		}
+
+		// отброшенные песни уходят в корзину, песни из корзины отвязываются
+		// от исполнителя: все песни source и песни target, чьё название
+		// займёт перенесённая песня (см. Database.MergeArtists)
+		now := time.Now().UTC()
+		names := make(map[string]bool)
+		for i, s := range songs {
+			if dropped[s.id] && !s.trashed() {
+				songs[i].deletedAt = now
+				songs[i].deletedBy = by
+			}
+			if s.authorID == source && !songs[i].trashed() {
+				names[s.songName] = true
+			}
+		}
+		for i, s := range songs {
+			if s.trashed() && (s.authorID == source || s.authorID == target && names[s.songName]) {
+				songs[i].deletedGroup = m.groups[s.authorID]
+				songs[i].authorID = 0
+			}
+		}
+
+		for i, s := range songs {
+			if s.authorID == source {
+				songs[i].authorID = target
+				songs[i].version++
+				moved = append(moved, [2]Song{m.toSong(s), m.toSong(songs[i])})
+				result.Moved++
+			}
+		}
+		merged = append(merged, source)
+	}
+
+	m.songs = songs
+	for _, song := range moved {
+		m.addSongRevision(song[0], song[1], by)
+	}
+	for _, source := range merged {
+		for _, album := range m.albums {
+			if album.authorID == source {
+				album.authorID = target
+			}
+		}
+		for alias, id := range m.aliases {
+			if id == source {
+				m.aliases[alias] = target
+			}
+		}
+		m.aliases[m.groups[source]] = target
+		delete(m.groups, source)
+		delete(m.artistHistory, source)
+	}
+	m.mu.Unlock()
+
+	var err error
+	result.Target, err = m.GetArtist(target)
+	return result, err
+}
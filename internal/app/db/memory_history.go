@@ -0,0 +1,26 @@
+package db
+
+import "slices"
+
+// история изменений песни, от новых ревизий к старым
+func (m *MemoryStore) SongRevisions(id int64) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return newestFirst(m.songHistory[id]), nil
+}
+
+// история изменений исполнителя, от новых ревизий к старым
+func (m *MemoryStore) ArtistRevisions(id int) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return newestFirst(m.artistHistory[id]), nil
+}
+
+// копия ревизий в обратном порядке, чтобы вызывающий не изменил историю в хранилище
+func newestFirst(revs []Revision) []Revision {
+	revs = append(make([]Revision, 0, len(revs)), revs...)
+	slices.Reverse(revs)
+	return revs
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// строка таблицы playlists, записи хранятся в порядке позиций
+type memPlaylist struct {
+	id      int64
+	name    string
+	owner   string
+	entries []memEntry
+}
+
+type memEntry struct {
+	id     int64
+	songID int64
+}
+
+// список плейлистов по имени, owner != "" - только плейлисты этого пользователя
+func (m *MemoryStore) ListPlaylists(owner, offset, limit string) ([]Playlist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	playlists := make([]Playlist, 0, len(m.playlists))
+	for _, p := range m.playlists {
+		if owner == "" || p.owner == owner {
+			playlists = append(playlists, Playlist{ID: p.id, Name: p.name, Owner: p.owner, SongCount: len(p.entries)})
+		}
+	}
+	sort.Slice(playlists, func(i, j int) bool {
+		if playlists[i].Name != playlists[j].Name {
+			return playlists[i].Name < playlists[j].Name
+		}
+		return playlists[i].ID < playlists[j].ID
+	})
+
+	return page(playlists, offsetInt, limitInt), nil
+}
+
+// плейлист вместе с песнями в порядке их позиций
+func (m *MemoryStore) GetPlaylist(id int64) (Playlist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.playlists[id]
+	if !ok {
+		return Playlist{}, ErrNotFound
+	}
+
+	playlist := Playlist{ID: p.id, Name: p.name, Owner: p.owner, Entries: make([]PlaylistEntry, 0, len(p.entries))}
+	for _, e := range p.entries {
+		i := m.findSongByID(e.songID)
+		if i < 0 {
+			continue
+		}
+		playlist.Entries = append(playlist.Entries, PlaylistEntry{
+			ID:       e.id,
+			Position: len(playlist.Entries) + 1,
+			Song:     m.toSong(m.songs[i]),
+		})
+	}
+	playlist.SongCount = len(playlist.Entries)
+	return playlist, nil
+}
+
+// добавление пустого плейлиста
+func (m *MemoryStore) AddPlaylist(p Playlist) (Playlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.playlists {
+		if other.owner == p.Owner && other.name == p.Name {
+			return Playlist{}, fmt.Errorf("%w: playlist %q of %q", ErrAlreadyExists, p.Name, p.Owner)
+		}
+	}
+
+	p.ID = m.nextPlaylistID
+	m.nextPlaylistID++
+	m.playlists[p.ID] = &memPlaylist{id: p.ID, name: p.Name, owner: p.Owner}
+	return p, nil
+}
+
+// удаление плейлиста, песни остаются в библиотеке
+func (m *MemoryStore) DeletePlaylist(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.playlists[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.playlists, id)
+	return nil
+}
+
+// добавляет песню в конец плейлиста, возвращает id новой записи
+func (m *MemoryStore) AppendToPlaylist(id, songID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[id]
+	if !ok {
+		return 0, fmt.Errorf("%w: playlist %d", ErrNotFound, id)
+	}
+	if m.findSongByID(songID) < 0 {
+		return 0, fmt.Errorf("%w: song %d", ErrNotFound, songID)
+	}
+
+	entryID := m.nextEntryID
+	m.nextEntryID++
+	p.entries = append(p.entries, memEntry{id: entryID, songID: songID})
+	return entryID, nil
+}
+
+// перемещает запись плейлиста на позицию position (с 1)
+func (m *MemoryStore) MovePlaylistEntry(id, entryID int64, position int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	ids := make([]int64, len(p.entries))
+	songs := make(map[int64]int64, len(p.entries))
+	for i, e := range p.entries {
+		ids[i] = e.id
+		songs[e.id] = e.songID
+	}
+	ids, err := moveEntry(ids, entryID, position)
+	if err != nil {
+		return err
+	}
+	for i, entry := range ids {
+		p.entries[i] = memEntry{id: entry, songID: songs[entry]}
+	}
+	return nil
+}
+
+// удаление записи из плейлиста, сама песня остаётся в библиотеке
+func (m *MemoryStore) RemovePlaylistEntry(id, entryID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, e := range p.entries {
+		if e.id == entryID {
+			p.entries = append(p.entries[:i], p.entries[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"sort"
+)
+
+// поиск песен по тексту; релевантность - количество вхождений слов запроса
+func (m *MemoryStore) SearchLyrics(q SearchQuery, p ListParams) ([]SearchHit, error) {
+	offsetInt, limitInt, err := parsePage(p.Offset, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hits := make([]SearchHit, 0, 16)
+	for _, song := range m.filterSongs(p) {
+		if n := q.count(searchWords(song.text)); n > 0 {
+			hits = append(hits, q.hit(m.toSong(song), float64(n)))
+		}
+	}
+
+	less := songLess("")
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return less(hits[i].Song, hits[j].Song)
+	})
+
+	return page(hits, offsetInt, limitInt), nil
+}
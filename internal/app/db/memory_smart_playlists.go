@@ -0,0 +1,99 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// список умных плейлистов по имени (без песен),
+// owner != "" - только плейлисты этого пользователя
+func (m *MemoryStore) ListSmartPlaylists(owner, offset, limit string) ([]SmartPlaylist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	playlists := make([]SmartPlaylist, 0, len(m.smartPlaylists))
+	for _, p := range m.smartPlaylists {
+		if owner == "" || p.Owner == owner {
+			playlists = append(playlists, p)
+		}
+	}
+	sort.Slice(playlists, func(i, j int) bool {
+		if playlists[i].Name != playlists[j].Name {
+			return playlists[i].Name < playlists[j].Name
+		}
+		return playlists[i].ID < playlists[j].ID
+	})
+
+	return page(playlists, offsetInt, limitInt), nil
+}
+
+// определение умного плейлиста (см. Database.GetSmartPlaylist)
+func (m *MemoryStore) GetSmartPlaylist(id int64) (SmartPlaylist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.smartPlaylists[id]
+	if !ok {
+		return SmartPlaylist{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (m *MemoryStore) AddSmartPlaylist(p SmartPlaylist) (SmartPlaylist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findSmartPlaylist(p.Owner, p.Name) != 0 {
+		return SmartPlaylist{}, fmt.Errorf("%w: smart playlist %q of %q", ErrAlreadyExists, p.Name, p.Owner)
+	}
+
+	p.ID = m.nextSmartPlaylistID
+	m.nextSmartPlaylistID++
+	p.Songs = nil
+	m.smartPlaylists[p.ID] = p
+	return p, nil
+}
+
+// замена определения умного плейлиста
+func (m *MemoryStore) UpdateSmartPlaylist(id int64, p SmartPlaylist) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.smartPlaylists[id]; !ok {
+		return ErrNotFound
+	}
+	if other := m.findSmartPlaylist(p.Owner, p.Name); other != 0 && other != id {
+		return fmt.Errorf("%w: smart playlist %q of %q", ErrAlreadyExists, p.Name, p.Owner)
+	}
+
+	p.ID = id
+	p.Songs = nil
+	m.smartPlaylists[id] = p
+	return nil
+}
+
+func (m *MemoryStore) DeleteSmartPlaylist(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.smartPlaylists[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.smartPlaylists, id)
+	return nil
+}
+
+// id умного плейлиста пользователя owner с именем name или 0
+func (m *MemoryStore) findSmartPlaylist(owner, name string) int64 {
+	for id, p := range m.smartPlaylists {
+		if p.Owner == owner && p.Name == name {
+			return id
+		}
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// подсказки по началу имени, см. Database.Suggest
+func (m *MemoryStore) Suggest(kind, prefix string, limit int) ([]Suggestion, error) {
+	prefix = foldName(prefix)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	suggestions := make([]Suggestion, 0, limit)
+	switch kind {
+	case SuggestArtist:
+		for id, name := range m.groups {
+			if strings.HasPrefix(foldName(name), prefix) {
+				suggestions = append(suggestions, Suggestion{ID: int64(id), Name: name})
+			}
+		}
+	case SuggestSong:
+		for _, song := range m.songs {
+			if !song.trashed() && strings.HasPrefix(foldName(song.songName), prefix) {
+				suggestions = append(suggestions, Suggestion{ID: song.id, Name: song.songName, Group: m.groups[song.authorID]})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown suggestion kind %q", kind)
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if x, y := foldName(a.Name), foldName(b.Name); x != y {
+			return x < y
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Group < b.Group
+	})
+	return page(suggestions, 0, limit), nil
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// строка таблицы tags
+type memTag struct {
+	id       int64
+	name     string
+	kind     string
+	parentID *int64
+}
+
+// список тегов, отсортированный по имени, kind != "" - только теги этого вида
+func (m *MemoryStore) ListTags(kind string) ([]Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.collectTags(func(t *memTag) bool {
+		return kind == "" || t.kind == kind
+	}), nil
+}
+
+// тег вместе с его непосредственными поджанрами
+func (m *MemoryStore) GetTag(id int64) (Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.tags[id]
+	if !ok {
+		return Tag{}, ErrNotFound
+	}
+	tag := toTag(t)
+	tag.Children = m.collectTags(func(child *memTag) bool {
+		return child.parentID != nil && *child.parentID == id
+	})
+	return tag, nil
+}
+
+func (m *MemoryStore) AddTag(t Tag) (Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := checkTagParent(0, t, m.tagLookup)
+	if err != nil {
+		return Tag{}, err
+	}
+	if m.findTag(t.Name) != nil {
+		return Tag{}, fmt.Errorf("%w: tag %q", ErrAlreadyExists, t.Name)
+	}
+
+	t.ID = m.nextTagID
+	m.nextTagID++
+	m.tags[t.ID] = &memTag{id: t.ID, name: t.Name, kind: t.Kind, parentID: t.ParentID}
+	return t, nil
+}
+
+// замена имени, вида и родителя тега (см. Database.UpdateTag)
+func (m *MemoryStore) UpdateTag(id int64, t Tag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag, ok := m.tags[id]
+	if !ok {
+		return ErrNotFound
+	}
+	err := checkTagParent(id, t, m.tagLookup)
+	if err != nil {
+		return err
+	}
+	if t.Kind != TagKindGenre {
+		for _, child := range m.tags {
+			if child.parentID != nil && *child.parentID == id {
+				return fmt.Errorf("%w: genre has subgenres", ErrBadTagParent)
+			}
+		}
+	}
+	if other := m.findTag(t.Name); other != nil && other.id != id {
+		return fmt.Errorf("%w: tag %q", ErrAlreadyExists, t.Name)
+	}
+
+	tag.name, tag.kind, tag.parentID = t.Name, t.Kind, t.ParentID
+	return nil
+}
+
+// удаление тега, его поджанры становятся жанрами верхнего уровня
+func (m *MemoryStore) DeleteTag(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tags[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.tags, id)
+	for _, t := range m.tags {
+		if t.parentID != nil && *t.parentID == id {
+			t.parentID = nil
+		}
+	}
+	for _, tags := range m.songTags {
+		delete(tags, id)
+	}
+	return nil
+}
+
+// теги песни, отсортированные по имени
+func (m *MemoryStore) SongTags(songID int64) ([]Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.findSongByID(songID) < 0 {
+		return nil, ErrNotFound
+	}
+	return m.collectTags(func(t *memTag) bool {
+		return m.songTags[songID][t.id]
+	}), nil
+}
+
+// отмечает песню тегом, повторная отметка ничего не меняет
+func (m *MemoryStore) AttachTag(songID, tagID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findSongByID(songID) < 0 {
+		return fmt.Errorf("%w: song %d", ErrNotFound, songID)
+	}
+	if _, ok := m.tags[tagID]; !ok {
+		return fmt.Errorf("%w: tag %d", ErrNotFound, tagID)
+	}
+
+	if m.songTags[songID] == nil {
+		m.songTags[songID] = make(map[int64]bool)
+	}
+	m.songTags[songID][tagID] = true
+	return nil
+}
+
+// снимает тег с песни
+func (m *MemoryStore) DetachTag(songID, tagID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.songTags[songID][tagID] {
+		return ErrNotFound
+	}
+	delete(m.songTags[songID], tagID)
+	return nil
+}
+
+func toTag(t *memTag) Tag {
+	return Tag{ID: t.id, Name: t.name, Kind: t.kind, ParentID: t.parentID}
+}
+
+func (m *MemoryStore) tagLookup(id int64) (string, *int64, error) {
+	t, ok := m.tags[id]
+	if !ok {
+		return "", nil, ErrNotFound
+	}
+	return t.kind, t.parentID, nil
+}
+
+func (m *MemoryStore) findTag(name string) *memTag {
+	for _, t := range m.tags {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// теги, удовлетворяющие match, отсортированные по имени
+func (m *MemoryStore) collectTags(match func(*memTag) bool) []Tag {
+	tags := make([]Tag, 0, 16)
+	for _, t := range m.tags {
+		if match(t) {
+			tags = append(tags, toTag(t))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags
+}
+
+// id тега с именем name и всех его поджанров (см. songHasTag)
+func (m *MemoryStore) tagSubtree(name string) map[int64]bool {
+	subtree := make(map[int64]bool)
+	root := m.findTag(name)
+	if root == nil {
+		return subtree
+	}
+	subtree[root.id] = true
+	for added := true; added; {
+		added = false
+		for _, t := range m.tags {
+			if !subtree[t.id] && t.parentID != nil && subtree[*t.parentID] {
+				subtree[t.id] = true
+				added = true
+			}
+		}
+	}
+	return subtree
+}
+
+// песня отмечена хотя бы одним тегом из subtree
+func (m *MemoryStore) songHasTag(songID int64, subtree map[int64]bool) bool {
+	for tagID := range m.songTags[songID] {
+		if subtree[tagID] {
+			return true
+		}
+	}
+	return false
+}
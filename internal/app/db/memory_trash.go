@@ -0,0 +1,124 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// песни в корзине, последние удалённые - первыми
+func (m *MemoryStore) ListTrash(offset, limit string) ([]TrashedSong, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trash := make([]TrashedSong, 0, 16)
+	for _, song := range m.songs {
+		if song.trashed() {
+			trash = append(trash, TrashedSong{Song: m.toSong(song), DeletedAt: song.deletedAt, DeletedBy: song.deletedBy})
+		}
+	}
+	sort.Slice(trash, func(i, j int) bool {
+		if !trash[i].DeletedAt.Equal(trash[j].DeletedAt) {
+			return trash[i].DeletedAt.After(trash[j].DeletedAt)
+		}
+		return trash[i].ID < trash[j].ID
+	})
+	return page(trash, offsetInt, limitInt), nil
+}
+
+// возвращает песню из корзины (см. Database.RestoreSong)
+func (m *MemoryStore) RestoreSong(id int64, by string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.findTrashed(id)
+	if i < 0 {
+		return fmt.Errorf("%w: song %d is not in the trash", ErrNotFound, id)
+	}
+	song := m.songs[i]
+	group := m.toSong(song).Group
+	authorID := song.authorID
+	if authorID == 0 {
+		authorID = m.authorID(song.deletedGroup)
+	}
+	for j, other := range m.songs {
+		if j != i && other.authorID == authorID && other.songName == song.songName {
+			return restoreConflict(m.groups[authorID], song.songName)
+		}
+	}
+
+	song.authorID = authorID
+	song.deletedAt = time.Time{}
+	song.deletedBy = ""
+	song.deletedGroup = ""
+	song.version++
+	m.songs[i] = song
+	m.songHistory[id] = append(m.songHistory[id], Revision{
+		Version:   song.version,
+		ChangedBy: by,
+		ChangedAt: time.Now().UTC(),
+		Changes:   restoreChanges(group, m.groups[authorID]),
+	})
+	return nil
+}
+
+// окончательно удаляет песню из корзины
+func (m *MemoryStore) PurgeSong(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.findTrashed(id)
+	if i < 0 {
+		return fmt.Errorf("%w: song %d is not in the trash", ErrNotFound, id)
+	}
+	m.forgetSong(id)
+	m.songs = append(m.songs[:i], m.songs[i+1:]...)
+	return nil
+}
+
+// окончательно удаляет песни, попавшие в корзину раньше before,
+// возвращает их количество
+func (m *MemoryStore) PurgeTrash(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	songs := m.songs[:0]
+	var purged int64
+	for _, song := range m.songs {
+		if song.trashed() && song.deletedAt.Before(before) {
+			m.forgetSong(song.id)
+			purged++
+			continue
+		}
+		songs = append(songs, song)
+	}
+	m.songs = songs
+	return purged, nil
+}
+
+// переносит песню m.songs[i] в корзину от имени by
+func (m *MemoryStore) trashSong(i int, by string) {
+	m.songs[i].deletedAt = time.Now().UTC()
+	m.songs[i].deletedBy = by
+}
+
+// отвязывает песню из корзины m.songs[i] от исполнителя, сохраняя его имя (см. trash.go)
+func (m *MemoryStore) detachSong(i int) {
+	m.songs[i].deletedGroup = m.groups[m.songs[i].authorID]
+	m.songs[i].authorID = 0
+}
+
+// возвращает индекс песни из корзины с указанным id в m.songs или -1
+func (m *MemoryStore) findTrashed(id int64) int {
+	for i, song := range m.songs {
+		if song.id == id && song.trashed() {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+-- суррогатный идентификатор песни, не зависящий от её названия и исполнителя
+-- существующие песни получают id при добавлении колонки
+alter table songs add column song_id int generated always as identity;
+
+alter table songs add constraint songs_song_id_key unique (song_id);
+
+-- +goose Down
+alter table songs drop column song_id;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- прежние имена исполнителей, объединённых с другими,
+-- запросы по старому имени перенаправляются на исполнителя author_id
+CREATE TABLE IF NOT EXISTS artist_aliases(
+    alias text primary key,
+    author_id int not null references groups (author_id) on delete cascade
+);
+
+create index on artist_aliases (
+    author_id
+);
+
+-- +goose Down
+DROP TABLE artist_aliases;
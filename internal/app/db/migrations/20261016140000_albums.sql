@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS albums(
+    album_id int generated always as identity primary key,
+    title text not null,
+    author_id int not null references groups (author_id) on delete cascade,
+    release_date date,
+    album_type text not null default 'LP' check (album_type in ('LP', 'EP', 'single'))
+);
+
+create index on albums (
+    author_id
+);
+
+-- песни альбома с номером диска и позицией трека на нём
+CREATE TABLE IF NOT EXISTS album_tracks(
+    album_id int references albums (album_id) on delete cascade,
+    song_id int references songs (song_id) on delete cascade,
+    disc int not null default 1 check (disc > 0),
+    position int not null check (position > 0),
+primary key (album_id, song_id),
+unique (album_id, disc, position)
+);
+
+create index on album_tracks (
+    song_id
+);
+
+-- +goose Down
+DROP TABLE album_tracks;
+DROP TABLE albums;
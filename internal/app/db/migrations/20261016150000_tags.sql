@@ -0,0 +1,27 @@
+-- +goose Up
+-- теги песен: жанры (kind = 'genre') образуют иерархию через parent_id,
+-- произвольные теги (kind = 'tag') иерархии не имеют
+CREATE TABLE IF NOT EXISTS tags(
+    tag_id int generated always as identity primary key,
+    name text not null unique,
+    kind text not null default 'tag' check (kind in ('genre', 'tag')),
+    parent_id int references tags (tag_id) on delete set null
+);
+
+create index on tags (
+    parent_id
+);
+
+CREATE TABLE IF NOT EXISTS song_tags(
+    song_id int references songs (song_id) on delete cascade,
+    tag_id int references tags (tag_id) on delete cascade,
+primary key (song_id, tag_id)
+);
+
+create index on song_tags (
+    tag_id
+);
+
+-- +goose Down
+DROP TABLE song_tags;
+DROP TABLE tags;
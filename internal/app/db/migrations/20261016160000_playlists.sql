@@ -0,0 +1,29 @@
+-- +goose Up
+-- плейлисты пользователей, имя плейлиста уникально в пределах владельца
+CREATE TABLE IF NOT EXISTS playlists(
+    playlist_id int generated always as identity primary key,
+    name text not null,
+    owner text not null,
+unique (owner, name)
+);
+
+-- песни плейлиста, одна песня может встречаться несколько раз
+-- position задаёт только порядок, номера позиций могут идти с пропусками
+CREATE TABLE IF NOT EXISTS playlist_entries(
+    entry_id int generated always as identity primary key,
+    playlist_id int not null references playlists (playlist_id) on delete cascade,
+    song_id int not null references songs (song_id) on delete cascade,
+    position int not null
+);
+
+create index on playlist_entries (
+    playlist_id, position
+);
+
+create index on playlist_entries (
+    song_id
+);
+
+-- +goose Down
+DROP TABLE playlist_entries;
+DROP TABLE playlists;
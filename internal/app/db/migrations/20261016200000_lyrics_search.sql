@@ -0,0 +1,12 @@
+-- +goose Up
+-- полнотекстовый поиск по текстам песен (/library/search), конфигурация simple
+-- не привязана к языку: тексты бывают и на русском, и на английском
+alter table songs add column song_tsv tsvector
+    generated always as (to_tsvector('simple', coalesce(song_text, ''))) stored;
+
+create index songs_song_tsv_idx on songs using gin (
+    song_tsv
+);
+
+-- +goose Down
+alter table songs drop column song_tsv;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- нечёткий поиск по исполнителю и названию песни (fuzzy=true в /library/all),
+-- pg_trgm - доверенное расширение, его может установить владелец базы данных
+create extension if not exists pg_trgm;
+
+create index groups_author_name_trgm_idx on groups using gin (
+    author_name gin_trgm_ops
+);
+
+create index songs_song_name_trgm_idx on songs using gin (
+    song_name gin_trgm_ops
+);
+
+-- +goose Down
+drop index songs_song_name_trgm_idx;
+drop index groups_author_name_trgm_idx;
+drop extension if exists pg_trgm;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- подсказки по началу имени исполнителя и песни (/library/suggest) без учёта
+-- регистра и диакритики. unaccent не объявлена immutable и не может
+-- использоваться в индексе напрямую, поэтому она оборачивается в fold_name
+create extension if not exists unaccent;
+
+-- +goose StatementBegin
+create function fold_name(text) returns text
+    language sql immutable strict parallel safe
+    as $$ select lower(public.unaccent('public.unaccent'::regdictionary, $1)) $$;
+-- +goose StatementEnd
+
+-- text_pattern_ops позволяет использовать индекс для like 'префикс%'
+create index groups_author_name_fold_idx on groups (
+    fold_name(author_name) text_pattern_ops
+);
+
+create index songs_song_name_fold_idx on songs (
+    fold_name(song_name) text_pattern_ops
+);
+
+-- +goose Down
+drop index songs_song_name_fold_idx;
+drop index groups_author_name_fold_idx;
+drop function fold_name(text);
+drop extension if exists unaccent;
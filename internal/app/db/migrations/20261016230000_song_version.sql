@@ -0,0 +1,7 @@
+-- +goose Up
+-- версия песни для оптимистичной блокировки (ETag, If-Match):
+-- увеличивается при каждом изменении песни
+alter table songs add column version bigint not null default 1;
+
+-- +goose Down
+alter table songs drop column version;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- история изменений песен и исполнителей: по строке на каждое изменённое поле.
+-- Ревизия песни - её версия после изменения (songs.version), ревизия
+-- исполнителя - порядковый номер изменения, 1 - исходное имя
+create table song_history (
+    history_id bigint generated always as identity primary key,
+    song_id int not null references songs (song_id) on delete cascade,
+    version bigint not null,
+    changed_by text not null,
+    changed_at timestamptz not null default now(),
+    field text not null,
+    old_value text not null,
+    new_value text not null
+);
+
+create index song_history_song_id_idx on song_history (song_id, version);
+
+create table artist_history (
+    history_id bigint generated always as identity primary key,
+    author_id int not null references groups (author_id) on delete cascade,
+    version bigint not null,
+    changed_by text not null,
+    changed_at timestamptz not null default now(),
+    field text not null,
+    old_value text not null,
+    new_value text not null
+);
+
+create index artist_history_author_id_idx on artist_history (author_id, version);
+
+-- +goose Down
+drop table artist_history;
+drop table song_history;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- песни удаляются в корзину: deleted_at - время удаления (null - песня не удалена),
+-- deleted_by - кто удалил. Песня в корзине занимает своё название у исполнителя,
+-- пока её не восстановят или не удалят окончательно
+alter table songs add column deleted_at timestamptz;
+alter table songs add column deleted_by text;
+-- песня в корзине, чей исполнитель удалён или объединён с другим, отвязывается
+-- от него (author_id = null) и хранит его имя, чтобы вернуться к нему при восстановлении
+alter table songs add column deleted_group text;
+
+-- у отвязанной песни нет исполнителя, поэтому первичный ключ (author_id, song_name)
+-- заменяется ограничением unique: песни с author_id = null друг другу не мешают
+alter table songs drop constraint songs_pkey;
+alter table songs alter column author_id drop not null;
+alter table songs alter column song_name set not null;
+alter table songs add constraint songs_author_id_song_name_key unique (author_id, song_name);
+
+-- корзина и её очистка выбирают песни по времени удаления
+create index songs_deleted_at_idx on songs (deleted_at) where deleted_at is not null;
+
+-- +goose Down
+delete from songs where deleted_at is not null;
+drop index songs_deleted_at_idx;
+alter table songs drop constraint songs_author_id_song_name_key;
+alter table songs add primary key (author_id, song_name);
+alter table songs drop column deleted_group;
+alter table songs drop column deleted_by;
+alter table songs drop column deleted_at;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS groups(
+    author_id integer primary key autoincrement,
+    author_name text unique not null
+);
+
+CREATE TABLE IF NOT EXISTS songs(
+    author_id integer,
+    song_name text,
+    -- в sqlite нет типа date, дата хранится строкой yyyy-mm-dd
+    release_date text,
+    song_text text,
+    link text,
+primary key (author_id, song_name)
+);
+
+create index songs_song_name_idx on songs (
+    song_name
+);
+
+create index songs_release_date_idx on songs (
+    release_date
+);
+
+-- +goose Down
+DROP TABLE groups;
+DROP TABLE songs;
@@ -0,0 +1,52 @@
+-- +goose Up
+-- sqlite не умеет добавлять автоинкрементную колонку в существующую таблицу,
+-- поэтому таблица пересоздаётся
+CREATE TABLE songs_new(
+    song_id integer primary key autoincrement,
+    author_id integer,
+    song_name text,
+    release_date text,
+    song_text text,
+    link text,
+unique (author_id, song_name)
+);
+
+insert into songs_new (author_id, song_name, release_date, song_text, link)
+select author_id, song_name, release_date, song_text, link from songs;
+
+DROP TABLE songs;
+
+alter table songs_new rename to songs;
+
+create index songs_song_name_idx on songs (
+    song_name
+);
+
+create index songs_release_date_idx on songs (
+    release_date
+);
+
+-- +goose Down
+CREATE TABLE songs_old(
+    author_id integer,
+    song_name text,
+    release_date text,
+    song_text text,
+    link text,
+primary key (author_id, song_name)
+);
+
+insert into songs_old (author_id, song_name, release_date, song_text, link)
+select author_id, song_name, release_date, song_text, link from songs;
+
+DROP TABLE songs;
+
+alter table songs_old rename to songs;
+
+create index songs_song_name_idx on songs (
+    song_name
+);
+
+create index songs_release_date_idx on songs (
+    release_date
+);
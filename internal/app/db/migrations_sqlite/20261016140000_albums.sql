@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS albums(
+    album_id integer primary key autoincrement,
+    title text not null,
+    author_id integer not null references groups (author_id) on delete cascade,
+    release_date text,
+    album_type text not null default 'LP' check (album_type in ('LP', 'EP', 'single'))
+);
+
+create index albums_author_id_idx on albums (
+    author_id
+);
+
+-- песни альбома с номером диска и позицией трека на нём
+CREATE TABLE IF NOT EXISTS album_tracks(
+    album_id integer references albums (album_id) on delete cascade,
+    song_id integer references songs (song_id) on delete cascade,
+    disc integer not null default 1 check (disc > 0),
+    position integer not null check (position > 0),
+primary key (album_id, song_id),
+unique (album_id, disc, position)
+);
+
+create index album_tracks_song_id_idx on album_tracks (
+    song_id
+);
+
+-- +goose Down
+DROP TABLE album_tracks;
+DROP TABLE albums;
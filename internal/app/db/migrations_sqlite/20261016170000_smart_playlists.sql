@@ -0,0 +1,14 @@
+-- +goose Up
+-- умные плейлисты: песни не хранятся, а выбираются по дереву правил при каждом чтении
+CREATE TABLE IF NOT EXISTS smart_playlists(
+    smart_playlist_id integer primary key autoincrement,
+    name text not null,
+    owner text not null,
+    rules text not null,
+    sort text not null default '',
+    song_limit integer not null default 0 check (song_limit >= 0),
+unique (owner, name)
+);
+
+-- +goose Down
+DROP TABLE smart_playlists;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- ключи доступа к api, хранится только sha256 от ключа
+CREATE TABLE IF NOT EXISTS api_keys(
+    key_id integer primary key autoincrement,
+    name text not null,
+    key_hash text not null unique,
+    role text not null check (role in ('reader', 'editor', 'admin')),
+    created_at timestamp not null,
+    revoked_at timestamp
+);
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- отозванные токены доступа (jti), запись не нужна после истечения срока токена
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    jti text primary key,
+    expires_at timestamp not null
+);
+
+-- +goose Down
+DROP TABLE revoked_tokens;
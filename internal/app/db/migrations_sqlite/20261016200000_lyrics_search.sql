@@ -0,0 +1,36 @@
+-- +goose Up
+-- полнотекстовый поиск по текстам песен (/library/search): индекс fts5
+-- над колонкой songs.song_text, синхронизируется триггерами
+CREATE VIRTUAL TABLE songs_fts USING fts5(
+    song_text,
+    content = 'songs',
+    content_rowid = 'song_id',
+    tokenize = 'unicode61 remove_diacritics 0'
+);
+
+insert into songs_fts (songs_fts) values ('rebuild');
+
+-- +goose StatementBegin
+create trigger songs_fts_insert after insert on songs begin
+    insert into songs_fts (rowid, song_text) values (new.song_id, new.song_text);
+end;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+create trigger songs_fts_delete after delete on songs begin
+    insert into songs_fts (songs_fts, rowid, song_text) values ('delete', old.song_id, old.song_text);
+end;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+create trigger songs_fts_update after update of song_text on songs begin
+    insert into songs_fts (songs_fts, rowid, song_text) values ('delete', old.song_id, old.song_text);
+    insert into songs_fts (rowid, song_text) values (new.song_id, new.song_text);
+end;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER songs_fts_update;
+DROP TRIGGER songs_fts_delete;
+DROP TRIGGER songs_fts_insert;
+DROP TABLE songs_fts;
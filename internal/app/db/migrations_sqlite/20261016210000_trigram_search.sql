@@ -0,0 +1,8 @@
+-- +goose Up
+-- нечёткий поиск по исполнителю и названию песни: в sqlite нет pg_trgm,
+-- функция similarity регистрируется в go (см. sqlite.go), а подходящего
+-- индекса для неё нет - схема не меняется, миграция сохраняет общую нумерацию версий
+select 1;
+
+-- +goose Down
+select 1;
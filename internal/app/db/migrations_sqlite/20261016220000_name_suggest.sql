@@ -0,0 +1,14 @@
+-- +goose Up
+-- подсказки по началу имени исполнителя и песни (/library/suggest) без учёта
+-- регистра и диакритики, fold_name - функция на go (см. sqlite.go)
+create index groups_author_name_fold_idx on groups (
+    fold_name(author_name)
+);
+
+create index songs_song_name_fold_idx on songs (
+    fold_name(song_name)
+);
+
+-- +goose Down
+DROP INDEX songs_song_name_fold_idx;
+DROP INDEX groups_author_name_fold_idx;
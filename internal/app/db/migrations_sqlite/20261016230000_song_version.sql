@@ -0,0 +1,7 @@
+-- +goose Up
+-- версия песни для оптимистичной блокировки (ETag, If-Match):
+-- увеличивается при каждом изменении песни
+ALTER TABLE songs ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE songs DROP COLUMN version;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- история изменений песен и исполнителей (см. миграцию postgres)
+create table song_history (
+    history_id integer primary key autoincrement,
+    song_id integer not null references songs (song_id) on delete cascade,
+    version integer not null,
+    changed_by text not null,
+    changed_at timestamp not null,
+    field text not null,
+    old_value text not null,
+    new_value text not null
+);
+
+create index song_history_song_id_idx on song_history (song_id, version);
+
+create table artist_history (
+    history_id integer primary key autoincrement,
+    author_id integer not null references groups (author_id) on delete cascade,
+    version integer not null,
+    changed_by text not null,
+    changed_at timestamp not null,
+    field text not null,
+    old_value text not null,
+    new_value text not null
+);
+
+create index artist_history_author_id_idx on artist_history (author_id, version);
+
+-- +goose Down
+drop table artist_history;
+drop table song_history;
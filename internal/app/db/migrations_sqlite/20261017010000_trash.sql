@@ -0,0 +1,17 @@
+-- +goose Up
+-- песни удаляются в корзину: deleted_at - время удаления (null - песня не удалена),
+-- deleted_by - кто удалил, deleted_group - имя исполнителя песни, отвязанной от него
+-- при удалении или объединении исполнителя (author_id = null, см. postgres).
+-- Уникальность (author_id, song_name) на такие песни не распространяется
+ALTER TABLE songs ADD COLUMN deleted_at timestamp;
+ALTER TABLE songs ADD COLUMN deleted_by text;
+ALTER TABLE songs ADD COLUMN deleted_group text;
+
+create index songs_deleted_at_idx on songs (deleted_at) where deleted_at is not null;
+
+-- +goose Down
+delete from songs where deleted_at is not null;
+DROP INDEX songs_deleted_at_idx;
+ALTER TABLE songs DROP COLUMN deleted_group;
+ALTER TABLE songs DROP COLUMN deleted_by;
+ALTER TABLE songs DROP COLUMN deleted_at;
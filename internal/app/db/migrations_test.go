@@ -0,0 +1,35 @@
+package db
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// у postgres и sqlite одна целевая версия схемы (targetDBver),
+// поэтому миграции обоих хранилищ должны совпадать по версиям
+func TestMigrationsInLockstep(t *testing.T) {
+	versions := func(dir string) []string {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vers []string
+		for _, e := range entries {
+			if ver, _, ok := strings.Cut(e.Name(), "_"); ok && strings.HasSuffix(e.Name(), ".sql") {
+				vers = append(vers, ver)
+			}
+		}
+		return vers
+	}
+
+	pg := versions("./internal/app/db/migrations/")
+	sqlite := versions(sqliteMigrationsDir)
+	if strings.Join(pg, ",") != strings.Join(sqlite, ",") {
+		t.Errorf("migration versions differ:\npostgres %v\nsqlite   %v", pg, sqlite)
+	}
+	if last := pg[len(pg)-1]; last != strconv.Itoa(targetDBver) {
+		t.Errorf("last migration %s, targetDBver %d", last, targetDBver)
+	}
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Playlist struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Owner     string          `json:"owner"`
+	SongCount int             `json:"songCount"`
+	Entries   []PlaylistEntry `json:"entries,omitempty"`
+}
+
+// песня в плейлисте, позиции нумеруются с 1 без пропусков
+type PlaylistEntry struct {
+	ID       int64 `json:"id"`
+	Position int   `json:"position"`
+	Song     Song  `json:"song"`
+}
+
+// переставляет entryID в списке entries на позицию position (с 1),
+// позиция больше длины списка означает его конец
+func moveEntry(entries []int64, entryID int64, position int) ([]int64, error) {
+	from := -1
+	for i, id := range entries {
+		if id == entryID {
+			from = i
+			break
+		}
+	}
+	if from < 0 {
+		return nil, fmt.Errorf("%w: playlist entry %d", ErrNotFound, entryID)
+	}
+
+	to := min(max(position, 1), len(entries)) - 1
+	moved := make([]int64, 0, len(entries))
+	moved = append(moved, entries[:from]...)
+	moved = append(moved, entries[from+1:]...)
+	moved = append(moved[:to], append([]int64{entryID}, moved[to:]...)...)
+	return moved, nil
+}
+
+// список плейлистов по имени, owner != "" - только плейлисты этого пользователя
+func (db *Database) ListPlaylists(owner, offset, limit string) ([]Playlist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), `select playlists.playlist_id, playlists.name, playlists.owner,
+count(playlist_entries.entry_id)
+from playlists left join playlist_entries using (playlist_id)
+where ($1 = '' or playlists.owner = $1)
+group by playlists.playlist_id
+order by playlists.name, playlists.playlist_id offset $2 limit $3`, owner, offsetInt, nullLimit(limitInt))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := make([]Playlist, 0, 16)
+	for rows.Next() {
+		var p Playlist
+		err = rows.Scan(&p.ID, &p.Name, &p.Owner, &p.SongCount)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+	return playlists, rows.Err()
+}
+
+// плейлист вместе с песнями в порядке их позиций
+func (db *Database) GetPlaylist(id int64) (Playlist, error) {
+	var p Playlist
+	err := db.dbConn.QueryRow(context.Background(), `select playlist_id, name, owner from playlists where playlist_id=$1`, id).
+		Scan(&p.ID, &p.Name, &p.Owner)
+	if err != nil {
+		return Playlist{}, mapError(err)
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), `select playlist_entries.entry_id,
+songs.song_id, groups.author_name, songs.song_name, coalesce(songs.release_date::text, ''),
+coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version
+from playlist_entries inner join songs using (song_id) inner join groups using (author_id)
+where playlist_entries.playlist_id=$1 and songs.deleted_at is null order by playlist_entries.position, playlist_entries.entry_id`, id)
+	if err != nil {
+		return Playlist{}, err
+	}
+	defer rows.Close()
+
+	p.Entries = make([]PlaylistEntry, 0, 16)
+	for rows.Next() {
+		e := PlaylistEntry{Position: len(p.Entries) + 1}
+		err = rows.Scan(&e.ID, &e.Song.ID, &e.Song.Group, &e.Song.SongName, &e.Song.ReleaseDate, &e.Song.Text, &e.Song.Link, &e.Song.Version)
+		if err != nil {
+			return Playlist{}, err
+		}
+		p.Entries = append(p.Entries, e)
+	}
+	p.SongCount = len(p.Entries)
+	return p, rows.Err()
+}
+
+// добавление пустого плейлиста
+func (db *Database) AddPlaylist(p Playlist) (Playlist, error) {
+	err := db.dbConn.QueryRow(context.Background(), `insert into playlists (name, owner) values ($1, $2) returning playlist_id`,
+		p.Name, p.Owner).Scan(&p.ID)
+	if err != nil {
+		return Playlist{}, mapError(err)
+	}
+	return p, nil
+}
+
+// удаление плейлиста, песни остаются в библиотеке
+func (db *Database) DeletePlaylist(id int64) error {
+	tag, err := db.dbConn.Exec(context.Background(), `delete from playlists where playlist_id=$1`, id)
+	slog.Debug("deleting playlist", "db response", tag.String())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// добавляет песню в конец плейлиста, возвращает id новой записи
+func (db *Database) AppendToPlaylist(id, songID int64) (int64, error) {
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокировка плейлиста упорядочивает параллельные изменения его записей
+	err = tx.QueryRow(ctx, `select playlist_id from playlists where playlist_id=$1 for update`, id).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%w: playlist %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var entryID int64
+	err = tx.QueryRow(ctx, `insert into playlist_entries (playlist_id, song_id, position)
+select $1, song_id, (select coalesce(max(position), 0) + 1 from playlist_entries where playlist_id=$1)
+from songs where song_id=$2 and deleted_at is null returning entry_id`, id, songID).Scan(&entryID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%w: song %d", ErrNotFound, songID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return entryID, tx.Commit(ctx)
+}
+
+// перемещает запись плейлиста на позицию position (с 1)
+func (db *Database) MovePlaylistEntry(id, entryID int64, position int) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `select playlist_id from playlists where playlist_id=$1 for update`, id).Scan(&id)
+	if err != nil {
+		return mapError(err)
+	}
+
+	rows, err := tx.Query(ctx, `select entry_id from playlist_entries where playlist_id=$1
+order by position, entry_id`, id)
+	if err != nil {
+		return err
+	}
+	entries, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return err
+	}
+
+	entries, err = moveEntry(entries, entryID, position)
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		_, err = tx.Exec(ctx, `update playlist_entries set position=$1 where entry_id=$2`, i+1, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// удаление записи из плейлиста, сама песня остаётся в библиотеке
+func (db *Database) RemovePlaylistEntry(id, entryID int64) error {
+	tag, err := db.dbConn.Exec(context.Background(), `delete from playlist_entries where playlist_id=$1 and entry_id=$2`, id, entryID)
+	slog.Debug("removing playlist entry", "db response", tag.String())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// queryArgs - значения параметров запроса, на которые ссылаются
+// нумерованные плейсхолдеры ($1, $2, ...). Используется реализациями
+// на postgres и sqlite, чтобы не подставлять пользовательские данные в текст запроса
+type queryArgs struct {
+	args []any
+}
+
+// добавляет аргумент запроса и возвращает его плейсхолдер
+func (q *queryArgs) arg(value any) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// setClause накапливает присваивания для запроса update
+type setClause struct {
+	queryArgs
+	parts []string
+}
+
+// добавляет присваивание column=$n
+func (c *setClause) add(column string, value any) {
+	c.parts = append(c.parts, column+"="+c.arg(value))
+}
+
+// добавляет присваивание column=expr, где %s в expr заменяется на плейсхолдер значения
+func (c *setClause) addf(column, expr string, value any) {
+	c.parts = append(c.parts, column+"="+fmt.Sprintf(expr, c.arg(value)))
+}
+
+func (c *setClause) empty() bool {
+	return len(c.parts) == 0
+}
+
+func (c *setClause) String() string {
+	return strings.Join(c.parts, ", ")
+}
+
+// whereClause накапливает условия where, объединяемые через and
+type whereClause struct {
+	queryArgs
+	parts []string
+}
+
+// добавляет условие, %s в cond заменяются плейсхолдерами значений values
+// (%[1]s - если одно значение используется несколько раз)
+func (c *whereClause) add(cond string, values ...any) {
+	if len(values) == 0 {
+		c.parts = append(c.parts, cond)
+		return
+	}
+	placeholders := make([]any, len(values))
+	for i, v := range values {
+		placeholders[i] = c.arg(v)
+	}
+	c.parts = append(c.parts, fmt.Sprintf(cond, placeholders...))
+}
+
+func (c *whereClause) String() string {
+	if len(c.parts) == 0 {
+		return "true"
+	}
+	return "(" + strings.Join(c.parts, ") and (") + ")"
+}
+
+// различия sql postgres и sqlite, учитываемые при построении условий
+type dialect struct {
+	// дата выпуска песни в формате yyyy-mm-dd
+	releaseDate string
+	// условие "строка %[1]s содержит подстроку %[2]s" с учётом регистра
+	contains string
+	// хост из ссылки на песню, пустая строка для ссылок без схемы
+	linkHost string
+	// условие "колонка %[1]s похожа на строку %[2]s" для порога сходства %[3]s
+	similar string
+}
+
+var (
+	pgDialect = dialect{
+		releaseDate: "songs.release_date::text",
+		contains:    "strpos(%s, %s) > 0",
+		linkHost:    "split_part(split_part(songs.link, '://', 2), '/', 1)",
+		// оператор % использует триграммный индекс, его порог задаётся
+		// в транзакции запроса (см. Database.querySongs)
+		similar: "%[1]s %% %[2]s and similarity(%[1]s, %[2]s) >= %[3]s",
+	}
+	sqliteDialect = dialect{
+		releaseDate: "songs.release_date",
+		contains:    "instr(%s, %s) > 0",
+		linkHost: `case when instr(songs.link, '://') = 0 then '' else
+substr(substr(songs.link, instr(songs.link, '://') + 3), 1,
+case when instr(substr(songs.link, instr(songs.link, '://') + 3), '/') = 0 then length(songs.link)
+else instr(substr(songs.link, instr(songs.link, '://') + 3), '/') - 1 end) end`,
+		similar: "similarity(%[1]s, %[2]s) >= %[3]s",
+	}
+)
+
+// поля, по которым можно сортировать список песен, и соответствующие им колонки
+// песни без даты выпуска всегда идут последними
+var sortColumns = map[string]string{
+	"id":          "songs.song_id",
+	"artist":      "groups.author_name",
+	"song":        "songs.song_name",
+	"releaseDate": "songs.release_date",
+}
+
+// ключ сортировки: поле из sortColumns и направление
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// разбирает параметр сортировки: поля через запятую, "-" перед полем -
+// по убыванию (например "-releaseDate,song"). Поле не может повторяться
+func sortKeys(sort string) ([]sortKey, bool) {
+	if sort == "" {
+		return nil, true
+	}
+	keys := make([]sortKey, 0, len(sortColumns))
+	for _, part := range strings.Split(sort, ",") {
+		field, desc := strings.CutPrefix(strings.TrimSpace(part), "-")
+		if _, ok := sortColumns[field]; !ok {
+			return nil, false
+		}
+		for _, k := range keys {
+			if k.field == field {
+				return nil, false
+			}
+		}
+		keys = append(keys, sortKey{field, desc})
+	}
+	return keys, true
+}
+
+// ValidSort проверяет параметр сортировки (см. sortKeys).
+// Пустая строка - сортировка по умолчанию
+func ValidSort(sort string) bool {
+	_, ok := sortKeys(sort)
+	return ok
+}
+
+// колонка order by списка песен
+type orderTerm struct {
+	column string
+	desc   bool
+}
+
+// колонки order by для сортировки sort: после заданных полей песни упорядочиваются
+// по исполнителю и названию, а затем по id, чтобы порядок был однозначным
+// и страницы не пересекались
+func songsOrderTerms(sort string) []orderTerm {
+	keys, _ := sortKeys(sort)
+	terms := make([]orderTerm, 0, len(keys)+4)
+	for _, k := range keys {
+		if k.field == "releaseDate" {
+			terms = append(terms, orderTerm{column: "(songs.release_date is null)"})
+		}
+		terms = append(terms, orderTerm{column: sortColumns[k.field], desc: k.desc})
+	}
+	return append(terms, orderTerm{column: "groups.author_name"}, orderTerm{column: "songs.song_name"},
+		orderTerm{column: "songs.song_id"})
+}
+
+// order by для списка песен, reverse - в обратном порядке
+// (для страницы перед курсором, см. Cursor)
+func songsOrder(sort string, reverse bool) string {
+	terms := songsOrderTerms(sort)
+	order := make([]string, len(terms))
+	for i, t := range terms {
+		order[i] = t.column
+		if t.desc != reverse {
+			order[i] += " desc"
+		}
+	}
+	return strings.Join(order, ", ")
+}
+
+// order by для списка песен по параметрам p: при нечётком поиске без сортировки
+// первыми идут песни, больше всего похожие на фильтры author и song
+func songsListOrder(p ListParams, w *whereClause) string {
+	reverse := p.Cursor != nil && p.Cursor.Before
+	if p.Similarity == 0 || p.Sort != "" {
+		return songsOrder(p.Sort, reverse)
+	}
+	var score []string
+	if p.Filter.Group != "" {
+		score = append(score, "similarity(groups.author_name, "+w.arg(p.Filter.Group)+")")
+	}
+	if p.Filter.SongName != "" {
+		score = append(score, "similarity(songs.song_name, "+w.arg(p.Filter.SongName)+")")
+	}
+	if len(score) == 0 {
+		return songsOrder("", reverse)
+	}
+	return strings.Join(score, " + ") + " desc, " + songsOrder("", reverse)
+}
+
+// условия выборки песен по параметрам p для postgres и sqlite
+func songsWhere(p ListParams, d dialect) *whereClause {
+	releaseDate := d.releaseDate
+	var w whereClause
+	s := p.Filter
+
+	// песни из корзины не видны ни в списках, ни в поиске
+	w.add("songs.deleted_at is null")
+
+	switch {
+	case s.Group == "":
+	case p.Similarity > 0:
+		w.add(fmt.Sprintf(d.similar, "groups.author_name", w.arg(s.Group), similarityLiteral(p.Similarity)))
+	default:
+		w.add("songs.author_id = "+authorByName("%[1]s"), s.Group)
+	}
+	switch {
+	case s.SongName == "":
+	case p.Similarity > 0:
+		w.add(fmt.Sprintf(d.similar, "songs.song_name", w.arg(s.SongName), similarityLiteral(p.Similarity)))
+	default:
+		w.add("songs.song_name = %s", s.SongName)
+	}
+	if s.ReleaseDate != "" {
+		// даты хранятся в формате yyyy-mm-dd, приводим к нему и фильтр
+		date := s.ReleaseDate
+		if normalized, err := normalizeDate(date); err == nil {
+			date = normalized
+		}
+		w.add(releaseDate+" = %s", date)
+	}
+	if s.Text != "" {
+		w.add("songs.song_text = %s", s.Text)
+	}
+	if s.Link != "" {
+		w.add("songs.link = %s", s.Link)
+	}
+	if p.Album != 0 {
+		w.add("songs.song_id in (select song_id from album_tracks where album_id = %s)", p.Album)
+	}
+	if len(p.Tags) > 0 {
+		conds := make([]string, len(p.Tags))
+		for i, tag := range p.Tags {
+			conds[i] = fmt.Sprintf(songHasTag, w.arg(tag))
+		}
+		op := " or "
+		if p.TagMode == TagModeAll {
+			op = " and "
+		}
+		w.add("(" + strings.Join(conds, op) + ")")
+	}
+	if p.Rule != nil {
+		w.add(p.Rule.sql(&w, d))
+	}
+	if p.Cursor != nil {
+		w.add(p.Cursor.sql(&w, d))
+	}
+
+	return &w
+}
+
+// песня отмечена тегом с именем %s или любым из его подчинённых жанров
+const songHasTag = `songs.song_id in (select song_tags.song_id from song_tags where song_tags.tag_id in (
+with recursive sub(tag_id) as (
+    select tag_id from tags where name = %s
+    union select tags.tag_id from tags inner join sub on tags.parent_id = sub.tag_id)
+select tag_id from sub))`
+
+// выражение, находящее id исполнителя по имени из параметра param.
+// Если исполнителя с таким именем нет, ищется среди прежних имён
+// объединённых исполнителей (см. MergeArtists). null - исполнитель не найден
+func authorByName(param string) string {
+	return fmt.Sprintf(`coalesce((select author_id from groups where author_name=%[1]s),
+(select author_id from artist_aliases where alias=%[1]s))`, param)
+}
+
+// разбирает параметры пагинации, общие для всех списков
+// limit < 0 означает отсутствие ограничения
+func parsePage(offset, limit string) (offsetInt, limitInt int, err error) {
+	limitInt = -1
+	if offset != "" {
+		offsetInt, err = strconv.Atoi(offset)
+		if err != nil {
+			return 0, 0, err
+		}
+		if offsetInt < 0 {
+			return 0, 0, fmt.Errorf("OFFSET must not be negative")
+		}
+	}
+	if limit != "" {
+		limitInt, err = strconv.Atoi(limit)
+		if err != nil {
+			return 0, 0, err
+		}
+		if limitInt < 0 {
+			return 0, 0, fmt.Errorf("LIMIT must not be negative")
+		}
+	}
+	return offsetInt, limitInt, nil
+}
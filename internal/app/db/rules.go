@@ -0,0 +1,297 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrBadRule возвращается при сохранении некорректного дерева правил
+var ErrBadRule = errors.New("bad rule")
+
+// Rule - узел дерева правил умного плейлиста (и фильтров списка песен).
+// Узел либо объединяет вложенные правила (All, Any, Not), либо проверяет поле песни:
+//
+//	artist, song, link in [строки]       - поле равно одной из строк (исполнитель - с учётом прежних имён)
+//	artist, song, text, link contains    - поле содержит одну из строк
+//	artist, song, link prefix [строки]   - поле начинается с одной из строк
+//	releaseDate between [от, до]         - дата выпуска, пустая граница - без ограничения
+//	releaseDate in [даты]                - дата выпуска совпадает с одной из дат
+//	link platform [платформы]            - ссылка ведёт на одну из платформ (см. LinkPlatforms)
+//
+// IgnoreCase - строки in, contains и prefix сравниваются без учёта регистра и диакритики
+type Rule struct {
+	All        []Rule   `json:"all,omitempty"`
+	Any        []Rule   `json:"any,omitempty"`
+	Not        *Rule    `json:"not,omitempty"`
+	Field      string   `json:"field,omitempty"`
+	Op         string   `json:"op,omitempty"`
+	Values     []string `json:"values,omitempty"`
+	IgnoreCase bool     `json:"ignoreCase,omitempty"`
+}
+
+// домены платформ, на которые могут вести ссылки песен
+var LinkPlatforms = map[string][]string{
+	"youtube":    {"youtube.com", "youtu.be"},
+	"spotify":    {"spotify.com"},
+	"soundcloud": {"soundcloud.com"},
+	"apple":      {"music.apple.com"},
+	"yandex":     {"music.yandex.ru"},
+	"vk":         {"vk.com"},
+}
+
+// допустимые операции для каждого поля
+var ruleOps = map[string][]string{
+	"artist":      {"in", "contains", "prefix"},
+	"song":        {"in", "contains", "prefix"},
+	"releaseDate": {"between", "in"},
+	"text":        {"contains"},
+	"link":        {"platform", "in", "contains", "prefix"},
+}
+
+// колонки строковых полей для операций in, contains и prefix
+var ruleColumns = map[string]string{
+	"artist": "groups.author_name",
+	"song":   "songs.song_name",
+	"text":   "songs.song_text",
+	"link":   "songs.link",
+}
+
+// максимальная вложенность дерева правил
+const maxRuleDepth = 16
+
+// Validate проверяет дерево правил и приводит даты к формату yyyy-mm-dd
+func (r *Rule) Validate() error {
+	return r.validate(0)
+}
+
+func (r *Rule) validate(depth int) error {
+	if depth > maxRuleDepth {
+		return fmt.Errorf("%w: rules are nested deeper than %d", ErrBadRule, maxRuleDepth)
+	}
+
+	kinds := 0
+	for _, set := range []bool{r.All != nil, r.Any != nil, r.Not != nil, r.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("%w: rule must have exactly one of all, any, not, field", ErrBadRule)
+	}
+
+	switch {
+	case r.All != nil || r.Any != nil:
+		if len(r.All)+len(r.Any) == 0 {
+			return fmt.Errorf("%w: all and any need at least one rule", ErrBadRule)
+		}
+		for i := range r.All {
+			if err := r.All[i].validate(depth + 1); err != nil {
+				return err
+			}
+		}
+		for i := range r.Any {
+			if err := r.Any[i].validate(depth + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case r.Not != nil:
+		return r.Not.validate(depth + 1)
+	}
+
+	ops, ok := ruleOps[r.Field]
+	if !ok {
+		return fmt.Errorf("%w: unknown field %q", ErrBadRule, r.Field)
+	}
+	if !slices.Contains(ops, r.Op) {
+		return fmt.Errorf("%w: field %s supports only %s", ErrBadRule, r.Field, strings.Join(ops, ", "))
+	}
+	if r.IgnoreCase && (r.Field == "releaseDate" || r.Op == "platform") {
+		return fmt.Errorf("%w: %s %s can't ignore case", ErrBadRule, r.Field, r.Op)
+	}
+
+	switch r.Op {
+	case "in", "contains", "prefix":
+		// пустая строка совпадала бы с отсутствующим значением только в памяти, но не в sql
+		if len(r.Values) == 0 || slices.Contains(r.Values, "") {
+			return fmt.Errorf("%w: %s %s needs at least one value, values can't be empty", ErrBadRule, r.Field, r.Op)
+		}
+		if r.Field == "releaseDate" {
+			for i, v := range r.Values {
+				date, err := normalizeDate(v)
+				if err != nil {
+					return fmt.Errorf("%w: %w", ErrBadRule, err)
+				}
+				r.Values[i] = date
+			}
+		}
+	case "between":
+		if len(r.Values) != 2 || r.Values[0] == "" && r.Values[1] == "" {
+			return fmt.Errorf("%w: releaseDate between needs two dates, one may be empty", ErrBadRule)
+		}
+		for i, v := range r.Values {
+			date, err := normalizeDate(v)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrBadRule, err)
+			}
+			r.Values[i] = date
+		}
+	case "platform":
+		if len(r.Values) == 0 {
+			return fmt.Errorf("%w: link platform needs at least one value", ErrBadRule)
+		}
+		for _, v := range r.Values {
+			if _, ok := LinkPlatforms[v]; !ok {
+				return fmt.Errorf("%w: unknown platform %q", ErrBadRule, v)
+			}
+		}
+	}
+	return nil
+}
+
+// условие sql для дерева правил, значения добавляются в w
+func (r *Rule) sql(w *whereClause, d dialect) string {
+	switch {
+	case r.All != nil || r.Any != nil:
+		nodes, op, empty := r.All, " and ", "true"
+		if r.Any != nil {
+			nodes, op, empty = r.Any, " or ", "false"
+		}
+		if len(nodes) == 0 {
+			return empty
+		}
+		conds := make([]string, len(nodes))
+		for i := range nodes {
+			conds[i] = nodes[i].sql(w, d)
+		}
+		return "(" + strings.Join(conds, op) + ")"
+	case r.Not != nil:
+		// coalesce - чтобы песня без даты или ссылки попадала под отрицание
+		return "not coalesce(" + r.Not.sql(w, d) + ", false)"
+	}
+
+	conds := make([]string, 0, len(r.Values))
+	switch {
+	case r.Field == "artist" && r.Op == "in" && !r.IgnoreCase:
+		for _, v := range r.Values {
+			conds = append(conds, "songs.author_id = "+authorByName(w.arg(v)))
+		}
+	case r.Field == "releaseDate" && r.Op == "in":
+		for _, v := range r.Values {
+			conds = append(conds, d.releaseDate+" = "+w.arg(v))
+		}
+	case r.Op == "in" || r.Op == "contains" || r.Op == "prefix":
+		column := ruleColumns[r.Field]
+		for _, v := range r.Values {
+			value := w.arg(v)
+			if r.IgnoreCase {
+				column, value = "fold_name("+ruleColumns[r.Field]+")", "fold_name("+value+")"
+			}
+			switch r.Op {
+			case "in":
+				conds = append(conds, column+" = "+value)
+			case "contains":
+				conds = append(conds, fmt.Sprintf(d.contains, column, value))
+			case "prefix":
+				conds = append(conds, fmt.Sprintf("substr(%[1]s, 1, length(%[2]s)) = %[2]s", column, value))
+			}
+		}
+	case r.Field == "releaseDate":
+		if from := r.Values[0]; from != "" {
+			conds = append(conds, d.releaseDate+" >= "+w.arg(from))
+		}
+		if to := r.Values[1]; to != "" {
+			conds = append(conds, d.releaseDate+" <= "+w.arg(to))
+		}
+		return "(" + strings.Join(conds, " and ") + ")"
+	case r.Field == "link":
+		for _, v := range r.Values {
+			for _, domain := range LinkPlatforms[v] {
+				host := "lower(" + d.linkHost + ")"
+				conds = append(conds, fmt.Sprintf("(%[1]s = %[2]s or %[1]s like '%%.' || %[2]s)", host, w.arg(domain)))
+			}
+		}
+	}
+	return "(" + strings.Join(conds, " or ") + ")"
+}
+
+// проверка дерева правил для хранилища в памяти, повторяет sql:
+// artist - функция, сопоставляющая имя исполнителя с его id
+func (r *Rule) match(song Song, authorID int, artist func(name string) (int, bool)) bool {
+	switch {
+	case r.All != nil:
+		for i := range r.All {
+			if !r.All[i].match(song, authorID, artist) {
+				return false
+			}
+		}
+		return true
+	case r.Any != nil:
+		for i := range r.Any {
+			if r.Any[i].match(song, authorID, artist) {
+				return true
+			}
+		}
+		return false
+	case r.Not != nil:
+		return !r.Not.match(song, authorID, artist)
+	}
+
+	switch {
+	case r.Field == "artist" && r.Op == "in" && !r.IgnoreCase:
+		return slices.ContainsFunc(r.Values, func(name string) bool {
+			id, ok := artist(name)
+			return ok && id == authorID
+		})
+	case r.Field == "releaseDate" && r.Op == "in":
+		return song.ReleaseDate != "" && slices.Contains(r.Values, song.ReleaseDate)
+	case r.Op == "in" || r.Op == "contains" || r.Op == "prefix":
+		field := map[string]string{
+			"artist": song.Group,
+			"song":   song.SongName,
+			"text":   song.Text,
+			"link":   song.Link,
+		}[r.Field]
+		return slices.ContainsFunc(r.Values, func(v string) bool {
+			if r.IgnoreCase {
+				field, v = foldName(field), foldName(v)
+			}
+			switch r.Op {
+			case "in":
+				return field == v
+			case "contains":
+				return strings.Contains(field, v)
+			default:
+				return strings.HasPrefix(field, v)
+			}
+		})
+	case r.Field == "releaseDate":
+		if song.ReleaseDate == "" {
+			return false
+		}
+		from, to := r.Values[0], r.Values[1]
+		return (from == "" || song.ReleaseDate >= from) && (to == "" || song.ReleaseDate <= to)
+	case r.Field == "link":
+		host := strings.ToLower(linkHost(song.Link))
+		for _, v := range r.Values {
+			for _, domain := range LinkPlatforms[v] {
+				if host == domain || strings.HasSuffix(host, "."+domain) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// хост ссылки так же, как его вычисляет dialect.linkHost
+func linkHost(link string) string {
+	_, rest, found := strings.Cut(link, "://")
+	if !found {
+		return ""
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return host
+}
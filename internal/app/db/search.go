@@ -0,0 +1,245 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrBadQuery возвращается, если в поисковом запросе нет ни одного слова
+var ErrBadQuery = errors.New("bad search query")
+
+// максимальное количество термов в поисковом запросе
+const maxSearchTerms = 32
+
+// SearchQuery - разобранный поисковый запрос по текстам песен.
+// Песня подходит, если в её тексте встречаются все термы запроса
+type SearchQuery []searchTerm
+
+type searchTerm struct {
+	// слова, идущие в тексте подряд; одно слово - обычный терм
+	words []string
+	// последнее слово может быть лишь началом слова в тексте
+	prefix bool
+}
+
+// SearchHit - песня, найденная по тексту. Сам текст не возвращается,
+// вместо него - куплет, в котором найдены слова запроса
+type SearchHit struct {
+	Song
+	// релевантность, больше - лучше. Значения сравнимы только в пределах одного ответа
+	Rank float64 `json:"rank"`
+	// номер куплета (куплеты разделены пустой строкой), 0 - запрос совпал
+	// только с текстом целиком, например фраза переходит в следующий куплет
+	Verse int `json:"verse"`
+	// куплет (или весь текст при Verse = 0), слова запроса выделены <b></b>
+	Snippet string `json:"snippet"`
+}
+
+// ParseSearchQuery разбирает строку поиска: слова через пробел должны встречаться
+// в тексте все, "слова в кавычках" - подряд, слово* - как начало слова.
+// Регистр не учитывается, знаки препинания игнорируются
+func ParseSearchQuery(q string) (SearchQuery, error) {
+	var query SearchQuery
+	add := func(chunk string) {
+		chunk, prefix := strings.CutSuffix(strings.TrimSpace(chunk), "*")
+		words := searchWords(chunk)
+		if len(words) > 0 {
+			query = append(query, searchTerm{words: words, prefix: prefix})
+		}
+	}
+
+	for q != "" {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if phrase, ok := strings.CutPrefix(q, `"`); ok {
+			// незакрытая кавычка - фраза до конца запроса
+			phrase, rest, _ := strings.Cut(phrase, `"`)
+			if strings.HasPrefix(rest, "*") {
+				phrase, rest = phrase+"*", rest[1:]
+			}
+			add(phrase)
+			q = rest
+			continue
+		}
+		end := strings.IndexFunc(q, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(q)
+		}
+		add(q[:end])
+		q = q[end:]
+	}
+
+	if len(query) == 0 {
+		return nil, fmt.Errorf("%w: no words to search for", ErrBadQuery)
+	}
+	if len(query) > maxSearchTerms {
+		return nil, fmt.Errorf("%w: more than %d terms", ErrBadQuery, maxSearchTerms)
+	}
+	return query, nil
+}
+
+// слова текста в нижнем регистре, так же их выделяют парсер postgres
+// (конфигурация simple) и токенизатор unicode61 в sqlite
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), notWordRune)
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// запрос в синтаксисе to_tsquery: термы через &, слова фразы через <->
+func (q SearchQuery) tsquery() string {
+	terms := make([]string, len(q))
+	for i, t := range q {
+		words := make([]string, len(t.words))
+		for j, w := range t.words {
+			words[j] = "'" + w + "'"
+		}
+		terms[i] = strings.Join(words, " <-> ")
+		if t.prefix {
+			terms[i] += ":*"
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// запрос в синтаксисе fts5 match: "фраза" *, термы через AND
+func (q SearchQuery) fts5() string {
+	terms := make([]string, len(q))
+	for i, t := range q {
+		terms[i] = `"` + strings.Join(t.words, " ") + `"`
+		if t.prefix {
+			terms[i] += " *"
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// количество вхождений слов запроса в слова текста, 0 - какой-то терм не найден
+func (q SearchQuery) count(words []string) int {
+	total := 0
+	for _, t := range q {
+		n := 0
+		for i := 0; i+len(t.words) <= len(words); i++ {
+			if t.matchAt(words, i) {
+				n++
+			}
+		}
+		if n == 0 {
+			return 0
+		}
+		total += n
+	}
+	return total
+}
+
+// фраза терма начинается со слова words[i]
+func (t searchTerm) matchAt(words []string, i int) bool {
+	for j, w := range t.words {
+		if t.prefix && j == len(t.words)-1 {
+			if !strings.HasPrefix(words[i+j], w) {
+				return false
+			}
+		} else if words[i+j] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// слово текста совпадает с каким-либо словом запроса (как в ts_headline,
+// выделяются отдельные слова, даже если фраза целиком не совпала)
+func (q SearchQuery) highlights(word string) bool {
+	for _, t := range q {
+		for j, w := range t.words {
+			if word == w || t.prefix && j == len(t.words)-1 && strings.HasPrefix(word, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// выделяет в тексте слова запроса тегами <b></b>
+func (q SearchQuery) highlight(text string) string {
+	var b strings.Builder
+	for text != "" {
+		start := strings.IndexFunc(text, func(r rune) bool { return !notWordRune(r) })
+		if start < 0 {
+			b.WriteString(text)
+			break
+		}
+		end := strings.IndexFunc(text[start:], notWordRune)
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		b.WriteString(text[:start])
+		if word := text[start:end]; q.highlights(strings.ToLower(word)) {
+			b.WriteString("<b>" + word + "</b>")
+		} else {
+			b.WriteString(word)
+		}
+		text = text[end:]
+	}
+	return b.String()
+}
+
+// результат поиска для песни, найденной sqlite или хранилищем в памяти:
+// первый куплет, в котором встречаются все термы запроса, с выделенными словами
+func (q SearchQuery) hit(s Song, rank float64) SearchHit {
+	h := SearchHit{Rank: rank, Snippet: q.highlight(s.Text)}
+	for i, verse := range strings.Split(s.Text, "\n\n") {
+		if q.count(searchWords(verse)) > 0 {
+			h.Verse, h.Snippet = i+1, q.highlight(verse)
+			break
+		}
+	}
+	s.Text = ""
+	h.Song = s
+	return h
+}
+
+// поиск песен по тексту, отсортированных по релевантности (ts_rank);
+// фильтры p применяются так же, как в ListAllLibrary, сортировка p не учитывается
+func (db *Database) SearchLyrics(q SearchQuery, p ListParams) ([]SearchHit, error) {
+	offsetInt, limitInt, err := parsePage(p.Offset, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	w := songsWhere(p, pgDialect)
+	tsquery := w.arg(q.tsquery())
+	// куплет ищется отдельно: индекс построен по тексту целиком
+	query := `select songs.song_id, groups.author_name, songs.song_name, coalesce(songs.release_date::text, ''),
+coalesce(songs.link, ''), ts_rank(songs.song_tsv, q) as rank, coalesce(verse.n, 0),
+ts_headline('simple', coalesce(verse.verse_text, songs.song_text), q, 'StartSel=<b>, StopSel=</b>, HighlightAll=true')
+from songs inner join groups using (author_id)
+cross join to_tsquery('simple', ` + tsquery + `) as q
+left join lateral (
+    select verse_text, n from regexp_split_to_table(songs.song_text, E'\n\n') with ordinality as verses(verse_text, n)
+    where to_tsvector('simple', verse_text) @@ q order by n limit 1
+) as verse on true
+where songs.song_tsv @@ q and ` + w.String() + `
+order by rank desc, groups.author_name, songs.song_name offset ` + w.arg(offsetInt) + ` limit ` + w.arg(nullLimit(limitInt))
+
+	slog.Debug("search lyrics database query", "query", q.tsquery(), "params", p)
+
+	hits := make([]SearchHit, 0, 16)
+	err = db.querySongs(p, query, w.args, func(rows pgx.Rows) error {
+		var h SearchHit
+		err := rows.Scan(&h.ID, &h.Group, &h.SongName, &h.ReleaseDate, &h.Link, &h.Rank, &h.Verse, &h.Snippet)
+		hits = append(hits, h)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"strconv"
+)
+
+// DefaultSimilarity - порог сходства по умолчанию, как pg_trgm.similarity_threshold
+const DefaultSimilarity = 0.3
+
+// триграммы строки так же, как их выделяет pg_trgm: строка приводится
+// к нижнему регистру и делится на слова, каждое слово дополняется
+// двумя пробелами в начале и одним в конце
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range searchWords(s) {
+		r := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// сходство строк по триграммам (функция similarity из pg_trgm):
+// доля общих триграмм среди всех триграмм обеих строк, от 0 до 1
+func similarity(a, b string) float64 {
+	x, y := trigrams(a), trigrams(b)
+	if len(x) == 0 || len(y) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range x {
+		if y[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(x)+len(y)-common)
+}
+
+// порог сходства в виде литерала sql
+func similarityLiteral(threshold float64) string {
+	return strconv.FormatFloat(threshold, 'f', -1, 64)
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+)
+
+// умный плейлист: песни выбираются по дереву правил при каждом чтении
+type SmartPlaylist struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	Rules Rule   `json:"rules"`
+	Sort  string `json:"sort,omitempty"`
+	// максимальное количество песен, 0 - без ограничения
+	Limit int     `json:"limit,omitempty"`
+	Songs Library `json:"songs,omitempty"`
+}
+
+// параметры ListAllLibrary, выбирающие песни плейлиста
+func (p SmartPlaylist) Params() ListParams {
+	params := ListParams{Rule: &p.Rules, Sort: p.Sort}
+	if p.Limit > 0 {
+		params.Limit = strconv.Itoa(p.Limit)
+	}
+	return params
+}
+
+// список умных плейлистов по имени (без песен),
+// owner != "" - только плейлисты этого пользователя
+func (db *Database) ListSmartPlaylists(owner, offset, limit string) ([]SmartPlaylist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), `select smart_playlist_id, name, owner, rules, sort, song_limit
+from smart_playlists where ($1 = '' or owner = $1)
+order by name, smart_playlist_id offset $2 limit $3`, owner, offsetInt, nullLimit(limitInt))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := make([]SmartPlaylist, 0, 16)
+	for rows.Next() {
+		p, err := scanSmartPlaylist(rows)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+	return playlists, rows.Err()
+}
+
+// определение умного плейлиста, песни выбираются вызывающим через ListAllLibrary(p.Params())
+func (db *Database) GetSmartPlaylist(id int64) (SmartPlaylist, error) {
+	row := db.dbConn.QueryRow(context.Background(), `select smart_playlist_id, name, owner, rules, sort, song_limit
+from smart_playlists where smart_playlist_id=$1`, id)
+	p, err := scanSmartPlaylist(row)
+	if err != nil {
+		return SmartPlaylist{}, mapError(err)
+	}
+	return p, nil
+}
+
+func (db *Database) AddSmartPlaylist(p SmartPlaylist) (SmartPlaylist, error) {
+	rules, err := json.Marshal(p.Rules)
+	if err != nil {
+		return SmartPlaylist{}, err
+	}
+
+	err = db.dbConn.QueryRow(context.Background(), `insert into smart_playlists (name, owner, rules, sort, song_limit)
+values ($1, $2, $3, $4, $5) returning smart_playlist_id`, p.Name, p.Owner, string(rules), p.Sort, p.Limit).Scan(&p.ID)
+	if err != nil {
+		return SmartPlaylist{}, mapError(err)
+	}
+	return p, nil
+}
+
+// замена определения умного плейлиста
+func (db *Database) UpdateSmartPlaylist(id int64, p SmartPlaylist) error {
+	rules, err := json.Marshal(p.Rules)
+	if err != nil {
+		return err
+	}
+
+	tag, err := db.dbConn.Exec(context.Background(), `update smart_playlists
+set name=$1, owner=$2, rules=$3, sort=$4, song_limit=$5 where smart_playlist_id=$6`,
+		p.Name, p.Owner, string(rules), p.Sort, p.Limit, id)
+	slog.Debug("updating smart playlist", "db response", tag.String())
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (db *Database) DeleteSmartPlaylist(id int64) error {
+	tag, err := db.dbConn.Exec(context.Background(), `delete from smart_playlists where smart_playlist_id=$1`, id)
+	slog.Debug("deleting smart playlist", "db response", tag.String())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// строка результата запроса, общая для pgx и database/sql
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSmartPlaylist(row rowScanner) (SmartPlaylist, error) {
+	var p SmartPlaylist
+	var rules []byte
+	err := row.Scan(&p.ID, &p.Name, &p.Owner, &rules, &p.Sort, &p.Limit)
+	if err != nil {
+		return SmartPlaylist{}, err
+	}
+	return p, json.Unmarshal(rules, &p.Rules)
+}
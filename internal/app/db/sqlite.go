@@ -0,0 +1,380 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const sqliteMigrationsDir = "./internal/app/db/migrations_sqlite/"
+
+// в sqlite нет pg_trgm и unaccent, similarity и fold_name предоставляются функциями на go.
+// fold_name используется в индексах, поэтому открыть базу без этих функций
+// (например, консольным sqlite3) можно только для чтения
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("similarity", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		a, _ := args[0].(string)
+		b, _ := args[1].(string)
+		return similarity(a, b), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("fold_name", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, nil
+		}
+		return foldName(name), nil
+	})
+}
+
+// SQLiteDatabase - хранилище библиотеки во встроенной базе sqlite.
+// Предназначено для небольших установок, где отдельный сервер postgres не нужен
+type SQLiteDatabase struct {
+	config *Config
+	dbConn *sql.DB
+}
+
+func NewSQLite(config *Config) *SQLiteDatabase {
+	return &SQLiteDatabase{config: config}
+}
+
+// открывает файл базы данных (создаёт его при отсутствии) и применяет миграции
+func (db *SQLiteDatabase) Open() error {
+	dbConn, err := sql.Open("sqlite", db.config.SQLiteDSN())
+	if err != nil {
+		return err
+	}
+
+	err = dbConn.Ping()
+	if err != nil {
+		return err
+	}
+
+	db.dbConn = dbConn
+
+	err = db.fixDBVersion()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// устанавливает заданную версию базы данных
+func (db *SQLiteDatabase) fixDBVersion() error {
+	err := goose.SetDialect("sqlite3")
+	if err != nil {
+		return err
+	}
+	ver, err := goose.GetDBVersion(db.dbConn)
+	if err != nil {
+		return err
+	}
+	if ver < targetDBver {
+		err = goose.UpTo(db.dbConn, sqliteMigrationsDir, targetDBver)
+		if err != nil {
+			return err
+		}
+	}
+	if ver > targetDBver {
+		err = goose.DownTo(db.dbConn, sqliteMigrationsDir, targetDBver)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// выдает все песни, удовлетворяющие параметрам фильтрации (если они есть)
+func (db *SQLiteDatabase) ListAllLibrary(p ListParams) (Library, error) {
+	// в sqlite offset без limit недопустим, limit -1 означает "без ограничения"
+	offsetInt, limitInt, err := parsePage(p.Offset, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	w := songsWhere(p, sqliteDialect)
+	columns, dest := songsProjection(p.Fields, sqliteDialect)
+	q := `select ` + columns + `
+from songs inner join groups using (author_id) where ` + w.String() + `
+order by ` + songsListOrder(p, w) + ` limit ` + w.arg(limitInt) + ` offset ` + w.arg(offsetInt)
+
+	slog.Debug("list all library database query", "params", p)
+
+	rows, err := db.dbConn.QueryContext(context.Background(), q, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sTmp := Song{}
+	lib := make(Library, 0, 64)
+
+	for rows.Next() {
+		err = rows.Scan(dest(&sTmp)...)
+		if err != nil {
+			return nil, err
+		}
+		lib = append(lib, sTmp)
+	}
+	if p.Cursor != nil && p.Cursor.Before {
+		slices.Reverse(lib)
+	}
+	return lib, rows.Err()
+}
+
+// количество песен, удовлетворяющих параметрам фильтрации, без учёта страницы
+func (db *SQLiteDatabase) CountLibrary(p ListParams) (int, error) {
+	p.Cursor = nil
+
+	w := songsWhere(p, sqliteDialect)
+	var count int
+	err := db.dbConn.QueryRowContext(context.Background(),
+		`select count(*) from songs inner join groups using (author_id) where `+w.String(), w.args...).Scan(&count)
+	return count, err
+}
+
+// удаление определенной песни в корзину от имени by (см. trash.go)
+// возвращает количество удалённых песен
+func (db *SQLiteDatabase) DeleteSong(author_name, songName, by string) (int64, error) {
+	res, err := db.dbConn.ExecContext(context.Background(), `update songs set deleted_at=$3, deleted_by=$4
+where song_name=$1 and author_id=`+authorByName("$2")+` and deleted_at is null`, songName, author_name, time.Now().UTC(), by)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// удаление песни в корзину по её id, если её версия - version (0 - любая)
+func (db *SQLiteDatabase) DeleteSongByID(id, version int64, by string) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `update songs set deleted_at=$3, deleted_by=$4
+where song_id=$1 and ($2 = 0 or version=$2) and deleted_at is null`, id, version, time.Now().UTC(), by)
+	if err != nil {
+		return err
+	}
+	return db.expectSongChanged(res, id)
+}
+
+// добавление песни в базу данных
+func (db *SQLiteDatabase) AddSong(s Song) error {
+	date, err := normalizeDate(s.ReleaseDate)
+	if err != nil {
+		return err
+	}
+
+	// исполнитель добавляется в одной транзакции с песней (см. Database.AddSong)
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := getSQLiteAuthorID(ctx, tx, s.Group)
+	if err != nil {
+		return err
+	}
+	var trashed int64
+	err = tx.QueryRowContext(ctx, `select song_id from songs where author_id=$1 and song_name=$2 and deleted_at is not null`,
+		id, s.SongName).Scan(&trashed)
+	if err == nil {
+		return &TrashedSongError{ID: trashed}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into songs (author_id, song_name, release_date, song_text, link)
+values ($1, $2, nullif($3, ''), $4, $5)`, id, s.SongName, date, s.Text, s.Link)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	return tx.Commit()
+}
+
+// обновление имени исполнителя в бд (см. Database.UpdateGroupName)
+func (db *SQLiteDatabase) UpdateGroupName(author_name string, s Song, by string) error {
+	return db.renameArtist(`select author_id, author_name from groups
+where author_id=`+authorByName("$1"), author_name, s.Group, by)
+}
+
+// обновление данных песни (см. Database.UpdateSongDetails)
+// поля со значением "no_data" не изменяются
+func (db *SQLiteDatabase) UpdateSongDetails(author_name, song_name string, s Song, by string) error {
+	var id int64
+	err := db.dbConn.QueryRowContext(context.Background(), `select songs.song_id from songs where songs.author_id=`+authorByName("$1")+`
+and songs.song_name=$2 and songs.deleted_at is null`, author_name, song_name).Scan(&id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	return db.UpdateSongByID(id, s, by)
+}
+
+// обновление данных песни по её id, правила те же, что и в UpdateSongDetails,
+// изменения записываются в историю песни (см. Database.UpdateSongByID)
+func (db *SQLiteDatabase) UpdateSongByID(id int64, s Song, by string) error {
+	var set setClause
+
+	if s.SongName != "no_data" {
+		set.add("song_name", s.SongName)
+	}
+	if s.ReleaseDate != "no_data" {
+		date, err := normalizeDate(s.ReleaseDate)
+		if err != nil {
+			return err
+		}
+		set.addf("release_date", "nullif(%s, '')", date)
+	}
+	if s.Text != "no_data" {
+		set.add("song_text", s.Text)
+	}
+	if s.Link != "no_data" {
+		set.add("link", s.Link)
+	}
+
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := getSQLiteSong(ctx, tx, id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	err = checkVersion(old, s.Version)
+	if err != nil {
+		return err
+	}
+	// исполнитель добавляется только после проверки версии (см. Database.UpdateSongByID)
+	if s.Group != "no_data" {
+		authorID, err := getSQLiteAuthorID(ctx, tx, s.Group)
+		if err != nil {
+			return err
+		}
+		set.add("author_id", authorID)
+	}
+	// обновлять нечего, об отсутствии песни (или другой её версии) уже сообщено
+	if set.empty() {
+		return nil
+	}
+
+	// блокировки строк в sqlite нет, поэтому версия проверяется и при обновлении:
+	// прежние значения полей в истории должны соответствовать изменённой версии
+	query := fmt.Sprintf(`update songs set %s, version=version+1 where song_id=%s and version=%s and deleted_at is null`,
+		&set, set.arg(id), set.arg(old.Version))
+	res, err := tx.ExecContext(ctx, query, set.args...)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: song %d", ErrVersionMismatch, id)
+	}
+	updated, err := getSQLiteSong(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, c := range SongChanges(old, updated) {
+		_, err = tx.ExecContext(ctx, `insert into song_history (song_id, version, changed_by, changed_at, field, old_value, new_value)
+values ($1, $2, $3, $4, $5, $6, $7)`, id, updated.Version, by, now, c.Field, c.Old, c.New)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// получение песни по её id
+func (db *SQLiteDatabase) GetSong(id int64) (Song, error) {
+	s, err := getSQLiteSong(context.Background(), db.dbConn, id)
+	if err != nil {
+		return Song{}, mapSQLiteError(err)
+	}
+	return s, nil
+}
+
+// песня не из корзины по её id из conn (базы данных или транзакции)
+func getSQLiteSong(ctx context.Context, conn interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}, id int64) (Song, error) {
+	var s Song
+	err := conn.QueryRowContext(ctx, `select songs.song_id, groups.author_name, songs.song_name,
+coalesce(songs.release_date, ''), coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version
+from songs inner join groups using (author_id) where songs.song_id=$1 and songs.deleted_at is null`, id).
+		Scan(&s.ID, &s.Group, &s.SongName, &s.ReleaseDate, &s.Text, &s.Link, &s.Version)
+	return s, err
+}
+
+// возвращает id исполнителя, добавляя его при отсутствии, в транзакции tx
+func getSQLiteAuthorID(ctx context.Context, tx *sql.Tx, author_name string) (int, error) {
+	var id sql.NullInt64
+	err := tx.QueryRowContext(ctx, `select `+authorByName("$1"), author_name).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if id.Valid {
+		return int(id.Int64), nil
+	}
+
+	var newID int
+	err = tx.QueryRowContext(ctx, `insert into groups (author_name) values ($1) returning author_id`, author_name).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+	return newID, nil
+}
+
+// ErrNotFound, если запрос не затронул ни одной строки
+func expectAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// как expectAffected, но для изменения песни id с проверкой версии (см. songChangeError)
+func (db *SQLiteDatabase) expectSongChanged(res sql.Result, id int64) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return songChangeError(db.GetSong, id)
+	}
+	return nil
+}
+
+// приводит ошибки sqlite к ошибкам пакета, не зависящим от реализации хранилища
+func mapSQLiteError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %s", ErrAlreadyExists, sqliteErr.Error())
+		}
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// список альбомов, при artistID != 0 - только альбомы этого исполнителя
+func (db *SQLiteDatabase) ListAlbums(artistID int, offset, limit string) ([]Album, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select albums.album_id, albums.title, albums.author_id,
+groups.author_name, coalesce(albums.release_date, ''), albums.album_type
+from albums inner join groups using (author_id)
+where ($1 = 0 or albums.author_id = $1)
+order by albums.title, albums.album_id limit $2 offset $3`, artistID, limitInt, offsetInt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	albums := make([]Album, 0, 16)
+	for rows.Next() {
+		var a Album
+		err = rows.Scan(&a.ID, &a.Title, &a.ArtistID, &a.Artist, &a.ReleaseDate, &a.Type)
+		if err != nil {
+			return nil, err
+		}
+		albums = append(albums, a)
+	}
+	return albums, rows.Err()
+}
+
+// альбом вместе с треками, упорядоченными по диску и позиции
+func (db *SQLiteDatabase) GetAlbum(id int64) (Album, error) {
+	var a Album
+	err := db.dbConn.QueryRowContext(context.Background(), `select albums.album_id, albums.title, albums.author_id,
+groups.author_name, coalesce(albums.release_date, ''), albums.album_type
+from albums inner join groups using (author_id) where albums.album_id=$1`, id).
+		Scan(&a.ID, &a.Title, &a.ArtistID, &a.Artist, &a.ReleaseDate, &a.Type)
+	if err != nil {
+		return Album{}, mapSQLiteError(err)
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select album_tracks.disc, album_tracks.position,
+songs.song_id, groups.author_name, songs.song_name, coalesce(songs.release_date, ''),
+coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version
+from album_tracks inner join songs using (song_id) inner join groups using (author_id)
+where album_tracks.album_id=$1 and songs.deleted_at is null order by album_tracks.disc, album_tracks.position`, id)
+	if err != nil {
+		return Album{}, err
+	}
+	defer rows.Close()
+
+	a.Tracks = make([]Track, 0, 16)
+	for rows.Next() {
+		var t Track
+		err = rows.Scan(&t.Disc, &t.Position, &t.Song.ID, &t.Song.Group, &t.Song.SongName,
+			&t.Song.ReleaseDate, &t.Song.Text, &t.Song.Link, &t.Song.Version)
+		if err != nil {
+			return Album{}, err
+		}
+		a.Tracks = append(a.Tracks, t)
+	}
+	return a, rows.Err()
+}
+
+// добавление альбома без треков, исполнитель должен существовать
+func (db *SQLiteDatabase) AddAlbum(a Album) (Album, error) {
+	date, err := normalizeDate(a.ReleaseDate)
+	if err != nil {
+		return Album{}, err
+	}
+
+	err = db.dbConn.QueryRowContext(context.Background(), `insert into albums (title, author_id, release_date, album_type)
+select $1, author_id, nullif($3, ''), $4 from groups where author_id=$2 returning album_id`,
+		a.Title, a.ArtistID, date, a.Type).Scan(&a.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Album{}, fmt.Errorf("%w: artist %d", ErrNotFound, a.ArtistID)
+		}
+		return Album{}, mapSQLiteError(err)
+	}
+	return db.GetAlbum(a.ID)
+}
+
+// замена данных альбома (название, исполнитель, дата, тип), треки не меняются
+func (db *SQLiteDatabase) UpdateAlbum(id int64, a Album) error {
+	date, err := normalizeDate(a.ReleaseDate)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var exists bool
+	err = db.dbConn.QueryRowContext(ctx, `select exists(select 1 from groups where author_id=$1)`, a.ArtistID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: artist %d", ErrNotFound, a.ArtistID)
+	}
+
+	res, err := db.dbConn.ExecContext(ctx, `update albums set title=$1, author_id=$2, release_date=nullif($3, ''), album_type=$4
+where album_id=$5`, a.Title, a.ArtistID, date, a.Type, id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	return expectAffected(res)
+}
+
+// удаление альбома, песни альбома остаются в библиотеке
+func (db *SQLiteDatabase) DeleteAlbum(id int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from albums where album_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// добавляет или перемещает песню в альбоме (см. Database.SetTrack)
+func (db *SQLiteDatabase) SetTrack(albumID, songID int64, disc, position int) error {
+	ctx := context.Background()
+	var albumExists, songExists bool
+	err := db.dbConn.QueryRowContext(ctx, `select exists(select 1 from albums where album_id=$1),
+exists(select 1 from songs where song_id=$2 and deleted_at is null)`, albumID, songID).Scan(&albumExists, &songExists)
+	if err != nil {
+		return err
+	}
+	if !albumExists {
+		return fmt.Errorf("%w: album %d", ErrNotFound, albumID)
+	}
+	if !songExists {
+		return fmt.Errorf("%w: song %d", ErrNotFound, songID)
+	}
+
+	_, err = db.dbConn.ExecContext(ctx, `insert into album_tracks (album_id, song_id, disc, position) values ($1, $2, $3, $4)
+on conflict (album_id, song_id) do update set disc=excluded.disc, position=excluded.position`, albumID, songID, disc, position)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	return nil
+}
+
+// удаление песни из альбома, сама песня остаётся в библиотеке
+func (db *SQLiteDatabase) RemoveTrack(albumID, songID int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from album_tracks where album_id=$1 and song_id=$2`, albumID, songID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
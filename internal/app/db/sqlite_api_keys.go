@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// все ключи, включая отозванные, в порядке создания
+func (db *SQLiteDatabase) ListAPIKeys() ([]APIKey, error) {
+	rows, err := db.dbConn.QueryContext(context.Background(), `select key_id, name, role, created_at, revoked_at
+from api_keys order by key_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0, 16)
+	for rows.Next() {
+		var k APIKey
+		err = rows.Scan(&k.ID, &k.Name, &k.Role, &k.CreatedAt, &k.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// сохраняет ключ с хешем hash (см. NewAPIKeySecret)
+func (db *SQLiteDatabase) AddAPIKey(k APIKey, hash string) (APIKey, error) {
+	k.CreatedAt = time.Now().UTC()
+	err := db.dbConn.QueryRowContext(context.Background(), `insert into api_keys (name, key_hash, role, created_at)
+values ($1, $2, $3, $4) returning key_id`, k.Name, hash, k.Role, k.CreatedAt).Scan(&k.ID)
+	if err != nil {
+		return APIKey{}, mapSQLiteError(err)
+	}
+	return k, nil
+}
+
+// действующий (не отозванный) ключ по его хешу
+func (db *SQLiteDatabase) FindAPIKey(hash string) (APIKey, error) {
+	var k APIKey
+	err := db.dbConn.QueryRowContext(context.Background(), `select key_id, name, role, created_at from api_keys
+where key_hash=$1 and revoked_at is null`, hash).Scan(&k.ID, &k.Name, &k.Role, &k.CreatedAt)
+	if err != nil {
+		return APIKey{}, mapSQLiteError(err)
+	}
+	return k, nil
+}
+
+// отзыв ключа, отозванный ключ остаётся в списке
+func (db *SQLiteDatabase) RevokeAPIKey(id int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `update api_keys set revoked_at=$1
+where key_id=$2 and revoked_at is null`, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// добавляет токен в список отозванных (см. Database.RevokeToken)
+func (db *SQLiteDatabase) RevokeToken(jti string, expires time.Time) error {
+	ctx := context.Background()
+	_, err := db.dbConn.ExecContext(ctx, `delete from revoked_tokens where expires_at < $1`, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	_, err = db.dbConn.ExecContext(ctx, `insert into revoked_tokens (jti, expires_at) values ($1, $2)
+on conflict do nothing`, jti, expires.UTC())
+	return err
+}
+
+// отозван ли токен или ключ, по которому он выдан (см. Database.TokenRevoked)
+func (db *SQLiteDatabase) TokenRevoked(jti string, keyID int64) (bool, error) {
+	var revoked bool
+	err := db.dbConn.QueryRowContext(context.Background(), `select exists(select 1 from revoked_tokens where jti=$1)
+or not exists(select 1 from api_keys where key_id=$2 and revoked_at is null)`, jti, keyID).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,290 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// список исполнителей, отсортированный по имени, с количеством песен у каждого
+func (db *SQLiteDatabase) ListArtists(offset, limit string) ([]Artist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select groups.author_id, groups.author_name, count(songs.song_id)
+from groups left join songs on songs.author_id = groups.author_id and songs.deleted_at is null
+group by groups.author_id, groups.author_name
+order by groups.author_name limit $1 offset $2`, limitInt, offsetInt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := make([]Artist, 0, 64)
+	for rows.Next() {
+		var a Artist
+		err = rows.Scan(&a.ID, &a.Name, &a.SongCount)
+		if err != nil {
+			return nil, err
+		}
+		artists = append(artists, a)
+	}
+	return artists, rows.Err()
+}
+
+// исполнитель без песен по имени (см. Database.FindArtist)
+func (db *SQLiteDatabase) FindArtist(name string) (Artist, error) {
+	var a Artist
+	err := db.dbConn.QueryRowContext(context.Background(), `select author_id, author_name from groups
+where author_id=`+authorByName("$1"), name).Scan(&a.ID, &a.Name)
+	if err != nil {
+		return Artist{}, mapSQLiteError(err)
+	}
+	return a, nil
+}
+
+// исполнитель вместе со всеми его песнями
+func (db *SQLiteDatabase) GetArtist(id int) (Artist, error) {
+	a := Artist{ID: id}
+	err := db.dbConn.QueryRowContext(context.Background(), `select author_name from groups where author_id=$1`, id).Scan(&a.Name)
+	if err != nil {
+		return Artist{}, mapSQLiteError(err)
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select songs.song_id, songs.song_name,
+coalesce(songs.release_date, ''), coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version
+from songs where author_id=$1 and deleted_at is null order by songs.song_name`, id)
+	if err != nil {
+		return Artist{}, err
+	}
+	defer rows.Close()
+
+	a.Songs = make(Library, 0, 16)
+	for rows.Next() {
+		s := Song{Group: a.Name}
+		err = rows.Scan(&s.ID, &s.SongName, &s.ReleaseDate, &s.Text, &s.Link, &s.Version)
+		if err != nil {
+			return Artist{}, err
+		}
+		a.Songs = append(a.Songs, s)
+	}
+	a.SongCount = len(a.Songs)
+	return a, rows.Err()
+}
+
+// добавление исполнителя без песен
+func (db *SQLiteDatabase) AddArtist(name string) (Artist, error) {
+	a := Artist{Name: name}
+	err := db.dbConn.QueryRowContext(context.Background(), `insert into groups (author_name) values ($1) returning author_id`, name).Scan(&a.ID)
+	if err != nil {
+		return Artist{}, mapSQLiteError(err)
+	}
+	return a, nil
+}
+
+// переименование исполнителя по его id
+func (db *SQLiteDatabase) RenameArtist(id int, name, by string) error {
+	return db.renameArtist(`select author_id, author_name from groups where author_id=$1`, id, name, by)
+}
+
+// переименование исполнителя с записью в историю и увеличением версий
+// его песен (см. Database.renameArtist)
+func (db *SQLiteDatabase) renameArtist(find string, key any, name, by string) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var old string
+	err = tx.QueryRowContext(ctx, find, key).Scan(&id, &old)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	_, err = tx.ExecContext(ctx, `update groups set author_name=$1 where author_id=$2`, name, id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	if old != name {
+		_, err = tx.ExecContext(ctx, `insert into song_history (song_id, version, changed_by, changed_at, field, old_value, new_value)
+select song_id, version + 1, $2, $3, 'group', $4, $5 from songs where author_id=$1`, id, by, time.Now().UTC(), old, name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `update songs set version=version+1 where author_id=$1`, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `insert into artist_history (author_id, version, changed_by, changed_at, field, old_value, new_value)
+select $1, coalesce(max(version), 1) + 1, $2, $3, 'name', $4, $5 from artist_history where author_id=$1`,
+			id, by, time.Now().UTC(), old, name)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// удаление исполнителя (см. Database.DeleteArtist)
+func (db *SQLiteDatabase) DeleteArtist(id int, cascade bool, by string) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var songs, trashed int
+	err = tx.QueryRowContext(ctx, `select count(*), count(deleted_at) from songs where author_id=$1`, id).Scan(&songs, &trashed)
+	if err != nil {
+		return err
+	}
+	if songs > 0 {
+		if !cascade {
+			return artistSongsError(songs-trashed, trashed)
+		}
+		_, err = tx.ExecContext(ctx, `update songs set deleted_at=$2, deleted_by=$3
+where author_id=$1 and deleted_at is null`, id, time.Now().UTC(), by)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, detachTrashed+`songs.author_id=$1`, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `delete from groups where author_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	err = expectAffected(res)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// объединяет исполнителей sources с исполнителем target (см. Database.MergeArtists)
+func (db *SQLiteDatabase) MergeArtists(target int, sources []int, policy MergePolicy, by string) (MergeResult, error) {
+	ctx := context.Background()
+	result := MergeResult{Dropped: make([]int64, 0)}
+
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	var targetName string
+	err = tx.QueryRowContext(ctx, `select author_name from groups where author_id=$1`, target).Scan(&targetName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return result, fmt.Errorf("%w: artist %d", ErrNotFound, target)
+		}
+		return result, err
+	}
+
+	for _, source := range sources {
+		if source == target {
+			continue
+		}
+
+		var name string
+		err = tx.QueryRowContext(ctx, `select author_name from groups where author_id=$1`, source).Scan(&name)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return result, fmt.Errorf("%w: artist %d", ErrNotFound, source)
+			}
+			return result, err
+		}
+
+		// пары одноимённых песен не из корзины: (песня target, песня source)
+		rows, err := tx.QueryContext(ctx, `select t.song_id, s.song_id from songs s
+inner join songs t on t.song_name = s.song_name and t.author_id = $1
+where s.author_id = $2 and s.deleted_at is null and t.deleted_at is null`, target, source)
+		if err != nil {
+			return result, err
+		}
+		drop := make([]int64, 0)
+		for rows.Next() {
+			var targetSong, sourceSong int64
+			err = rows.Scan(&targetSong, &sourceSong)
+			if err != nil {
+				rows.Close()
+				return result, err
+			}
+			song, err := policy.drop(targetSong, sourceSong)
+			if err != nil {
+				rows.Close()
+				return result, err
+			}
+			drop = append(drop, song)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return result, err
+		}
+
+		for _, song := range drop {
+			_, err = tx.ExecContext(ctx, `update songs set deleted_at=$2, deleted_by=$3 where song_id=$1`,
+				song, time.Now().UTC(), by)
+			if err != nil {
+				return result, err
+			}
+		}
+		result.Dropped = append(result.Dropped, drop...)
+		_, err = tx.ExecContext(ctx, detachTrashed+`(songs.author_id=$2 or songs.author_id=$1 and songs.song_name in
+(select song_name from songs where author_id=$2 and deleted_at is null))`, target, source)
+		if err != nil {
+			return result, err
+		}
+
+		_, err = tx.ExecContext(ctx, `insert into song_history (song_id, version, changed_by, changed_at, field, old_value, new_value)
+select song_id, version + 1, $2, $3, 'group', $4, $5 from songs where author_id=$1`, source, by, time.Now().UTC(), name, targetName)
+		if err != nil {
+			return result, err
+		}
+		res, err := tx.ExecContext(ctx, `update songs set author_id=$1, version=version+1 where author_id=$2`, target, source)
+		if err != nil {
+			return result, err
+		}
+		moved, err := res.RowsAffected()
+		if err != nil {
+			return result, err
+		}
+		result.Moved += int(moved)
+
+		_, err = tx.ExecContext(ctx, `update artist_aliases set author_id=$1 where author_id=$2`, target, source)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.ExecContext(ctx, `update albums set author_id=$1 where author_id=$2`, target, source)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.ExecContext(ctx, `delete from groups where author_id=$1`, source)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.ExecContext(ctx, `insert into artist_aliases (alias, author_id) values ($1, $2)
+on conflict (alias) do update set author_id=excluded.author_id`, name, target)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return result, err
+	}
+
+	result.Target, err = db.GetArtist(target)
+	return result, err
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// история изменений песни, от новых ревизий к старым
+func (db *SQLiteDatabase) SongRevisions(id int64) ([]Revision, error) {
+	return db.revisions(`select version, changed_by, changed_at, field, old_value, new_value
+from song_history where song_id=$1 order by version desc, history_id`, id)
+}
+
+// история изменений исполнителя, от новых ревизий к старым
+func (db *SQLiteDatabase) ArtistRevisions(id int) ([]Revision, error) {
+	return db.revisions(`select version, changed_by, changed_at, field, old_value, new_value
+from artist_history where author_id=$1 order by version desc, history_id`, id)
+}
+
+func (db *SQLiteDatabase) revisions(query string, id any) ([]Revision, error) {
+	rows, err := db.dbConn.QueryContext(context.Background(), query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revs := make([]Revision, 0)
+	for rows.Next() {
+		var version int64
+		var by string
+		var at time.Time
+		var c Change
+		err = rows.Scan(&version, &by, &at, &c.Field, &c.Old, &c.New)
+		if err != nil {
+			return nil, err
+		}
+		revs = appendChange(revs, version, by, at, c)
+	}
+	return revs, rows.Err()
+}
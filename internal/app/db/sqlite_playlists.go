@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// список плейлистов по имени, owner != "" - только плейлисты этого пользователя
+func (db *SQLiteDatabase) ListPlaylists(owner, offset, limit string) ([]Playlist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select playlists.playlist_id, playlists.name, playlists.owner,
+count(playlist_entries.entry_id)
+from playlists left join playlist_entries using (playlist_id)
+where ($1 = '' or playlists.owner = $1)
+group by playlists.playlist_id
+order by playlists.name, playlists.playlist_id limit $2 offset $3`, owner, limitInt, offsetInt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := make([]Playlist, 0, 16)
+	for rows.Next() {
+		var p Playlist
+		err = rows.Scan(&p.ID, &p.Name, &p.Owner, &p.SongCount)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+	return playlists, rows.Err()
+}
+
+// плейлист вместе с песнями в порядке их позиций
+func (db *SQLiteDatabase) GetPlaylist(id int64) (Playlist, error) {
+	var p Playlist
+	err := db.dbConn.QueryRowContext(context.Background(), `select playlist_id, name, owner from playlists where playlist_id=$1`, id).
+		Scan(&p.ID, &p.Name, &p.Owner)
+	if err != nil {
+		return Playlist{}, mapSQLiteError(err)
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select playlist_entries.entry_id,
+songs.song_id, groups.author_name, songs.song_name, coalesce(songs.release_date, ''),
+coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version
+from playlist_entries inner join songs using (song_id) inner join groups using (author_id)
+where playlist_entries.playlist_id=$1 and songs.deleted_at is null order by playlist_entries.position, playlist_entries.entry_id`, id)
+	if err != nil {
+		return Playlist{}, err
+	}
+	defer rows.Close()
+
+	p.Entries = make([]PlaylistEntry, 0, 16)
+	for rows.Next() {
+		e := PlaylistEntry{Position: len(p.Entries) + 1}
+		err = rows.Scan(&e.ID, &e.Song.ID, &e.Song.Group, &e.Song.SongName, &e.Song.ReleaseDate, &e.Song.Text, &e.Song.Link, &e.Song.Version)
+		if err != nil {
+			return Playlist{}, err
+		}
+		p.Entries = append(p.Entries, e)
+	}
+	p.SongCount = len(p.Entries)
+	return p, rows.Err()
+}
+
+// добавление пустого плейлиста
+func (db *SQLiteDatabase) AddPlaylist(p Playlist) (Playlist, error) {
+	err := db.dbConn.QueryRowContext(context.Background(), `insert into playlists (name, owner) values ($1, $2) returning playlist_id`,
+		p.Name, p.Owner).Scan(&p.ID)
+	if err != nil {
+		return Playlist{}, mapSQLiteError(err)
+	}
+	return p, nil
+}
+
+// удаление плейлиста, песни остаются в библиотеке
+func (db *SQLiteDatabase) DeletePlaylist(id int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from playlists where playlist_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// добавляет песню в конец плейлиста, возвращает id новой записи
+func (db *SQLiteDatabase) AppendToPlaylist(id, songID int64) (int64, error) {
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `select playlist_id from playlists where playlist_id=$1`, id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: playlist %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var entryID int64
+	err = tx.QueryRowContext(ctx, `insert into playlist_entries (playlist_id, song_id, position)
+select $1, song_id, (select coalesce(max(position), 0) + 1 from playlist_entries where playlist_id=$1)
+from songs where song_id=$2 and deleted_at is null returning entry_id`, id, songID).Scan(&entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: song %d", ErrNotFound, songID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return entryID, tx.Commit()
+}
+
+// перемещает запись плейлиста на позицию position (с 1)
+func (db *SQLiteDatabase) MovePlaylistEntry(id, entryID int64, position int) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `select playlist_id from playlists where playlist_id=$1`, id).Scan(&id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `select entry_id from playlist_entries where playlist_id=$1
+order by position, entry_id`, id)
+	if err != nil {
+		return err
+	}
+	entries := make([]int64, 0, 16)
+	for rows.Next() {
+		var entry int64
+		err = rows.Scan(&entry)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	entries, err = moveEntry(entries, entryID, position)
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		_, err = tx.ExecContext(ctx, `update playlist_entries set position=$1 where entry_id=$2`, i+1, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// удаление записи из плейлиста, сама песня остаётся в библиотеке
+func (db *SQLiteDatabase) RemovePlaylistEntry(id, entryID int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from playlist_entries where playlist_id=$1 and entry_id=$2`, id, entryID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
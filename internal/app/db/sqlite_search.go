@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+)
+
+// поиск песен по тексту через полнотекстовый индекс songs_fts (fts5),
+// релевантность - bm25 с обратным знаком. Куплет и выделение слов
+// вычисляются так же, как в хранилище в памяти
+func (db *SQLiteDatabase) SearchLyrics(q SearchQuery, p ListParams) ([]SearchHit, error) {
+	offsetInt, limitInt, err := parsePage(p.Offset, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	w := songsWhere(p, sqliteDialect)
+	query := `select songs.song_id, groups.author_name, songs.song_name, coalesce(songs.release_date, ''),
+coalesce(songs.song_text, ''), coalesce(songs.link, ''), -bm25(songs_fts) as rank
+from songs_fts inner join songs on songs.song_id = songs_fts.rowid inner join groups using (author_id)
+where songs_fts match ` + w.arg(q.fts5()) + ` and ` + w.String() + `
+order by rank desc, groups.author_name, songs.song_name limit ` + w.arg(limitInt) + ` offset ` + w.arg(offsetInt)
+
+	slog.Debug("search lyrics database query", "query", q.fts5(), "params", p)
+
+	rows, err := db.dbConn.QueryContext(context.Background(), query, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := make([]SearchHit, 0, 16)
+	for rows.Next() {
+		var s Song
+		var rank float64
+		err = rows.Scan(&s.ID, &s.Group, &s.SongName, &s.ReleaseDate, &s.Text, &s.Link, &rank)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, q.hit(s, rank))
+	}
+	return hits, rows.Err()
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// список умных плейлистов по имени (без песен),
+// owner != "" - только плейлисты этого пользователя
+func (db *SQLiteDatabase) ListSmartPlaylists(owner, offset, limit string) ([]SmartPlaylist, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select smart_playlist_id, name, owner, rules, sort, song_limit
+from smart_playlists where ($1 = '' or owner = $1)
+order by name, smart_playlist_id limit $2 offset $3`, owner, limitInt, offsetInt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := make([]SmartPlaylist, 0, 16)
+	for rows.Next() {
+		p, err := scanSmartPlaylist(rows)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+	return playlists, rows.Err()
+}
+
+// определение умного плейлиста (см. Database.GetSmartPlaylist)
+func (db *SQLiteDatabase) GetSmartPlaylist(id int64) (SmartPlaylist, error) {
+	row := db.dbConn.QueryRowContext(context.Background(), `select smart_playlist_id, name, owner, rules, sort, song_limit
+from smart_playlists where smart_playlist_id=$1`, id)
+	p, err := scanSmartPlaylist(row)
+	if err != nil {
+		return SmartPlaylist{}, mapSQLiteError(err)
+	}
+	return p, nil
+}
+
+func (db *SQLiteDatabase) AddSmartPlaylist(p SmartPlaylist) (SmartPlaylist, error) {
+	rules, err := json.Marshal(p.Rules)
+	if err != nil {
+		return SmartPlaylist{}, err
+	}
+
+	err = db.dbConn.QueryRowContext(context.Background(), `insert into smart_playlists (name, owner, rules, sort, song_limit)
+values ($1, $2, $3, $4, $5) returning smart_playlist_id`, p.Name, p.Owner, string(rules), p.Sort, p.Limit).Scan(&p.ID)
+	if err != nil {
+		return SmartPlaylist{}, mapSQLiteError(err)
+	}
+	return p, nil
+}
+
+// замена определения умного плейлиста
+func (db *SQLiteDatabase) UpdateSmartPlaylist(id int64, p SmartPlaylist) error {
+	rules, err := json.Marshal(p.Rules)
+	if err != nil {
+		return err
+	}
+
+	res, err := db.dbConn.ExecContext(context.Background(), `update smart_playlists
+set name=$1, owner=$2, rules=$3, sort=$4, song_limit=$5 where smart_playlist_id=$6`,
+		p.Name, p.Owner, string(rules), p.Sort, p.Limit, id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	return expectAffected(res)
+}
+
+func (db *SQLiteDatabase) DeleteSmartPlaylist(id int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from smart_playlists where smart_playlist_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// подсказки по началу имени, см. Database.Suggest. fold_name - функция на go,
+// префикс ищется диапазоном, чтобы использовался индекс по fold_name(имя)
+func (db *SQLiteDatabase) Suggest(kind, prefix string, limit int) ([]Suggestion, error) {
+	var query string
+	switch kind {
+	case SuggestArtist:
+		query = `select author_id, author_name, '' from groups
+where fold_name(author_name) >= $1 and fold_name(author_name) < $1 || char(1114111)
+order by fold_name(author_name), author_name limit $2`
+	case SuggestSong:
+		query = `select songs.song_id, songs.song_name, groups.author_name from songs inner join groups using (author_id)
+where songs.deleted_at is null and fold_name(songs.song_name) >= $1 and fold_name(songs.song_name) < $1 || char(1114111)
+order by fold_name(songs.song_name), songs.song_name, groups.author_name limit $2`
+	default:
+		return nil, fmt.Errorf("unknown suggestion kind %q", kind)
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), query, foldName(prefix), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := make([]Suggestion, 0, limit)
+	for rows.Next() {
+		var s Suggestion
+		err = rows.Scan(&s.ID, &s.Name, &s.Group)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// список тегов, отсортированный по имени, kind != "" - только теги этого вида
+func (db *SQLiteDatabase) ListTags(kind string) ([]Tag, error) {
+	rows, err := db.dbConn.QueryContext(context.Background(), `select tag_id, name, kind, parent_id from tags
+where ($1 = '' or kind = $1) order by name`, kind)
+	if err != nil {
+		return nil, err
+	}
+	return collectSQLiteTags(rows)
+}
+
+// тег вместе с его непосредственными поджанрами
+func (db *SQLiteDatabase) GetTag(id int64) (Tag, error) {
+	var t Tag
+	err := db.dbConn.QueryRowContext(context.Background(), `select tag_id, name, kind, parent_id from tags where tag_id=$1`, id).
+		Scan(&t.ID, &t.Name, &t.Kind, &t.ParentID)
+	if err != nil {
+		return Tag{}, mapSQLiteError(err)
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select tag_id, name, kind, parent_id from tags
+where parent_id=$1 order by name`, id)
+	if err != nil {
+		return Tag{}, err
+	}
+	t.Children, err = collectSQLiteTags(rows)
+	return t, err
+}
+
+func (db *SQLiteDatabase) AddTag(t Tag) (Tag, error) {
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return Tag{}, err
+	}
+	defer tx.Rollback()
+
+	err = checkTagParent(0, t, sqliteTagLookup(ctx, tx))
+	if err != nil {
+		return Tag{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, `insert into tags (name, kind, parent_id) values ($1, $2, $3) returning tag_id`,
+		t.Name, t.Kind, t.ParentID).Scan(&t.ID)
+	if err != nil {
+		return Tag{}, mapSQLiteError(err)
+	}
+	return t, tx.Commit()
+}
+
+// замена имени, вида и родителя тега (см. Database.UpdateTag)
+func (db *SQLiteDatabase) UpdateTag(id int64, t Tag) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `select tag_id from tags where tag_id=$1`, id).Scan(&id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	err = checkTagParent(id, t, sqliteTagLookup(ctx, tx))
+	if err != nil {
+		return err
+	}
+	if t.Kind != TagKindGenre {
+		var hasChildren bool
+		err = tx.QueryRowContext(ctx, `select exists(select 1 from tags where parent_id=$1)`, id).Scan(&hasChildren)
+		if err != nil {
+			return err
+		}
+		if hasChildren {
+			return fmt.Errorf("%w: genre has subgenres", ErrBadTagParent)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `update tags set name=$1, kind=$2, parent_id=$3 where tag_id=$4`, t.Name, t.Kind, t.ParentID, id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	return tx.Commit()
+}
+
+// удаление тега, его поджанры становятся жанрами верхнего уровня
+func (db *SQLiteDatabase) DeleteTag(id int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from tags where tag_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// теги песни, отсортированные по имени
+func (db *SQLiteDatabase) SongTags(songID int64) ([]Tag, error) {
+	var exists bool
+	err := db.dbConn.QueryRowContext(context.Background(), `select exists(select 1 from songs where song_id=$1 and deleted_at is null)`, songID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select tags.tag_id, tags.name, tags.kind, tags.parent_id
+from song_tags inner join tags using (tag_id) where song_tags.song_id=$1 order by tags.name`, songID)
+	if err != nil {
+		return nil, err
+	}
+	return collectSQLiteTags(rows)
+}
+
+// отмечает песню тегом, повторная отметка ничего не меняет
+func (db *SQLiteDatabase) AttachTag(songID, tagID int64) error {
+	ctx := context.Background()
+	var songExists, tagExists bool
+	err := db.dbConn.QueryRowContext(ctx, `select exists(select 1 from songs where song_id=$1 and deleted_at is null),
+exists(select 1 from tags where tag_id=$2)`, songID, tagID).Scan(&songExists, &tagExists)
+	if err != nil {
+		return err
+	}
+	if !songExists {
+		return fmt.Errorf("%w: song %d", ErrNotFound, songID)
+	}
+	if !tagExists {
+		return fmt.Errorf("%w: tag %d", ErrNotFound, tagID)
+	}
+
+	_, err = db.dbConn.ExecContext(ctx, `insert into song_tags (song_id, tag_id) values ($1, $2) on conflict do nothing`, songID, tagID)
+	return mapSQLiteError(err)
+}
+
+// снимает тег с песни
+func (db *SQLiteDatabase) DetachTag(songID, tagID int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from song_tags where song_id=$1 and tag_id=$2`, songID, tagID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func sqliteTagLookup(ctx context.Context, tx *sql.Tx) tagLookup {
+	return func(id int64) (string, *int64, error) {
+		var kind string
+		var parent *int64
+		err := tx.QueryRowContext(ctx, `select kind, parent_id from tags where tag_id=$1`, id).Scan(&kind, &parent)
+		return kind, parent, mapSQLiteError(err)
+	}
+}
+
+func collectSQLiteTags(rows *sql.Rows) ([]Tag, error) {
+	defer rows.Close()
+
+	tags := make([]Tag, 0, 16)
+	for rows.Next() {
+		var t Tag
+		err := rows.Scan(&t.ID, &t.Name, &t.Kind, &t.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// транзакции, которые сначала читают, а потом пишут, не должны падать
+// с SQLITE_BUSY при одновременных запросах (см. Config.SQLiteDSN)
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	store := NewSQLite(&Config{Driver: DriverSQLite, Path: filepath.Join(t.TempDir(), "library.db")})
+	openStore(t, store)
+	t.Cleanup(func() { store.dbConn.Close() })
+
+	const writers = 8
+	ids := make([]int64, writers)
+	for i := range ids {
+		ids[i] = addSong(t, store, Song{Group: "Muse", SongName: fmt.Sprintf("Song %d", i)})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 10 {
+				err := store.UpdateSongByID(ids[i], textUpdate(0, fmt.Sprintf("text %d", j), "no_data"), "test")
+				if err != nil {
+					errs <- fmt.Errorf("update song %d: %w", ids[i], err)
+					return
+				}
+				err = store.AddSong(Song{Group: fmt.Sprintf("Artist %d", i), SongName: fmt.Sprintf("Song %d", j)})
+				if err != nil {
+					errs <- fmt.Errorf("add song: %w", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// в базе хранится только хеш ключа доступа, сам ключ в неё не попадает
+func TestSQLiteStoresAPIKeyHash(t *testing.T) {
+	store := NewSQLite(&Config{Driver: DriverSQLite, Path: filepath.Join(t.TempDir(), "library.db")})
+	openStore(t, store)
+	t.Cleanup(func() { store.dbConn.Close() })
+
+	secret, hash, err := NewAPIKeySecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.AddAPIKey(APIKey{Name: "ci", Role: RoleReader}, hash)
+	if err != nil {
+		t.Fatalf("AddAPIKey: %v", err)
+	}
+
+	var name, storedHash, role string
+	err = store.dbConn.QueryRowContext(context.Background(), `select name, key_hash, role from api_keys`).
+		Scan(&name, &storedHash, &role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedHash != hash {
+		t.Errorf("key_hash = %q, want %q", storedHash, hash)
+	}
+	for _, column := range []string{name, storedHash, role} {
+		if strings.Contains(column, secret) {
+			t.Errorf("api_keys contains the key itself: %q", column)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// песни в корзине, последние удалённые - первыми
+func (db *SQLiteDatabase) ListTrash(offset, limit string) ([]TrashedSong, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.QueryContext(context.Background(), `select songs.song_id,
+coalesce(groups.author_name, songs.deleted_group, ''), songs.song_name,
+coalesce(songs.release_date, ''), coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version,
+songs.deleted_at, coalesce(songs.deleted_by, '')
+from songs left join groups using (author_id) where songs.deleted_at is not null
+order by songs.deleted_at desc, songs.song_id limit $1 offset $2`, limitInt, offsetInt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trash := make([]TrashedSong, 0, 16)
+	for rows.Next() {
+		var t TrashedSong
+		err = rows.Scan(&t.ID, &t.Group, &t.SongName, &t.ReleaseDate, &t.Text, &t.Link, &t.Version,
+			&t.DeletedAt, &t.DeletedBy)
+		if err != nil {
+			return nil, err
+		}
+		trash = append(trash, t)
+	}
+	return trash, rows.Err()
+}
+
+// возвращает песню из корзины (см. Database.RestoreSong)
+func (db *SQLiteDatabase) RestoreSong(id int64, by string) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var authorID sql.NullInt64
+	var group, name string
+	var version int64
+	err = tx.QueryRowContext(ctx, `select songs.author_id, coalesce(groups.author_name, songs.deleted_group, ''),
+songs.song_name, songs.version from songs left join groups using (author_id)
+where songs.song_id=$1 and songs.deleted_at is not null`, id).Scan(&authorID, &group, &name, &version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: song %d is not in the trash", ErrNotFound, id)
+		}
+		return err
+	}
+
+	newGroup := group
+	if !authorID.Valid {
+		newID, err := getSQLiteAuthorID(ctx, tx, group)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, `select author_name from groups where author_id=$1`, newID).Scan(&newGroup)
+		if err != nil {
+			return err
+		}
+		authorID = sql.NullInt64{Int64: int64(newID), Valid: true}
+	}
+
+	var taken bool
+	err = tx.QueryRowContext(ctx, `select exists(select 1 from songs where author_id=$1 and song_name=$2 and song_id<>$3)`,
+		authorID.Int64, name, id).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return restoreConflict(newGroup, name)
+	}
+
+	_, err = tx.ExecContext(ctx, `update songs set author_id=$2, deleted_at=null, deleted_by=null, deleted_group=null,
+version=version+1 where song_id=$1`, id, authorID.Int64)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	now := time.Now().UTC()
+	for _, c := range restoreChanges(group, newGroup) {
+		_, err = tx.ExecContext(ctx, `insert into song_history (song_id, version, changed_by, changed_at, field, old_value, new_value)
+values ($1, $2, $3, $4, $5, $6, $7)`, id, version+1, by, now, c.Field, c.Old, c.New)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// окончательно удаляет песню из корзины
+func (db *SQLiteDatabase) PurgeSong(id int64) error {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from songs where song_id=$1 and deleted_at is not null`, id)
+	if err != nil {
+		return err
+	}
+	return expectTrashed(res, id)
+}
+
+// окончательно удаляет песни, попавшие в корзину раньше before,
+// возвращает их количество
+func (db *SQLiteDatabase) PurgeTrash(before time.Time) (int64, error) {
+	res, err := db.dbConn.ExecContext(context.Background(), `delete from songs where deleted_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// как expectAffected, но с сообщением о том, что песни id нет в корзине
+func expectTrashed(res sql.Result, id int64) error {
+	err := expectAffected(res)
+	if err == ErrNotFound {
+		return fmt.Errorf("%w: song %d is not in the trash", ErrNotFound, id)
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"ApiServer/internal/app/validate"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound возвращается, если запрошенная песня или исполнитель не найдены
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists возвращается при попытке создать или переименовать запись
+	// так, что она совпадёт с уже существующей
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrVersionMismatch возвращается при изменении или удалении песни, если её
+	// версия в хранилище отличается от ожидаемой: песню успели изменить
+	ErrVersionMismatch = errors.New("version mismatch")
+)
+
+// Store - хранилище библиотеки песен, с которым работает apiserver.
+// Реализации: Database (PostgreSQL), SQLiteDatabase (встроенная sqlite)
+// и MemoryStore (в памяти процесса)
+type Store interface {
+	Open() error
+	ListAllLibrary(p ListParams) (Library, error)
+	CountLibrary(p ListParams) (int, error)
+	SearchLyrics(q SearchQuery, p ListParams) ([]SearchHit, error)
+	Suggest(kind, prefix string, limit int) ([]Suggestion, error)
+	// песни удаляются в корзину (см. trash.go) от имени by
+	DeleteSong(author_name, songName, by string) (int64, error)
+	AddSong(s Song) error
+	UpdateGroupName(author_name string, s Song, by string) error
+	UpdateSongDetails(author_name, song_name string, s Song, by string) error
+
+	GetSong(id int64) (Song, error)
+	// s.Version и version - ожидаемая версия песни, 0 - без проверки
+	UpdateSongByID(id int64, s Song, by string) error
+	DeleteSongByID(id, version int64, by string) error
+
+	ListTrash(offset, limit string) ([]TrashedSong, error)
+	RestoreSong(id int64, by string) error
+	PurgeSong(id int64) error
+	PurgeTrash(before time.Time) (int64, error)
+
+	// история изменений (см. history.go), by в методах выше - кто изменяет данные
+	SongRevisions(id int64) ([]Revision, error)
+	ArtistRevisions(id int) ([]Revision, error)
+
+	ListArtists(offset, limit string) ([]Artist, error)
+	GetArtist(id int) (Artist, error)
+	FindArtist(name string) (Artist, error)
+	AddArtist(name string) (Artist, error)
+	RenameArtist(id int, name, by string) error
+	DeleteArtist(id int, cascade bool, by string) error
+	MergeArtists(target int, sources []int, policy MergePolicy, by string) (MergeResult, error)
+
+	ListAlbums(artistID int, offset, limit string) ([]Album, error)
+	GetAlbum(id int64) (Album, error)
+	AddAlbum(a Album) (Album, error)
+	UpdateAlbum(id int64, a Album) error
+	DeleteAlbum(id int64) error
+	SetTrack(albumID, songID int64, disc, position int) error
+	RemoveTrack(albumID, songID int64) error
+
+	ListTags(kind string) ([]Tag, error)
+	GetTag(id int64) (Tag, error)
+	AddTag(t Tag) (Tag, error)
+	UpdateTag(id int64, t Tag) error
+	DeleteTag(id int64) error
+	SongTags(songID int64) ([]Tag, error)
+	AttachTag(songID, tagID int64) error
+	DetachTag(songID, tagID int64) error
+
+	ListPlaylists(owner, offset, limit string) ([]Playlist, error)
+	GetPlaylist(id int64) (Playlist, error)
+	AddPlaylist(p Playlist) (Playlist, error)
+	DeletePlaylist(id int64) error
+	AppendToPlaylist(id, songID int64) (int64, error)
+	MovePlaylistEntry(id, entryID int64, position int) error
+	RemovePlaylistEntry(id, entryID int64) error
+
+	ListSmartPlaylists(owner, offset, limit string) ([]SmartPlaylist, error)
+	GetSmartPlaylist(id int64) (SmartPlaylist, error)
+	AddSmartPlaylist(p SmartPlaylist) (SmartPlaylist, error)
+	UpdateSmartPlaylist(id int64, p SmartPlaylist) error
+	DeleteSmartPlaylist(id int64) error
+
+	ListAPIKeys() ([]APIKey, error)
+	AddAPIKey(k APIKey, hash string) (APIKey, error)
+	FindAPIKey(hash string) (APIKey, error)
+	RevokeAPIKey(id int64) error
+	RevokeToken(jti string, expires time.Time) error
+	TokenRevoked(jti string, keyID int64) (bool, error)
+}
+
+// NewStore создаёт хранилище в соответствии с драйвером, указанным в конфиге
+func NewStore(config *Config) (Store, error) {
+	switch config.Driver {
+	case DriverPostgres:
+		return New(config), nil
+	case DriverSQLite:
+		return NewSQLite(config), nil
+	case DriverMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", config.Driver)
+	}
+}
+
+// приводит дату к формату yyyy-mm-dd, в котором её возвращает postgres
+// пустая строка остаётся пустой (дата не указана)
+func normalizeDate(date string) (string, error) {
+	date = strings.TrimSpace(date)
+	if date == "" {
+		return "", nil
+	}
+	t, err := validate.ParseDate(date)
+	if err != nil {
+		return "", err
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+// проверяет, что у песни song ожидаемая версия version (0 - без проверки),
+// иначе возвращает ErrVersionMismatch
+func checkVersion(song Song, version int64) error {
+	if version != 0 && song.Version != version {
+		return fmt.Errorf("%w: song %d has version %d, not %d", ErrVersionMismatch, song.ID, song.Version, version)
+	}
+	return nil
+}
+
+// ошибка изменения песни id, которое не затронуло ни одной строки:
+// песни нет (ErrNotFound) или её версия отличается от ожидаемой
+func songChangeError(get func(id int64) (Song, error), id int64) error {
+	_, err := get(id)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: song %d", ErrVersionMismatch, id)
+}
+
+var (
+	_ Store = (*Database)(nil)
+	_ Store = (*SQLiteDatabase)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
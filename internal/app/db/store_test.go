@@ -0,0 +1,793 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// общие тесты хранилищ: все реализации Store должны вести себя одинаково.
+// Каждый тест получает пустое хранилище. Postgres проверяется, только если
+// задан TEST_DATABASE_URL, его таблицы библиотеки очищаются перед каждым тестом,
+// поэтому имя базы должно содержать test (см. testPostgresConfig)
+
+func TestMain(m *testing.M) {
+	// миграции ищутся относительно корня репозитория, как при запуске сервера
+	err := os.Chdir("../../..")
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// создаёт пустое хранилище для теста
+type storeFactory func(t *testing.T) Store
+
+func storeFactories() map[string]storeFactory {
+	stores := map[string]storeFactory{
+		"memory": func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+		"sqlite": func(t *testing.T) Store {
+			store := NewSQLite(&Config{Driver: DriverSQLite, Path: filepath.Join(t.TempDir(), "library.db")})
+			openStore(t, store)
+			t.Cleanup(func() { store.dbConn.Close() })
+			return store
+		},
+	}
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		stores["postgres"] = func(t *testing.T) Store {
+			config, err := testPostgresConfig(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := New(config)
+			openStore(t, store)
+			t.Cleanup(store.dbConn.Close)
+			_, err = store.dbConn.Exec(context.Background(), `truncate groups, songs, artist_aliases, albums,
+album_tracks, tags, song_tags, playlists, playlist_entries, smart_playlists, song_history, artist_history,
+api_keys, revoked_tokens restart identity cascade`)
+			if err != nil {
+				t.Fatalf("truncate: %v", err)
+			}
+			return store
+		}
+	}
+	return stores
+}
+
+// настройки postgres из TEST_DATABASE_URL. Тесты очищают таблицы базы,
+// поэтому база, в имени которой нет test, считается рабочей и не используется
+func testPostgresConfig(url string) (*Config, error) {
+	conn, err := pgconn.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("bad TEST_DATABASE_URL: %w", err)
+	}
+	if !strings.Contains(strings.ToLower(conn.Database), "test") {
+		return nil, fmt.Errorf("TEST_DATABASE_URL points to database %q: the tests truncate its tables, "+
+			"so only a database with \"test\" in its name is used", conn.Database)
+	}
+	return &Config{
+		Driver:   DriverPostgres,
+		Host:     conn.Host,
+		Port:     strconv.Itoa(int(conn.Port)),
+		DBName:   conn.Database,
+		User:     conn.User,
+		Password: conn.Password,
+	}, nil
+}
+
+func openStore(t *testing.T, store Store) {
+	t.Helper()
+	err := store.Open()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s Store)
+	}{
+		{"AddGet", testAddGet},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"Paging", testPaging},
+		{"Filters", testFilters},
+		{"Sort", testSort},
+		{"Cursor", testCursor},
+		{"Tags", testTags},
+		{"TrashMerge", testTrashMerge},
+		{"TrashCascade", testTrashCascade},
+		{"AlbumDates", testAlbumDates},
+		{"APIKeys", testAPIKeys},
+		{"RevokedTokens", testRevokedTokens},
+	}
+	for name, newStore := range storeFactories() {
+		t.Run(name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.test(t, newStore(t))
+				})
+			}
+		})
+	}
+}
+
+// добавляет песню и возвращает её id
+func addSong(t *testing.T, s Store, song Song) int64 {
+	t.Helper()
+	err := s.AddSong(song)
+	if err != nil {
+		t.Fatalf("AddSong(%q, %q): %v", song.Group, song.SongName, err)
+	}
+	lib, err := s.ListAllLibrary(ListParams{Filter: Song{Group: song.Group, SongName: song.SongName}})
+	if err != nil {
+		t.Fatalf("ListAllLibrary: %v", err)
+	}
+	if len(lib) != 1 {
+		t.Fatalf("song %q by %q: found %d songs, want 1", song.SongName, song.Group, len(lib))
+	}
+	return lib[0].ID
+}
+
+// названия песен списка по порядку
+func songNames(lib Library) []string {
+	names := make([]string, len(lib))
+	for i, song := range lib {
+		names[i] = song.SongName
+	}
+	return names
+}
+
+func listNames(t *testing.T, s Store, p ListParams) []string {
+	t.Helper()
+	lib, err := s.ListAllLibrary(p)
+	if err != nil {
+		t.Fatalf("ListAllLibrary: %v", err)
+	}
+	return songNames(lib)
+}
+
+func checkNames(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("songs = %q, want %q", got, want)
+	}
+}
+
+// пять песен трёх исполнителей, у одной нет даты выпуска
+func addLibrary(t *testing.T, s Store) {
+	t.Helper()
+	for _, song := range []Song{
+		{Group: "Muse", SongName: "Uprising", ReleaseDate: "07.09.2009", Text: "Paranoia", Link: "https://example.com/uprising"},
+		{Group: "Muse", SongName: "Hysteria", ReleaseDate: "01.12.2003", Text: "It's bugging me", Link: "https://example.com/hysteria"},
+		{Group: "Queen", SongName: "Bohemian Rhapsody", ReleaseDate: "31.10.1975", Text: "Is this the real life"},
+		{Group: "Queen", SongName: "Innuendo", ReleaseDate: "14.01.1991"},
+		{Group: "Adele", SongName: "Skyfall"},
+	} {
+		addSong(t, s, song)
+	}
+}
+
+func testAddGet(t *testing.T, s Store) {
+	id := addSong(t, s, Song{Group: "Muse", SongName: "Supermassive Black Hole", ReleaseDate: "16.07.2006",
+		Text: "Ooh baby, don't you know I suffer?", Link: "https://example.com/smbh"})
+
+	song, err := s.GetSong(id)
+	if err != nil {
+		t.Fatalf("GetSong: %v", err)
+	}
+	want := Song{ID: id, Group: "Muse", SongName: "Supermassive Black Hole", ReleaseDate: "2006-07-16",
+		Text: "Ooh baby, don't you know I suffer?", Link: "https://example.com/smbh", Version: 1}
+	if song != want {
+		t.Errorf("GetSong = %+v, want %+v", song, want)
+	}
+
+	err = s.AddSong(Song{Group: "Muse", SongName: "Supermassive Black Hole"})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("AddSong of the same song: %v, want ErrAlreadyExists", err)
+	}
+	err = s.AddSong(Song{Group: "Muse", SongName: "Bad date", ReleaseDate: "32.13.2006"})
+	if err == nil {
+		t.Error("AddSong with a bad date succeeded")
+	}
+	_, err = s.GetSong(id + 100)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetSong of a missing song: %v, want ErrNotFound", err)
+	}
+
+	// дата в формате yyyy-mm-dd сохраняется как есть, пустая - остаётся пустой
+	id = addSong(t, s, Song{Group: "Muse", SongName: "Starlight", ReleaseDate: "2006-09-04"})
+	song, _ = s.GetSong(id)
+	if song.ReleaseDate != "2006-09-04" {
+		t.Errorf("ReleaseDate = %q, want 2006-09-04", song.ReleaseDate)
+	}
+	id = addSong(t, s, Song{Group: "Muse", SongName: "Undated"})
+	song, _ = s.GetSong(id)
+	if song.ReleaseDate != "" {
+		t.Errorf("ReleaseDate = %q, want empty", song.ReleaseDate)
+	}
+}
+
+// изменение только текста и даты, остальные поля не передаются
+func textUpdate(version int64, text, date string) Song {
+	return Song{Group: "no_data", SongName: "no_data", ReleaseDate: date, Text: text, Link: "no_data", Version: version}
+}
+
+func testUpdate(t *testing.T, s Store) {
+	id := addSong(t, s, Song{Group: "Muse", SongName: "Uprising", ReleaseDate: "07.09.2009", Text: "old"})
+
+	err := s.UpdateSongByID(id, textUpdate(1, "new", "02.01.2010"), "test")
+	if err != nil {
+		t.Fatalf("UpdateSongByID: %v", err)
+	}
+	song, _ := s.GetSong(id)
+	if song.Text != "new" || song.ReleaseDate != "2010-01-02" || song.Version != 2 {
+		t.Errorf("after update: %+v, want text new, date 2010-01-02, version 2", song)
+	}
+
+	err = s.UpdateSongByID(id, textUpdate(1, "stale", "no_data"), "test")
+	if !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("update with a stale version: %v, want ErrVersionMismatch", err)
+	}
+	err = s.UpdateSongByID(id+100, textUpdate(0, "missing", "no_data"), "test")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("update of a missing song: %v, want ErrNotFound", err)
+	}
+	// песня не изменилась ни одной из неудачных попыток
+	song, _ = s.GetSong(id)
+	if song.Text != "new" || song.Version != 2 {
+		t.Errorf("after failed updates: %+v, want text new, version 2", song)
+	}
+
+	// смена исполнителя создаёт нового исполнителя
+	err = s.UpdateSongByID(id, Song{Group: "MUSE", SongName: "no_data", ReleaseDate: "no_data", Text: "no_data",
+		Link: "no_data"}, "test")
+	if err != nil {
+		t.Fatalf("UpdateSongByID: %v", err)
+	}
+	song, _ = s.GetSong(id)
+	if song.Group != "MUSE" || song.Version != 3 {
+		t.Errorf("after artist change: %+v, want group MUSE, version 3", song)
+	}
+	_, err = s.FindArtist("MUSE")
+	if err != nil {
+		t.Errorf("FindArtist(MUSE): %v", err)
+	}
+
+	// при неудачной проверке версии исполнитель не создаётся
+	err = s.UpdateSongByID(id, Song{Group: "Nobody", SongName: "no_data", ReleaseDate: "no_data", Text: "no_data",
+		Link: "no_data", Version: 1}, "test")
+	if !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("update with a stale version: %v, want ErrVersionMismatch", err)
+	}
+	_, err = s.FindArtist("Nobody")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindArtist(Nobody) after a failed update: %v, want ErrNotFound", err)
+	}
+
+	// через UpdateSongDetails песня ищется по исполнителю и названию
+	err = s.UpdateSongDetails("MUSE", "Uprising", textUpdate(0, "by name", "no_data"), "test")
+	if err != nil {
+		t.Fatalf("UpdateSongDetails: %v", err)
+	}
+	song, _ = s.GetSong(id)
+	if song.Text != "by name" {
+		t.Errorf("Text = %q, want %q", song.Text, "by name")
+	}
+	err = s.UpdateSongDetails("MUSE", "Missing", textUpdate(0, "x", "no_data"), "test")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateSongDetails of a missing song: %v, want ErrNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, s Store) {
+	first := addSong(t, s, Song{Group: "Muse", SongName: "Uprising"})
+	addSong(t, s, Song{Group: "Muse", SongName: "Hysteria"})
+
+	err := s.DeleteSongByID(first, 2, "test")
+	if !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("delete with a stale version: %v, want ErrVersionMismatch", err)
+	}
+	err = s.DeleteSongByID(first, 1, "test")
+	if err != nil {
+		t.Fatalf("DeleteSongByID: %v", err)
+	}
+	_, err = s.GetSong(first)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetSong of a deleted song: %v, want ErrNotFound", err)
+	}
+	err = s.DeleteSongByID(first, 0, "test")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: %v, want ErrNotFound", err)
+	}
+
+	deleted, err := s.DeleteSong("Muse", "Hysteria", "test")
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteSong = %d, %v, want 1", deleted, err)
+	}
+	deleted, err = s.DeleteSong("Muse", "Hysteria", "test")
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteSong of a deleted song = %d, %v, want 0", deleted, err)
+	}
+
+	checkNames(t, listNames(t, s, ListParams{}))
+	trash, err := s.ListTrash("", "")
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	if len(trash) != 2 || trash[0].DeletedBy != "test" {
+		t.Errorf("trash = %+v, want 2 songs deleted by test", trash)
+	}
+
+	err = s.RestoreSong(first, "restorer")
+	if err != nil {
+		t.Fatalf("RestoreSong: %v", err)
+	}
+	checkNames(t, listNames(t, s, ListParams{}), "Uprising")
+	err = s.RestoreSong(first, "restorer")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("second restore: %v, want ErrNotFound", err)
+	}
+
+	// восстановление - новая версия песни с записью в истории
+	song, _ := s.GetSong(first)
+	if song.Version != 2 {
+		t.Errorf("restored song version = %d, want 2", song.Version)
+	}
+	checkRestoreRevision(t, s, first, 2)
+
+	// название песни в корзине занято, ошибка указывает на неё
+	hysteria := trash[0].ID
+	if trash[0].SongName != "Hysteria" {
+		hysteria = trash[1].ID
+	}
+	err = s.AddSong(Song{Group: "Muse", SongName: "Hysteria"})
+	var trashed *TrashedSongError
+	if !errors.As(err, &trashed) || trashed.ID != hysteria {
+		t.Errorf("AddSong of a trashed song: %v, want TrashedSongError for song %d", err, hysteria)
+	}
+	err = s.AddSong(Song{Group: "Muse", SongName: "Uprising"})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("AddSong of an existing song: %v, want ErrAlreadyExists", err)
+	}
+}
+
+// последняя ревизия песни id - восстановление из корзины с версией version,
+// changes - дополнительные изменения
+func checkRestoreRevision(t *testing.T, s Store, id, version int64, changes ...Change) {
+	t.Helper()
+	revs, err := s.SongRevisions(id)
+	if err != nil {
+		t.Fatalf("SongRevisions: %v", err)
+	}
+	want := append([]Change{{Field: "deleted", Old: "true", New: "false"}}, changes...)
+	if len(revs) == 0 || revs[0].Version != version || revs[0].ChangedBy != "restorer" || !slices.Equal(revs[0].Changes, want) {
+		t.Errorf("revisions = %+v, want version %d by restorer with %+v", revs, version, want)
+	}
+}
+
+// песни в корзине по id: исполнитель и кто удалил
+func trashByID(t *testing.T, s Store) map[int64]TrashedSong {
+	t.Helper()
+	trash, err := s.ListTrash("", "")
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	byID := make(map[int64]TrashedSong, len(trash))
+	for _, song := range trash {
+		byID[song.ID] = song
+	}
+	return byID
+}
+
+func findArtist(t *testing.T, s Store, name string) int {
+	t.Helper()
+	artist, err := s.FindArtist(name)
+	if err != nil {
+		t.Fatalf("FindArtist(%q): %v", name, err)
+	}
+	return artist.ID
+}
+
+// при объединении исполнителей песни не удаляются: отброшенные дубликаты
+// уходят в корзину, а песни из корзины остаются в ней и восстанавливаются
+// к объединённому исполнителю
+func testTrashMerge(t *testing.T, s Store) {
+	uprising := addSong(t, s, Song{Group: "Muse", SongName: "Uprising"})
+	madness := addSong(t, s, Song{Group: "Muse", SongName: "Madness"})
+	sourceUprising := addSong(t, s, Song{Group: "MUSE", SongName: "Uprising"})
+	sourceMadness := addSong(t, s, Song{Group: "MUSE", SongName: "Madness"})
+	starlight := addSong(t, s, Song{Group: "MUSE", SongName: "Starlight"})
+	for _, id := range []int64{madness, starlight} {
+		err := s.DeleteSongByID(id, 0, "test")
+		if err != nil {
+			t.Fatalf("DeleteSongByID: %v", err)
+		}
+	}
+
+	result, err := s.MergeArtists(findArtist(t, s, "Muse"), []int{findArtist(t, s, "MUSE")}, MergeKeepTarget, "merger")
+	if err != nil {
+		t.Fatalf("MergeArtists: %v", err)
+	}
+	if result.Moved != 1 || !slices.Equal(result.Dropped, []int64{sourceUprising}) {
+		t.Errorf("merge result moved %d, dropped %v, want 1 and [%d]", result.Moved, result.Dropped, sourceUprising)
+	}
+	checkNames(t, listNames(t, s, ListParams{Filter: Song{Group: "Muse"}}), "Madness", "Uprising")
+
+	trash := trashByID(t, s)
+	want := map[int64]string{sourceUprising: "MUSE", madness: "Muse", starlight: "MUSE"}
+	if len(trash) != len(want) {
+		t.Fatalf("trash = %+v, want songs %v", trash, want)
+	}
+	for id, group := range want {
+		if trash[id].Group != group {
+			t.Errorf("trashed song %d: %+v, want group %s", id, trash[id], group)
+		}
+	}
+	if trash[sourceUprising].DeletedBy != "merger" {
+		t.Errorf("dropped song deleted by %q, want merger", trash[sourceUprising].DeletedBy)
+	}
+
+	// название занято песней, оставшейся у исполнителя
+	for _, id := range []int64{sourceUprising, madness} {
+		err = s.RestoreSong(id, "restorer")
+		if !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("restore of song %d: %v, want ErrAlreadyExists", id, err)
+		}
+	}
+	err = s.RestoreSong(starlight, "restorer")
+	if err != nil {
+		t.Fatalf("RestoreSong: %v", err)
+	}
+	song, err := s.GetSong(starlight)
+	if err != nil || song.Group != "Muse" || song.Version != 2 {
+		t.Errorf("restored song = %+v, %v, want group Muse, version 2", song, err)
+	}
+	checkRestoreRevision(t, s, starlight, 2, Change{Field: "group", Old: "MUSE", New: "Muse"})
+
+	err = s.PurgeSong(sourceUprising)
+	if err != nil {
+		t.Errorf("PurgeSong: %v", err)
+	}
+	if _, err = s.GetSong(uprising); err != nil {
+		t.Errorf("kept song: %v", err)
+	}
+	song, err = s.GetSong(sourceMadness)
+	if err != nil || song.Group != "Muse" || song.Version != 2 {
+		t.Errorf("moved song = %+v, %v, want group Muse, version 2", song, err)
+	}
+}
+
+// каскадное удаление исполнителя переносит его песни в корзину,
+// при восстановлении исполнитель добавляется заново
+func testTrashCascade(t *testing.T, s Store) {
+	innuendo := addSong(t, s, Song{Group: "Queen", SongName: "Innuendo"})
+	bicycle := addSong(t, s, Song{Group: "Queen", SongName: "Bicycle Race"})
+	addSong(t, s, Song{Group: "Muse", SongName: "Uprising"})
+	err := s.DeleteSongByID(bicycle, 0, "test")
+	if err != nil {
+		t.Fatalf("DeleteSongByID: %v", err)
+	}
+	queen := findArtist(t, s, "Queen")
+
+	err = s.DeleteArtist(queen, false, "admin")
+	if !errors.Is(err, ErrArtistHasSongs) {
+		t.Errorf("DeleteArtist without cascade: %v, want ErrArtistHasSongs", err)
+	}
+	err = s.DeleteArtist(queen, true, "admin")
+	if err != nil {
+		t.Fatalf("DeleteArtist: %v", err)
+	}
+	if _, err = s.FindArtist("Queen"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindArtist of a deleted artist: %v, want ErrNotFound", err)
+	}
+	checkNames(t, listNames(t, s, ListParams{}), "Uprising")
+
+	trash := trashByID(t, s)
+	if len(trash) != 2 || trash[innuendo].Group != "Queen" || trash[innuendo].DeletedBy != "admin" ||
+		trash[bicycle].Group != "Queen" || trash[bicycle].DeletedBy != "test" {
+		t.Errorf("trash = %+v, want both songs of Queen, Innuendo deleted by admin", trash)
+	}
+
+	err = s.RestoreSong(innuendo, "restorer")
+	if err != nil {
+		t.Fatalf("RestoreSong: %v", err)
+	}
+	checkNames(t, listNames(t, s, ListParams{Filter: Song{Group: "Queen"}}), "Innuendo")
+	checkRestoreRevision(t, s, innuendo, 2)
+	// вторая песня возвращается к тому же, заново добавленному исполнителю
+	err = s.RestoreSong(bicycle, "restorer")
+	if err != nil {
+		t.Fatalf("RestoreSong: %v", err)
+	}
+	checkNames(t, listNames(t, s, ListParams{Filter: Song{Group: "Queen"}}), "Bicycle Race", "Innuendo")
+}
+
+func testPaging(t *testing.T, s Store) {
+	addLibrary(t, s)
+
+	checkNames(t, listNames(t, s, ListParams{}),
+		"Skyfall", "Hysteria", "Uprising", "Bohemian Rhapsody", "Innuendo")
+	checkNames(t, listNames(t, s, ListParams{Offset: "1", Limit: "2"}), "Hysteria", "Uprising")
+	checkNames(t, listNames(t, s, ListParams{Offset: "4"}), "Innuendo")
+	checkNames(t, listNames(t, s, ListParams{Offset: "5"}))
+	checkNames(t, listNames(t, s, ListParams{Limit: "0"}))
+
+	count, err := s.CountLibrary(ListParams{Offset: "1", Limit: "2"})
+	if err != nil || count != 5 {
+		t.Errorf("CountLibrary = %d, %v, want 5", count, err)
+	}
+
+	for _, p := range []ListParams{{Offset: "-1"}, {Limit: "-1"}, {Offset: "x"}} {
+		_, err = s.ListAllLibrary(p)
+		if err == nil {
+			t.Errorf("ListAllLibrary(offset %q, limit %q) succeeded", p.Offset, p.Limit)
+		}
+	}
+}
+
+func testFilters(t *testing.T, s Store) {
+	addLibrary(t, s)
+
+	tests := []struct {
+		name   string
+		filter Song
+		want   []string
+	}{
+		{"artist", Song{Group: "Queen"}, []string{"Bohemian Rhapsody", "Innuendo"}},
+		{"song", Song{SongName: "Skyfall"}, []string{"Skyfall"}},
+		{"artist and song", Song{Group: "Muse", SongName: "Innuendo"}, nil},
+		{"date", Song{ReleaseDate: "2003-12-01"}, []string{"Hysteria"}},
+		{"date dd.mm.yyyy", Song{ReleaseDate: "01.12.2003"}, []string{"Hysteria"}},
+		{"text", Song{Text: "Paranoia"}, []string{"Uprising"}},
+		{"link", Song{Link: "https://example.com/hysteria"}, []string{"Hysteria"}},
+		{"missing artist", Song{Group: "Nobody"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ListParams{Filter: tt.filter}
+			checkNames(t, listNames(t, s, p), tt.want...)
+			count, err := s.CountLibrary(p)
+			if err != nil || count != len(tt.want) {
+				t.Errorf("CountLibrary = %d, %v, want %d", count, err, len(tt.want))
+			}
+		})
+	}
+}
+
+func testSort(t *testing.T, s Store) {
+	addLibrary(t, s)
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"song", []string{"Bohemian Rhapsody", "Hysteria", "Innuendo", "Skyfall", "Uprising"}},
+		{"-song", []string{"Uprising", "Skyfall", "Innuendo", "Hysteria", "Bohemian Rhapsody"}},
+		{"id", []string{"Uprising", "Hysteria", "Bohemian Rhapsody", "Innuendo", "Skyfall"}},
+		// песни без даты идут последними при любом направлении
+		{"releaseDate", []string{"Bohemian Rhapsody", "Innuendo", "Hysteria", "Uprising", "Skyfall"}},
+		{"-releaseDate", []string{"Uprising", "Hysteria", "Innuendo", "Bohemian Rhapsody", "Skyfall"}},
+		{"-artist,song", []string{"Bohemian Rhapsody", "Innuendo", "Hysteria", "Uprising", "Skyfall"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			checkNames(t, listNames(t, s, ListParams{Sort: tt.sort}), tt.want...)
+		})
+	}
+}
+
+func testCursor(t *testing.T, s Store) {
+	addLibrary(t, s)
+
+	// по две песни в порядке -releaseDate: вперёд до конца и на страницу назад
+	const sort = "-releaseDate"
+	lib, err := s.ListAllLibrary(ListParams{Sort: sort, Limit: "2"})
+	if err != nil {
+		t.Fatalf("ListAllLibrary: %v", err)
+	}
+	checkNames(t, songNames(lib), "Uprising", "Hysteria")
+
+	pages := [][]string{{"Innuendo", "Bohemian Rhapsody"}, {"Skyfall"}}
+	for _, want := range pages {
+		c := NewCursor(sort, lib[len(lib)-1], false)
+		lib, err = s.ListAllLibrary(ListParams{Sort: sort, Limit: "2", Cursor: &c})
+		if err != nil {
+			t.Fatalf("ListAllLibrary: %v", err)
+		}
+		checkNames(t, songNames(lib), want...)
+	}
+
+	c := NewCursor(sort, lib[0], true)
+	checkNames(t, listNames(t, s, ListParams{Sort: sort, Limit: "2", Cursor: &c}), "Innuendo", "Bohemian Rhapsody")
+
+	// курсор по строке, как его передаёт клиент
+	parsed, err := ParseCursor(NewCursor(sort, lib[0], true).String())
+	if err != nil {
+		t.Fatalf("ParseCursor: %v", err)
+	}
+	checkNames(t, listNames(t, s, ListParams{Sort: sort, Cursor: &parsed}),
+		"Uprising", "Hysteria", "Innuendo", "Bohemian Rhapsody")
+}
+
+func testTags(t *testing.T, s Store) {
+	uprising := addSong(t, s, Song{Group: "Muse", SongName: "Uprising"})
+	rhapsody := addSong(t, s, Song{Group: "Queen", SongName: "Bohemian Rhapsody"})
+	addSong(t, s, Song{Group: "Adele", SongName: "Skyfall"})
+
+	rock, err := s.AddTag(Tag{Name: "rock", Kind: TagKindGenre})
+	if err != nil {
+		t.Fatalf("AddTag: %v", err)
+	}
+	altRock, err := s.AddTag(Tag{Name: "alternative rock", Kind: TagKindGenre, ParentID: &rock.ID})
+	if err != nil {
+		t.Fatalf("AddTag: %v", err)
+	}
+	live, err := s.AddTag(Tag{Name: "live", Kind: TagKindTag})
+	if err != nil {
+		t.Fatalf("AddTag: %v", err)
+	}
+	_, err = s.AddTag(Tag{Name: "live", Kind: TagKindTag})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("AddTag of the same tag: %v, want ErrAlreadyExists", err)
+	}
+	_, err = s.AddTag(Tag{Name: "live rock", Kind: TagKindTag, ParentID: &rock.ID})
+	if !errors.Is(err, ErrBadTagParent) {
+		t.Errorf("AddTag of a tag with a parent: %v, want ErrBadTagParent", err)
+	}
+
+	for _, attach := range []struct{ song, tag int64 }{
+		{uprising, altRock.ID}, {uprising, live.ID}, {rhapsody, rock.ID},
+	} {
+		err = s.AttachTag(attach.song, attach.tag)
+		if err != nil {
+			t.Fatalf("AttachTag(%d, %d): %v", attach.song, attach.tag, err)
+		}
+	}
+	err = s.AttachTag(uprising+100, live.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("AttachTag to a missing song: %v, want ErrNotFound", err)
+	}
+
+	tags, err := s.SongTags(uprising)
+	if err != nil {
+		t.Fatalf("SongTags: %v", err)
+	}
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"alternative rock", "live"}) {
+		t.Errorf("SongTags = %q, want alternative rock and live", names)
+	}
+
+	// жанр включает свои поджанры
+	checkNames(t, listNames(t, s, ListParams{Tags: []string{"rock"}}), "Uprising", "Bohemian Rhapsody")
+	checkNames(t, listNames(t, s, ListParams{Tags: []string{"alternative rock"}}), "Uprising")
+	checkNames(t, listNames(t, s, ListParams{Tags: []string{"rock", "live"}, TagMode: TagModeAll}), "Uprising")
+	checkNames(t, listNames(t, s, ListParams{Tags: []string{"live", "missing"}, TagMode: TagModeAny}), "Uprising")
+
+	err = s.DetachTag(uprising, live.ID)
+	if err != nil {
+		t.Fatalf("DetachTag: %v", err)
+	}
+	checkNames(t, listNames(t, s, ListParams{Tags: []string{"live"}}))
+}
+
+// даты альбомов, как и песен, хранятся и возвращаются в формате yyyy-mm-dd
+func testAlbumDates(t *testing.T, s Store) {
+	artist, err := s.AddArtist("Muse")
+	if err != nil {
+		t.Fatalf("AddArtist: %v", err)
+	}
+	album, err := s.AddAlbum(Album{Title: "The Resistance", ArtistID: artist.ID, ReleaseDate: "14.09.2009", Type: "LP"})
+	if err != nil {
+		t.Fatalf("AddAlbum: %v", err)
+	}
+	if album.ReleaseDate != "2009-09-14" {
+		t.Errorf("added album date = %q, want 2009-09-14", album.ReleaseDate)
+	}
+
+	for date, want := range map[string]string{"02.01.2010": "2010-01-02", "2011-03-04": "2011-03-04", "": ""} {
+		err = s.UpdateAlbum(album.ID, Album{Title: "The Resistance", ArtistID: artist.ID, ReleaseDate: date, Type: "LP"})
+		if err != nil {
+			t.Fatalf("UpdateAlbum(%q): %v", date, err)
+		}
+		got, _ := s.GetAlbum(album.ID)
+		if got.ReleaseDate != want {
+			t.Errorf("album date after update to %q = %q, want %q", date, got.ReleaseDate, want)
+		}
+	}
+}
+
+// ключ ищется только по хешу, отозванный ключ не находится, но остаётся в списке
+func testAPIKeys(t *testing.T, s Store) {
+	secret, hash, err := NewAPIKeySecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, apiKeyPrefix) || hash != HashAPIKey(secret) || strings.Contains(hash, secret) {
+		t.Fatalf("secret %q, hash %q", secret, hash)
+	}
+	key, err := s.AddAPIKey(APIKey{Name: "ci", Role: RoleEditor}, hash)
+	if err != nil {
+		t.Fatalf("AddAPIKey: %v", err)
+	}
+	_, err = s.AddAPIKey(APIKey{Name: "copy", Role: RoleReader}, hash)
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("AddAPIKey with the same hash: %v, want ErrAlreadyExists", err)
+	}
+
+	found, err := s.FindAPIKey(hash)
+	if err != nil || found.ID != key.ID || found.Name != "ci" || found.Role != RoleEditor {
+		t.Errorf("FindAPIKey = %+v, %v, want key %d", found, err, key.ID)
+	}
+	_, err = s.FindAPIKey(secret)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindAPIKey by the secret itself: %v, want ErrNotFound", err)
+	}
+
+	err = s.RevokeAPIKey(key.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	_, err = s.FindAPIKey(hash)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindAPIKey of a revoked key: %v, want ErrNotFound", err)
+	}
+	err = s.RevokeAPIKey(key.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("RevokeAPIKey twice: %v, want ErrNotFound", err)
+	}
+	keys, err := s.ListAPIKeys()
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("ListAPIKeys = %+v, %v, want the revoked key", keys, err)
+	}
+}
+
+// токен отозван сам или вместе с ключом, по которому выдан
+func testRevokedTokens(t *testing.T, s Store) {
+	_, hash, err := NewAPIKeySecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.AddAPIKey(APIKey{Name: "ci", Role: RoleReader}, hash)
+	if err != nil {
+		t.Fatalf("AddAPIKey: %v", err)
+	}
+	check := func(jti string, keyID int64, want bool) {
+		t.Helper()
+		revoked, err := s.TokenRevoked(jti, keyID)
+		if err != nil || revoked != want {
+			t.Errorf("TokenRevoked(%q, %d) = %v, %v, want %v", jti, keyID, revoked, err, want)
+		}
+	}
+
+	check("first", key.ID, false)
+	err = s.RevokeToken("first", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	check("first", key.ID, true)
+	check("second", key.ID, false)
+	check("second", key.ID+1, true)
+
+	err = s.RevokeAPIKey(key.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	check("second", key.ID, true)
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	SuggestArtist = "artist"
+	SuggestSong   = "song"
+)
+
+// SuggestKinds - что можно подсказывать при наборе
+var SuggestKinds = []string{SuggestArtist, SuggestSong}
+
+// Suggestion - исполнитель или песня, имя которых начинается с набранного текста
+type Suggestion struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// исполнитель песни, только для подсказок песен
+	Group string `json:"group,omitempty"`
+}
+
+// убирает диакритические знаки: буквы раскладываются на базовую букву
+// и комбинируемые знаки, знаки удаляются
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// имя в виде для сравнения без учёта регистра и диакритики:
+// "Beyoncé" -> "beyonce", "Ёлка" -> "елка" (как lower(unaccent(...)) в postgres)
+func foldName(name string) string {
+	folded, _, err := transform.String(stripMarks, name)
+	if err != nil {
+		folded = name
+	}
+	return strings.ToLower(folded)
+}
+
+// экранирует служебные символы like, чтобы префикс сравнивался буквально
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// до limit исполнителей (kind = SuggestArtist) или песен (SuggestSong),
+// имя которых начинается с prefix без учёта регистра и диакритики,
+// в алфавитном порядке. Поиск использует индексы по fold_name(имя)
+func (db *Database) Suggest(kind, prefix string, limit int) ([]Suggestion, error) {
+	var query string
+	switch kind {
+	case SuggestArtist:
+		query = `select author_id, author_name, '' from groups
+where fold_name(author_name) like fold_name($1) || '%'
+order by fold_name(author_name), author_name limit $2`
+	case SuggestSong:
+		query = `select songs.song_id, songs.song_name, groups.author_name from songs inner join groups using (author_id)
+where songs.deleted_at is null and fold_name(songs.song_name) like fold_name($1) || '%'
+order by fold_name(songs.song_name), songs.song_name, groups.author_name limit $2`
+	default:
+		return nil, fmt.Errorf("unknown suggestion kind %q", kind)
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), query, escapeLike(prefix), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := make([]Suggestion, 0, limit)
+	for rows.Next() {
+		var s Suggestion
+		err = rows.Scan(&s.ID, &s.Name, &s.Group)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
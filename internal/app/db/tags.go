@@ -0,0 +1,244 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrBadTagParent возвращается, если родитель тега не может быть назначен:
+// родитель есть только у жанров, сам он тоже должен быть жанром,
+// и жанр не может оказаться среди собственных поджанров
+var ErrBadTagParent = errors.New("bad tag parent")
+
+const (
+	TagKindGenre = "genre"
+	TagKindTag   = "tag"
+)
+
+// виды тегов: жанры образуют иерархию, обычные теги - нет
+var TagKinds = []string{TagKindGenre, TagKindTag}
+
+type Tag struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	ParentID *int64 `json:"parentId,omitempty"`
+	// непосредственные поджанры, заполняется только в GetTag
+	Children []Tag `json:"children,omitempty"`
+}
+
+// возвращает вид и родителя тега с указанным id
+type tagLookup func(id int64) (kind string, parent *int64, err error)
+
+// проверяет, что тегу id (0 - новый тег) можно назначить данные t
+// с точки зрения иерархии жанров
+func checkTagParent(id int64, t Tag, lookup tagLookup) error {
+	if t.ParentID == nil {
+		return nil
+	}
+	if t.Kind != TagKindGenre {
+		return fmt.Errorf("%w: only genres can have a parent", ErrBadTagParent)
+	}
+
+	for p := t.ParentID; p != nil; {
+		if *p == id {
+			return fmt.Errorf("%w: genre can't be its own subgenre", ErrBadTagParent)
+		}
+		kind, parent, err := lookup(*p)
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("%w: tag %d", ErrNotFound, *p)
+		}
+		if err != nil {
+			return err
+		}
+		if p == t.ParentID && kind != TagKindGenre {
+			return fmt.Errorf("%w: parent must be a genre", ErrBadTagParent)
+		}
+		p = parent
+	}
+	return nil
+}
+
+// список тегов, отсортированный по имени, kind != "" - только теги этого вида
+func (db *Database) ListTags(kind string) ([]Tag, error) {
+	rows, err := db.dbConn.Query(context.Background(), `select tag_id, name, kind, parent_id from tags
+where ($1 = '' or kind = $1) order by name`, kind)
+	if err != nil {
+		return nil, err
+	}
+	return collectTags(rows)
+}
+
+// тег вместе с его непосредственными поджанрами
+func (db *Database) GetTag(id int64) (Tag, error) {
+	var t Tag
+	err := db.dbConn.QueryRow(context.Background(), `select tag_id, name, kind, parent_id from tags where tag_id=$1`, id).
+		Scan(&t.ID, &t.Name, &t.Kind, &t.ParentID)
+	if err != nil {
+		return Tag{}, mapError(err)
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), `select tag_id, name, kind, parent_id from tags
+where parent_id=$1 order by name`, id)
+	if err != nil {
+		return Tag{}, err
+	}
+	t.Children, err = collectTags(rows)
+	return t, err
+}
+
+func (db *Database) AddTag(t Tag) (Tag, error) {
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return Tag{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = checkTagParent(0, t, pgTagLookup(ctx, tx))
+	if err != nil {
+		return Tag{}, err
+	}
+
+	err = tx.QueryRow(ctx, `insert into tags (name, kind, parent_id) values ($1, $2, $3) returning tag_id`,
+		t.Name, t.Kind, t.ParentID).Scan(&t.ID)
+	if err != nil {
+		return Tag{}, mapError(err)
+	}
+	return t, tx.Commit(ctx)
+}
+
+// замена имени, вида и родителя тега
+// жанр, у которого есть поджанры, нельзя превратить в обычный тег
+func (db *Database) UpdateTag(id int64, t Tag) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокируем тег, чтобы параллельное изменение иерархии не создало цикл
+	err = tx.QueryRow(ctx, `select tag_id from tags where tag_id=$1 for update`, id).Scan(&id)
+	if err != nil {
+		return mapError(err)
+	}
+	err = checkTagParent(id, t, pgTagLookup(ctx, tx))
+	if err != nil {
+		return err
+	}
+	if t.Kind != TagKindGenre {
+		var hasChildren bool
+		err = tx.QueryRow(ctx, `select exists(select 1 from tags where parent_id=$1)`, id).Scan(&hasChildren)
+		if err != nil {
+			return err
+		}
+		if hasChildren {
+			return fmt.Errorf("%w: genre has subgenres", ErrBadTagParent)
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `update tags set name=$1, kind=$2, parent_id=$3 where tag_id=$4`, t.Name, t.Kind, t.ParentID, id)
+	slog.Debug("updating tag", "db response", tag.String())
+	if err != nil {
+		return mapError(err)
+	}
+	return tx.Commit(ctx)
+}
+
+// удаление тега, его поджанры становятся жанрами верхнего уровня
+func (db *Database) DeleteTag(id int64) error {
+	tag, err := db.dbConn.Exec(context.Background(), `delete from tags where tag_id=$1`, id)
+	slog.Debug("deleting tag", "db response", tag.String())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// теги песни, отсортированные по имени
+func (db *Database) SongTags(songID int64) ([]Tag, error) {
+	var exists bool
+	err := db.dbConn.QueryRow(context.Background(), `select exists(select 1 from songs where song_id=$1 and deleted_at is null)`, songID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), `select tags.tag_id, tags.name, tags.kind, tags.parent_id
+from song_tags inner join tags using (tag_id) where song_tags.song_id=$1 order by tags.name`, songID)
+	if err != nil {
+		return nil, err
+	}
+	return collectTags(rows)
+}
+
+// отмечает песню тегом, повторная отметка ничего не меняет
+func (db *Database) AttachTag(songID, tagID int64) error {
+	ctx := context.Background()
+	var songExists, tagExists bool
+	err := db.dbConn.QueryRow(ctx, `select exists(select 1 from songs where song_id=$1 and deleted_at is null),
+exists(select 1 from tags where tag_id=$2)`, songID, tagID).Scan(&songExists, &tagExists)
+	if err != nil {
+		return err
+	}
+	if !songExists {
+		return fmt.Errorf("%w: song %d", ErrNotFound, songID)
+	}
+	if !tagExists {
+		return fmt.Errorf("%w: tag %d", ErrNotFound, tagID)
+	}
+
+	tag, err := db.dbConn.Exec(ctx, `insert into song_tags (song_id, tag_id) values ($1, $2) on conflict do nothing`, songID, tagID)
+	slog.Debug("attaching tag", "db response", tag.String())
+	if err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+// снимает тег с песни
+func (db *Database) DetachTag(songID, tagID int64) error {
+	tag, err := db.dbConn.Exec(context.Background(), `delete from song_tags where song_id=$1 and tag_id=$2`, songID, tagID)
+	slog.Debug("detaching tag", "db response", tag.String())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func pgTagLookup(ctx context.Context, tx pgx.Tx) tagLookup {
+	return func(id int64) (string, *int64, error) {
+		var kind string
+		var parent *int64
+		err := tx.QueryRow(ctx, `select kind, parent_id from tags where tag_id=$1`, id).Scan(&kind, &parent)
+		return kind, parent, mapError(err)
+	}
+}
+
+func collectTags(rows pgx.Rows) ([]Tag, error) {
+	defer rows.Close()
+
+	tags := make([]Tag, 0, 16)
+	for rows.Next() {
+		var t Tag
+		err := rows.Scan(&t.ID, &t.Name, &t.Kind, &t.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
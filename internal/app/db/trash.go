@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// корзина: удалённая песня (DeleteSong, DeleteSongByID) остаётся в таблице songs
+// с временем удаления и не видна при чтении - ни в списках и поиске, ни по id,
+// ни в альбомах, плейлистах и у исполнителя. Её теги, треки альбомов, записи
+// плейлистов и история сохраняются и возвращаются вместе с ней при восстановлении.
+// Песня в корзине занимает своё название у исполнителя, пока её не восстановят
+// или не удалят окончательно (PurgeSong, PurgeTrash): AddSong с этим названием
+// возвращает TrashedSongError.
+// В корзину попадают и песни исполнителя, удалённого каскадно (DeleteArtist),
+// и дубликаты, отброшенные при объединении исполнителей (MergeArtists). Такие песни,
+// как и уже лежавшие в корзине песни удалённого или объединённого исполнителя,
+// отвязываются от него: author_id = null, а его имя хранится в deleted_group.
+// При восстановлении исполнитель ищется по этому имени (в том числе среди
+// псевдонимов объединённых исполнителей) и добавляется, если его нет
+
+// ErrInTrash возвращается при добавлении песни, название которой у исполнителя
+// занято песней в корзине (см. TrashedSongError)
+var ErrInTrash = errors.New("a song with this name is in the trash")
+
+// TrashedSongError - название добавляемой песни занято песней ID в корзине:
+// её нужно восстановить или удалить окончательно
+type TrashedSongError struct {
+	ID int64
+}
+
+func (e *TrashedSongError) Error() string {
+	return fmt.Sprintf("%s: song %d", ErrInTrash, e.ID)
+}
+
+func (e *TrashedSongError) Unwrap() error {
+	return ErrInTrash
+}
+
+// TrashedSong - песня в корзине
+type TrashedSong struct {
+	Song
+	DeletedAt time.Time `json:"deletedAt"`
+	DeletedBy string    `json:"deletedBy"`
+}
+
+// песни в корзине, последние удалённые - первыми
+func (db *Database) ListTrash(offset, limit string) ([]TrashedSong, error) {
+	offsetInt, limitInt, err := parsePage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.dbConn.Query(context.Background(), `select songs.song_id,
+coalesce(groups.author_name, songs.deleted_group, ''), songs.song_name,
+coalesce(songs.release_date::text, ''), coalesce(songs.song_text, ''), coalesce(songs.link, ''), songs.version,
+songs.deleted_at, coalesce(songs.deleted_by, '')
+from songs left join groups using (author_id) where songs.deleted_at is not null
+order by songs.deleted_at desc, songs.song_id offset $1 limit $2`, offsetInt, nullLimit(limitInt))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trash := make([]TrashedSong, 0, 16)
+	for rows.Next() {
+		var t TrashedSong
+		err = rows.Scan(&t.ID, &t.Group, &t.SongName, &t.ReleaseDate, &t.Text, &t.Link, &t.Version,
+			&t.DeletedAt, &t.DeletedBy)
+		if err != nil {
+			return nil, err
+		}
+		trash = append(trash, t)
+	}
+	return trash, rows.Err()
+}
+
+// возвращает песню из корзины, версия песни увеличивается, а восстановление
+// записывается в её историю от имени by. ErrAlreadyExists, если у исполнителя
+// уже есть другая песня с таким названием
+func (db *Database) RestoreSong(id int64, by string) error {
+	ctx := context.Background()
+	tx, err := db.dbConn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var authorID *int
+	var group, name string
+	var version int64
+	err = tx.QueryRow(ctx, `select songs.author_id, coalesce(groups.author_name, songs.deleted_group, ''),
+songs.song_name, songs.version from songs left join groups using (author_id)
+where songs.song_id=$1 and songs.deleted_at is not null for update of songs`, id).Scan(&authorID, &group, &name, &version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: song %d is not in the trash", ErrNotFound, id)
+		}
+		return err
+	}
+
+	newGroup := group
+	if authorID == nil {
+		newID, err := getAuthorID(ctx, tx, group)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRow(ctx, `select author_name from groups where author_id=$1`, newID).Scan(&newGroup)
+		if err != nil {
+			return err
+		}
+		authorID = &newID
+	}
+
+	var taken bool
+	err = tx.QueryRow(ctx, `select exists(select 1 from songs where author_id=$1 and song_name=$2 and song_id<>$3)`,
+		*authorID, name, id).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return restoreConflict(newGroup, name)
+	}
+
+	tag, err := tx.Exec(ctx, `update songs set author_id=$2, deleted_at=null, deleted_by=null, deleted_group=null,
+version=version+1 where song_id=$1`, id, *authorID)
+	slog.Debug("restoring song", "db response", tag.String())
+	if err != nil {
+		return mapError(err)
+	}
+	for _, c := range restoreChanges(group, newGroup) {
+		_, err = tx.Exec(ctx, `insert into song_history (song_id, version, changed_by, field, old_value, new_value)
+values ($1, $2, $3, $4, $5, $6)`, id, version+1, by, c.Field, c.Old, c.New)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// окончательно удаляет песню из корзины
+func (db *Database) PurgeSong(id int64) error {
+	tag, err := db.dbConn.Exec(context.Background(), `delete from songs where song_id=$1 and deleted_at is not null`, id)
+	slog.Debug("purging song", "db response", tag.String())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: song %d is not in the trash", ErrNotFound, id)
+	}
+	return nil
+}
+
+// окончательно удаляет песни, попавшие в корзину раньше before,
+// возвращает их количество
+func (db *Database) PurgeTrash(before time.Time) (int64, error) {
+	tag, err := db.dbConn.Exec(context.Background(), `delete from songs where deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// изменения, записываемые в историю песни при восстановлении: отметка
+// о восстановлении и смена group, если песня вернулась к исполнителю
+// с другим именем (её исполнителя объединили с другим)
+func restoreChanges(oldGroup, newGroup string) []Change {
+	changes := []Change{{Field: "deleted", Old: "true", New: "false"}}
+	if oldGroup != newGroup {
+		changes = append(changes, Change{Field: "group", Old: oldGroup, New: newGroup})
+	}
+	return changes
+}
+
+// песню нельзя восстановить: у исполнителя уже есть песня с таким названием
+func restoreConflict(group, name string) error {
+	return fmt.Errorf("%w: %q already has a song %q, rename or delete it before restoring", ErrAlreadyExists, group, name)
+}
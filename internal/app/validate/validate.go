@@ -0,0 +1,193 @@
+// Package validate проверяет параметры запросов и тела запросов до обращения
+// к хранилищу. Validator не останавливается на первой ошибке, а собирает
+// ошибки всех полей, чтобы клиент мог исправить их за один раз
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ограничения длины строковых полей (в символах)
+const (
+	// имена и названия: исполнитель, песня, альбом, тег, плейлист, владелец
+	MaxNameLength = 255
+	MaxLinkLength = 2048
+	MaxTextLength = 100_000
+)
+
+// коды ошибок полей
+const (
+	CodeRequired   = "required"
+	CodeTooLong    = "too_long"
+	CodeBadDate    = "bad_date"
+	CodeBadURL     = "bad_url"
+	CodeBadNumber  = "bad_number"
+	CodeBadBool    = "bad_bool"
+	CodeOutOfRange = "out_of_range"
+	CodeBadValue   = "bad_value"
+)
+
+// форматы дат, которые принимает postgres при datestyle iso,dmy
+var dateLayouts = []string{
+	"2006-01-02",
+	"02.01.2006",
+	"02-01-2006",
+	"02/01/2006",
+}
+
+// FieldError - ошибка в одном поле запроса
+type FieldError struct {
+	// параметр запроса, поле тела или переменная пути
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors - ошибки всех полей запроса
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validator собирает ошибки проверки полей, нулевое значение готово к работе.
+// Методы возвращают true, если поле прошло проверку
+type Validator struct {
+	errs Errors
+}
+
+// Add добавляет ошибку поля field
+func (v *Validator) Add(field, code, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Check добавляет ошибку, если условие ok не выполнено
+func (v *Validator) Check(ok bool, field, code, format string, args ...any) bool {
+	if !ok {
+		v.Add(field, code, format, args...)
+	}
+	return ok
+}
+
+// Has сообщает, есть ли уже ошибка в поле field (чтобы не проверять его дальше)
+func (v *Validator) Has(field string) bool {
+	return slices.ContainsFunc(v.errs, func(f FieldError) bool { return f.Field == field })
+}
+
+// Err возвращает Errors со всеми ошибками или nil
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Required - строка не пустая (пробелы не считаются)
+func (v *Validator) Required(field, value string) bool {
+	return v.Check(strings.TrimSpace(value) != "", field, CodeRequired, "%s is required", field)
+}
+
+// MaxLen - строка не длиннее max символов
+func (v *Validator) MaxLen(field, value string, max int) bool {
+	n := utf8.RuneCountInString(value)
+	return v.Check(n <= max, field, CodeTooLong, "%s is %d characters long, at most %d allowed", field, n, max)
+}
+
+// Name - обязательное имя или название не длиннее MaxNameLength
+func (v *Validator) Name(field, value string) bool {
+	return v.Required(field, value) && v.MaxLen(field, value, MaxNameLength)
+}
+
+// Date - дата в одном из форматов yyyy-mm-dd, dd.mm.yyyy, dd-mm-yyyy, dd/mm/yyyy;
+// пустая строка допустима (дата не указана)
+func (v *Validator) Date(field, value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return true
+	}
+	if _, err := ParseDate(value); err == nil {
+		return true
+	}
+	v.Add(field, CodeBadDate, "%s %q is not a date, expected yyyy-mm-dd or dd.mm.yyyy", field, value)
+	return false
+}
+
+// ParseDate разбирает дату в одном из форматов dateLayouts. Тем же разбором
+// хранилище приводит даты к yyyy-mm-dd, поэтому Date пропускает ровно те даты,
+// которые хранилище сможет сохранить
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// URL - абсолютная ссылка с хостом и одной из схем schemes, не длиннее
+// MaxLinkLength; пустая строка допустима (ссылки нет)
+func (v *Validator) URL(field, value string, schemes ...string) bool {
+	if value == "" {
+		return true
+	}
+	if !v.MaxLen(field, value, MaxLinkLength) {
+		return false
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || !slices.Contains(schemes, strings.ToLower(u.Scheme)) {
+		v.Add(field, CodeBadURL, "%s must be an absolute %s url", field, strings.Join(schemes, " or "))
+		return false
+	}
+	return true
+}
+
+// OneOf - значение из списка allowed
+func (v *Validator) OneOf(field, value string, allowed []string) bool {
+	return v.Check(slices.Contains(allowed, value), field, CodeBadValue,
+		"%s must be one of %s, got %q", field, strings.Join(allowed, ", "), value)
+}
+
+// Int разбирает целое число из параметра запроса и проверяет, что оно не меньше min;
+// пустая строка даёт def
+func (v *Validator) Int(field, value string, min, def int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		v.Add(field, CodeBadNumber, "%s must be an integer, got %q", field, value)
+		return def, false
+	}
+	if !v.Min(field, n, min) {
+		return def, false
+	}
+	return n, true
+}
+
+// Min - число не меньше min
+func (v *Validator) Min(field string, n, min int) bool {
+	return v.Check(n >= min, field, CodeOutOfRange, "%s must be at least %d, got %d", field, min, n)
+}
+
+// Bool разбирает флаг из параметра запроса (true, false, 1, 0...), пустая строка - false
+func (v *Validator) Bool(field, value string) (bool, bool) {
+	if value == "" {
+		return false, true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		v.Add(field, CodeBadBool, "%s must be true or false, got %q", field, value)
+		return false, false
+	}
+	return b, true
+}